@@ -2,6 +2,7 @@ package jsonrpc4go
 
 import (
 	"errors"
+	"io"
 	"reflect"
 	"strings"
 
@@ -12,8 +13,9 @@ import (
 /**
  * @Description: Create a new JSON-RPC client
  * @Param name: Service name
- * @Param protocol: Protocol type (http/https/tcp)
- * @Param server: Service address (string) or service discovery driver (discovery.Driver)
+ * @Param protocol: Protocol type (http/https/tcp/ws/wss/unix/stdio)
+ * @Param server: Service address (string) or service discovery driver (discovery.Driver),
+ * comma separated socket paths for unix, nil for stdio on stdin and stdout or an io.ReadWriter
  * @Return client.Client: Client interface
  * @Return error: Error message
  */
@@ -23,6 +25,13 @@ func NewClient(name string, protocol string, server any) (client.Client, error)
 		address string
 		dc      discovery.Driver
 	)
+	if strings.EqualFold(protocol, "stdio") {
+		p = &client.Stdio{Name: name}
+		if rw, ok := server.(io.ReadWriter); ok {
+			p = &client.Stdio{Name: name, Reader: rw, Writer: rw}
+		}
+		return client.NewClient(p), nil
+	}
 	if reflect.TypeOf(server).Kind() == reflect.String {
 		address = server.(string)
 	} else {
@@ -34,8 +43,10 @@ func NewClient(name string, protocol string, server any) (client.Client, error)
 		p = &client.Http{Name: name, Protocol: protocol, Address: address, Discovery: dc}
 	case "https":
 		p = &client.Http{Name: name, Protocol: protocol, Address: address, Discovery: dc}
-	case "tcp":
+	case "tcp", "unix":
 		p = &client.Tcp{Name: name, Protocol: protocol, Address: address, Discovery: dc}
+	case "ws", "wss":
+		p = &client.WebSocket{Name: name, Protocol: protocol, Address: address, Discovery: dc}
 	default:
 		return nil, errors.New("the protocol can not be supported")
 	}
@@ -0,0 +1,503 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+	"github.com/sunquakes/jsonrpc4go/discovery"
+)
+
+/**
+ * @Description: Number of virtual nodes per instance on the consistent hash ring
+ */
+const HASH_REPLICAS = 100
+
+/**
+ * @Description: Error returned by a balancer without instances
+ */
+var ErrNoInstance = errors.New("no available instance")
+
+/**
+ * @Description: Information about the call an instance is picked for
+ * @Field Method: Method name without the service name, the first one for batch calls
+ * @Field Params: Call parameters
+ * @Field Exclude: Addresses already tried by the call, avoided unless every instance is excluded
+ */
+type PickInfo struct {
+	Method  string
+	Params  any
+	Exclude []string
+}
+
+/**
+ * @Description: Load balancer choosing the instance of every call, shared by the HTTP and TCP clients
+ */
+type Balancer interface {
+	/**
+	 * @Description: Replace the instances to balance between
+	 * @Param instances: Healthy instances
+	 */
+	Update(instances []discovery.Instance)
+	/**
+	 * @Description: Pick an instance for a call
+	 * @Param info: Call information
+	 * @Return discovery.Instance: Picked instance
+	 * @Return error: ErrNoInstance when there is no instance
+	 */
+	Pick(info PickInfo) (discovery.Instance, error)
+	/**
+	 * @Description: Report the end of a call on a picked instance
+	 * @Param instance: Instance returned by Pick
+	 * @Param err: Error of the call
+	 */
+	Done(instance discovery.Instance, err error)
+}
+
+/**
+ * @Description: Round-robin balancer
+ * @Field Lock: Mutex lock
+ * @Field Instances: Instance list
+ * @Field Next: Index of the next instance
+ */
+type RoundRobinBalancer struct {
+	Lock      sync.Mutex
+	Instances []discovery.Instance
+	Next      int
+}
+
+/**
+ * @Description: Create a round-robin balancer
+ * @Return *RoundRobinBalancer: Balancer pointer
+ */
+func NewRoundRobinBalancer() *RoundRobinBalancer {
+	return &RoundRobinBalancer{}
+}
+
+/**
+ * @Description: Replace the instances to balance between
+ * @Receiver b: RoundRobinBalancer structure pointer
+ * @Param instances: Healthy instances
+ */
+func (b *RoundRobinBalancer) Update(instances []discovery.Instance) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	b.Instances = instances
+}
+
+/**
+ * @Description: Pick the next instance
+ * @Receiver b: RoundRobinBalancer structure pointer
+ * @Param info: Call information
+ * @Return discovery.Instance: Picked instance
+ * @Return error: Error message
+ */
+func (b *RoundRobinBalancer) Pick(info PickInfo) (discovery.Instance, error) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	if len(b.Instances) == 0 {
+		return discovery.Instance{}, ErrNoInstance
+	}
+	instances := candidates(b.Instances, info.Exclude)
+	instance := instances[b.Next%len(instances)]
+	b.Next = (b.Next + 1) % len(b.Instances)
+	return instance, nil
+}
+
+/**
+ * @Description: Report the end of a call, unused by round-robin
+ * @Receiver b: RoundRobinBalancer structure pointer
+ * @Param instance: Instance returned by Pick
+ * @Param err: Error of the call
+ */
+func (b *RoundRobinBalancer) Done(instance discovery.Instance, err error) {
+}
+
+/**
+ * @Description: Random balancer
+ * @Field Lock: Mutex lock
+ * @Field Instances: Instance list
+ */
+type RandomBalancer struct {
+	Lock      sync.Mutex
+	Instances []discovery.Instance
+}
+
+/**
+ * @Description: Create a random balancer
+ * @Return *RandomBalancer: Balancer pointer
+ */
+func NewRandomBalancer() *RandomBalancer {
+	return &RandomBalancer{}
+}
+
+/**
+ * @Description: Replace the instances to balance between
+ * @Receiver b: RandomBalancer structure pointer
+ * @Param instances: Healthy instances
+ */
+func (b *RandomBalancer) Update(instances []discovery.Instance) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	b.Instances = instances
+}
+
+/**
+ * @Description: Pick a random instance
+ * @Receiver b: RandomBalancer structure pointer
+ * @Param info: Call information
+ * @Return discovery.Instance: Picked instance
+ * @Return error: Error message
+ */
+func (b *RandomBalancer) Pick(info PickInfo) (discovery.Instance, error) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	if len(b.Instances) == 0 {
+		return discovery.Instance{}, ErrNoInstance
+	}
+	instances := candidates(b.Instances, info.Exclude)
+	return instances[rand.Intn(len(instances))], nil
+}
+
+/**
+ * @Description: Report the end of a call, unused by random
+ * @Receiver b: RandomBalancer structure pointer
+ * @Param instance: Instance returned by Pick
+ * @Param err: Error of the call
+ */
+func (b *RandomBalancer) Done(instance discovery.Instance, err error) {
+}
+
+/**
+ * @Description: Smooth weighted round-robin balancer using the discovery weights
+ * @Field Lock: Mutex lock
+ * @Field Instances: Instance list
+ * @Field Current: Current weight of every instance
+ */
+type WeightedRoundRobinBalancer struct {
+	Lock      sync.Mutex
+	Instances []discovery.Instance
+	Current   []float64
+}
+
+/**
+ * @Description: Create a smooth weighted round-robin balancer
+ * @Return *WeightedRoundRobinBalancer: Balancer pointer
+ */
+func NewWeightedRoundRobinBalancer() *WeightedRoundRobinBalancer {
+	return &WeightedRoundRobinBalancer{}
+}
+
+/**
+ * @Description: Replace the instances to balance between, keeping the current weight of the remaining ones
+ * @Receiver b: WeightedRoundRobinBalancer structure pointer
+ * @Param instances: Healthy instances
+ */
+func (b *WeightedRoundRobinBalancer) Update(instances []discovery.Instance) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	current := make(map[string]float64)
+	for i, instance := range b.Instances {
+		current[instance.Address()] = b.Current[i]
+	}
+	b.Instances = instances
+	b.Current = make([]float64, len(instances))
+	for i, instance := range instances {
+		b.Current[i] = current[instance.Address()]
+	}
+}
+
+/**
+ * @Description: Pick the instance with the highest current weight
+ * @Receiver b: WeightedRoundRobinBalancer structure pointer
+ * @Param info: Call information
+ * @Return discovery.Instance: Picked instance
+ * @Return error: Error message
+ */
+func (b *WeightedRoundRobinBalancer) Pick(info PickInfo) (discovery.Instance, error) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	if len(b.Instances) == 0 {
+		return discovery.Instance{}, ErrNoInstance
+	}
+	skip := skipped(b.Instances, info.Exclude)
+	total := 0.0
+	for i, instance := range b.Instances {
+		if !skip[i] {
+			total += max(instance.Weight, 0)
+		}
+	}
+	best := -1
+	for i, instance := range b.Instances {
+		if skip[i] {
+			continue
+		}
+		weight := max(instance.Weight, 0)
+		// Balance evenly when no instance has a weight.
+		if total == 0 {
+			weight = 1
+		}
+		b.Current[i] += weight
+		if best < 0 || b.Current[i] > b.Current[best] {
+			best = i
+		}
+	}
+	if total == 0 {
+		total = float64(len(b.Instances) - len(skip))
+	}
+	b.Current[best] -= total
+	return b.Instances[best], nil
+}
+
+/**
+ * @Description: Report the end of a call, unused by weighted round-robin
+ * @Receiver b: WeightedRoundRobinBalancer structure pointer
+ * @Param instance: Instance returned by Pick
+ * @Param err: Error of the call
+ */
+func (b *WeightedRoundRobinBalancer) Done(instance discovery.Instance, err error) {
+}
+
+/**
+ * @Description: Least outstanding requests balancer
+ * @Field Lock: Mutex lock
+ * @Field Instances: Instance list
+ * @Field Outstanding: Number of unfinished calls per address
+ * @Field Next: Index the search for the least loaded instance starts from, rotating between ties
+ */
+type LeastRequestBalancer struct {
+	Lock        sync.Mutex
+	Instances   []discovery.Instance
+	Outstanding map[string]int
+	Next        int
+}
+
+/**
+ * @Description: Create a least outstanding requests balancer
+ * @Return *LeastRequestBalancer: Balancer pointer
+ */
+func NewLeastRequestBalancer() *LeastRequestBalancer {
+	return &LeastRequestBalancer{Outstanding: make(map[string]int)}
+}
+
+/**
+ * @Description: Replace the instances to balance between
+ * @Receiver b: LeastRequestBalancer structure pointer
+ * @Param instances: Healthy instances
+ */
+func (b *LeastRequestBalancer) Update(instances []discovery.Instance) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	b.Instances = instances
+}
+
+/**
+ * @Description: Pick the instance with the fewest unfinished calls
+ * @Receiver b: LeastRequestBalancer structure pointer
+ * @Param info: Call information
+ * @Return discovery.Instance: Picked instance
+ * @Return error: Error message
+ */
+func (b *LeastRequestBalancer) Pick(info PickInfo) (discovery.Instance, error) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	size := len(b.Instances)
+	if size == 0 {
+		return discovery.Instance{}, ErrNoInstance
+	}
+	skip := skipped(b.Instances, info.Exclude)
+	best := -1
+	for i := 0; i < size; i++ {
+		j := (b.Next + i) % size
+		if skip[j] {
+			continue
+		}
+		if best < 0 || b.Outstanding[b.Instances[j].Address()] < b.Outstanding[b.Instances[best].Address()] {
+			best = j
+		}
+	}
+	b.Next = (b.Next + 1) % size
+	instance := b.Instances[best]
+	b.Outstanding[instance.Address()]++
+	return instance, nil
+}
+
+/**
+ * @Description: Decrease the unfinished calls of the instance
+ * @Receiver b: LeastRequestBalancer structure pointer
+ * @Param instance: Instance returned by Pick
+ * @Param err: Error of the call
+ */
+func (b *LeastRequestBalancer) Done(instance discovery.Instance, err error) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	address := instance.Address()
+	if b.Outstanding[address] <= 1 {
+		delete(b.Outstanding, address)
+		return
+	}
+	b.Outstanding[address]--
+}
+
+/**
+ * @Description: Consistent hashing balancer sending the calls with the same key to the same instance
+ * @Field Lock: Mutex lock
+ * @Field Key: Name of the params field hashed, the whole params are hashed when empty
+ * @Field Hashes: Sorted hashes of the virtual nodes
+ * @Field Ring: Instance of every virtual node hash
+ */
+type ConsistentHashBalancer struct {
+	Lock   sync.Mutex
+	Key    string
+	Hashes []uint32
+	Ring   map[uint32]discovery.Instance
+}
+
+/**
+ * @Description: Create a consistent hashing balancer
+ * @Param key: Name of the params field hashed, the whole params are hashed when empty
+ * @Return *ConsistentHashBalancer: Balancer pointer
+ */
+func NewConsistentHashBalancer(key string) *ConsistentHashBalancer {
+	return &ConsistentHashBalancer{Key: key, Ring: make(map[uint32]discovery.Instance)}
+}
+
+/**
+ * @Description: Rebuild the hash ring
+ * @Receiver b: ConsistentHashBalancer structure pointer
+ * @Param instances: Healthy instances
+ */
+func (b *ConsistentHashBalancer) Update(instances []discovery.Instance) {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	b.Hashes = make([]uint32, 0, len(instances)*HASH_REPLICAS)
+	b.Ring = make(map[uint32]discovery.Instance)
+	for _, instance := range instances {
+		for i := 0; i < HASH_REPLICAS; i++ {
+			hash := crc32.ChecksumIEEE([]byte(instance.Address() + "#" + strconv.Itoa(i)))
+			if _, ok := b.Ring[hash]; ok {
+				continue
+			}
+			b.Ring[hash] = instance
+			b.Hashes = append(b.Hashes, hash)
+		}
+	}
+	sort.Slice(b.Hashes, func(i, j int) bool {
+		return b.Hashes[i] < b.Hashes[j]
+	})
+}
+
+/**
+ * @Description: Pick the instance owning the hash of the key
+ * @Receiver b: ConsistentHashBalancer structure pointer
+ * @Param info: Call information
+ * @Return discovery.Instance: Picked instance
+ * @Return error: Error message
+ */
+func (b *ConsistentHashBalancer) Pick(info PickInfo) (discovery.Instance, error) {
+	key := HashKey(info.Params, b.Key)
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	if len(b.Hashes) == 0 {
+		return discovery.Instance{}, ErrNoInstance
+	}
+	hash := crc32.ChecksumIEEE(key)
+	i := sort.Search(len(b.Hashes), func(i int) bool {
+		return b.Hashes[i] >= hash
+	})
+	// Walk the ring to the next instance not excluded.
+	for j := 0; j < len(b.Hashes); j++ {
+		instance := b.Ring[b.Hashes[(i+j)%len(b.Hashes)]]
+		if !slices.Contains(info.Exclude, instance.Address()) {
+			return instance, nil
+		}
+	}
+	return b.Ring[b.Hashes[i%len(b.Hashes)]], nil
+}
+
+/**
+ * @Description: Report the end of a call, unused by consistent hashing
+ * @Receiver b: ConsistentHashBalancer structure pointer
+ * @Param instance: Instance returned by Pick
+ * @Param err: Error of the call
+ */
+func (b *ConsistentHashBalancer) Done(instance discovery.Instance, err error) {
+}
+
+/**
+ * @Description: Get the bytes hashed for the params
+ * @Param params: Call parameters
+ * @Param key: Name of the params field, the whole params are used when empty or missing
+ * @Return []byte: JSON encoding of the field or of the params
+ */
+func HashKey(params any, key string) []byte {
+	b, err := json.Marshal(params)
+	if err != nil || key == "" {
+		return b
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(b, &fields) != nil {
+		return b
+	}
+	if field, ok := fields[key]; ok {
+		return field
+	}
+	return b
+}
+
+/**
+ * @Description: Get the instances not excluded
+ * @Param instances: Instance list
+ * @Param exclude: Excluded addresses
+ * @Return []discovery.Instance: Instances not excluded, all instances when every one is excluded
+ */
+func candidates(instances []discovery.Instance, exclude []string) []discovery.Instance {
+	if len(exclude) == 0 {
+		return instances
+	}
+	skip := skipped(instances, exclude)
+	list := make([]discovery.Instance, 0, len(instances)-len(skip))
+	for i, instance := range instances {
+		if !skip[i] {
+			list = append(list, instance)
+		}
+	}
+	return list
+}
+
+/**
+ * @Description: Get the indexes of the excluded instances
+ * @Param instances: Instance list
+ * @Param exclude: Excluded addresses
+ * @Return map[int]bool: Indexes to skip, empty when every instance is excluded
+ */
+func skipped(instances []discovery.Instance, exclude []string) map[int]bool {
+	skip := make(map[int]bool)
+	for i, instance := range instances {
+		if slices.Contains(exclude, instance.Address()) {
+			skip[i] = true
+		}
+	}
+	if len(skip) == len(instances) {
+		return map[int]bool{}
+	}
+	return skip
+}
+
+/**
+ * @Description: Get the call information of a batch, taken from its first request
+ * @Param requests: Batch request list
+ * @Return PickInfo: Call information
+ */
+func batchPickInfo(requests []*common.SingleRequest) PickInfo {
+	if len(requests) == 0 {
+		return PickInfo{}
+	}
+	return PickInfo{Method: requests[0].Method, Params: requests[0].Params}
+}
@@ -0,0 +1,325 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/**
+ * @Description: State of the circuit breaker of an address
+ */
+type BreakerState int
+
+const (
+	/**
+	 * @Description: Requests are sent and their failures counted
+	 */
+	BREAKER_CLOSED BreakerState = iota
+	/**
+	 * @Description: Requests are rejected until the cooldown elapses
+	 */
+	BREAKER_OPEN
+	/**
+	 * @Description: A limited number of probe requests decide whether to close or open again
+	 */
+	BREAKER_HALF_OPEN
+)
+
+const (
+	/**
+	 * @Description: Default number of consecutive failures opening the breaker
+	 */
+	DEFAULT_BREAKER_CONSECUTIVE_FAILURES = 5
+	/**
+	 * @Description: Default failure ratio opening the breaker
+	 */
+	DEFAULT_BREAKER_FAILURE_RATIO = 0.5
+	/**
+	 * @Description: Default minimum number of requests in the window before the failure ratio applies
+	 */
+	DEFAULT_BREAKER_MIN_REQUESTS = 10
+	/**
+	 * @Description: Default length of the window the failure ratio is computed on
+	 */
+	DEFAULT_BREAKER_WINDOW = 10 * time.Second
+	/**
+	 * @Description: Default time an open breaker waits before letting probe requests through
+	 */
+	DEFAULT_BREAKER_COOLDOWN = time.Second
+	/**
+	 * @Description: Default number of concurrent probe requests when half-open
+	 */
+	DEFAULT_BREAKER_HALF_OPEN_REQUESTS = 1
+)
+
+/**
+ * @Description: Error returned when the breaker of the picked address rejects the request
+ */
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+/**
+ * @Description: Get the name of the state
+ * @Receiver s: BreakerState
+ * @Return string: State name
+ */
+func (s BreakerState) String() string {
+	switch s {
+	case BREAKER_OPEN:
+		return "open"
+	case BREAKER_HALF_OPEN:
+		return "half-open"
+	}
+	return "closed"
+}
+
+/**
+ * @Description: Circuit breaker options, zero fields take the default values
+ * @Field ConsecutiveFailures: Number of consecutive failures opening the breaker, negative to disable
+ * @Field FailureRatio: Failure ratio in the window opening the breaker, negative to disable
+ * @Field MinRequests: Minimum number of requests in the window before the failure ratio applies
+ * @Field Window: Length of the window the failure ratio is computed on
+ * @Field Cooldown: Time an open breaker waits before letting probe requests through
+ * @Field HalfOpenRequests: Number of concurrent probe requests when half-open
+ * @Field OnStateChange: Function called after the breaker of an address changes state
+ */
+type BreakerOptions struct {
+	ConsecutiveFailures int
+	FailureRatio        float64
+	MinRequests         int
+	Window              time.Duration
+	Cooldown            time.Duration
+	HalfOpenRequests    int
+	OnStateChange       func(address string, from BreakerState, to BreakerState)
+}
+
+/**
+ * @Description: Counters of the breaker of an address
+ * @Field State: Current state
+ * @Field Requests: Number of requests in the window
+ * @Field Failures: Number of failed requests in the window
+ * @Field Consecutive: Number of consecutive failures
+ * @Field WindowStart: Start of the window
+ * @Field OpenedAt: Time the breaker opened
+ * @Field Probes: Number of probe requests in flight
+ */
+type BreakerStatus struct {
+	State       BreakerState
+	Requests    int
+	Failures    int
+	Consecutive int
+	WindowStart time.Time
+	OpenedAt    time.Time
+	Probes      int
+}
+
+/**
+ * @Description: Circuit breaker keeping one state per upstream address
+ * @Field Options: Circuit breaker options
+ * @Field Lock: Mutex lock
+ * @Field Status: Breaker counters per address
+ */
+type CircuitBreaker struct {
+	Options BreakerOptions
+	Lock    sync.Mutex
+	Status  map[string]*BreakerStatus
+}
+
+/**
+ * @Description: Create a circuit breaker
+ * @Param options: Circuit breaker options
+ * @Return *CircuitBreaker: Circuit breaker pointer
+ */
+func NewCircuitBreaker(options BreakerOptions) *CircuitBreaker {
+	if options.ConsecutiveFailures == 0 {
+		options.ConsecutiveFailures = DEFAULT_BREAKER_CONSECUTIVE_FAILURES
+	}
+	if options.FailureRatio == 0 {
+		options.FailureRatio = DEFAULT_BREAKER_FAILURE_RATIO
+	}
+	if options.MinRequests <= 0 {
+		options.MinRequests = DEFAULT_BREAKER_MIN_REQUESTS
+	}
+	if options.Window <= 0 {
+		options.Window = DEFAULT_BREAKER_WINDOW
+	}
+	if options.Cooldown <= 0 {
+		options.Cooldown = DEFAULT_BREAKER_COOLDOWN
+	}
+	if options.HalfOpenRequests <= 0 {
+		options.HalfOpenRequests = DEFAULT_BREAKER_HALF_OPEN_REQUESTS
+	}
+	return &CircuitBreaker{
+		Options: options,
+		Status:  make(map[string]*BreakerStatus),
+	}
+}
+
+/**
+ * @Description: Get the state of an address
+ * @Receiver b: CircuitBreaker structure pointer
+ * @Param address: Upstream address
+ * @Return BreakerState: Current state, closed for unknown addresses
+ */
+func (b *CircuitBreaker) State(address string) BreakerState {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	if status, ok := b.Status[address]; ok {
+		return status.State
+	}
+	return BREAKER_CLOSED
+}
+
+/**
+ * @Description: Get the addresses whose requests would be rejected now
+ * @Receiver b: CircuitBreaker structure pointer
+ * @Return []string: Open addresses still cooling down and half-open addresses without free probe
+ */
+func (b *CircuitBreaker) Blocked() []string {
+	b.Lock.Lock()
+	defer b.Lock.Unlock()
+	blocked := make([]string, 0)
+	for address, status := range b.Status {
+		switch status.State {
+		case BREAKER_OPEN:
+			if time.Since(status.OpenedAt) < b.Options.Cooldown {
+				blocked = append(blocked, address)
+			}
+		case BREAKER_HALF_OPEN:
+			if status.Probes >= b.Options.HalfOpenRequests {
+				blocked = append(blocked, address)
+			}
+		}
+	}
+	return blocked
+}
+
+/**
+ * @Description: Check whether a request may be sent to an address, every allowed request must be reported
+ * @Receiver b: CircuitBreaker structure pointer
+ * @Param address: Upstream address
+ * @Return bool: Whether the request may be sent
+ */
+func (b *CircuitBreaker) Allow(address string) bool {
+	b.Lock.Lock()
+	status := b.status(address)
+	from := status.State
+	if status.State == BREAKER_OPEN && time.Since(status.OpenedAt) >= b.Options.Cooldown {
+		status.State = BREAKER_HALF_OPEN
+		status.Probes = 0
+	}
+	allowed := true
+	switch status.State {
+	case BREAKER_OPEN:
+		allowed = false
+	case BREAKER_HALF_OPEN:
+		allowed = status.Probes < b.Options.HalfOpenRequests
+		if allowed {
+			status.Probes++
+		}
+	}
+	to := status.State
+	b.Lock.Unlock()
+	b.changed(address, from, to)
+	return allowed
+}
+
+/**
+ * @Description: Report the end of an allowed request
+ * @Receiver b: CircuitBreaker structure pointer
+ * @Param address: Upstream address
+ * @Param err: Error of the request
+ */
+func (b *CircuitBreaker) Report(address string, err error) {
+	b.Lock.Lock()
+	status := b.status(address)
+	from := status.State
+	// The caller gave up, the request says nothing about the upstream.
+	canceled := errors.Is(err, ErrCanceled) || errors.Is(err, context.Canceled)
+	failed := !canceled && IsFailure(err)
+	switch status.State {
+	case BREAKER_HALF_OPEN:
+		status.Probes = max(status.Probes-1, 0)
+		if canceled {
+			break
+		}
+		if failed {
+			b.open(status)
+		} else {
+			*status = BreakerStatus{State: BREAKER_CLOSED, WindowStart: time.Now()}
+		}
+	case BREAKER_CLOSED:
+		if canceled {
+			break
+		}
+		if time.Since(status.WindowStart) >= b.Options.Window {
+			status.Requests, status.Failures, status.WindowStart = 0, 0, time.Now()
+		}
+		status.Requests++
+		if !failed {
+			status.Consecutive = 0
+			break
+		}
+		status.Failures++
+		status.Consecutive++
+		if b.Options.ConsecutiveFailures > 0 && status.Consecutive >= b.Options.ConsecutiveFailures {
+			b.open(status)
+		} else if b.Options.FailureRatio > 0 && status.Requests >= b.Options.MinRequests &&
+			float64(status.Failures)/float64(status.Requests) >= b.Options.FailureRatio {
+			b.open(status)
+		}
+	}
+	to := status.State
+	b.Lock.Unlock()
+	b.changed(address, from, to)
+}
+
+/**
+ * @Description: Get the counters of an address, the caller must hold the lock
+ * @Receiver b: CircuitBreaker structure pointer
+ * @Param address: Upstream address
+ * @Return *BreakerStatus: Breaker counters
+ */
+func (b *CircuitBreaker) status(address string) *BreakerStatus {
+	status, ok := b.Status[address]
+	if !ok {
+		status = &BreakerStatus{State: BREAKER_CLOSED, WindowStart: time.Now()}
+		b.Status[address] = status
+	}
+	return status
+}
+
+/**
+ * @Description: Open a breaker, the caller must hold the lock
+ * @Receiver b: CircuitBreaker structure pointer
+ * @Param status: Breaker counters
+ */
+func (b *CircuitBreaker) open(status *BreakerStatus) {
+	*status = BreakerStatus{State: BREAKER_OPEN, WindowStart: time.Now(), OpenedAt: time.Now()}
+}
+
+/**
+ * @Description: Call the state change callback when the state changed
+ * @Receiver b: CircuitBreaker structure pointer
+ * @Param address: Upstream address
+ * @Param from: Previous state
+ * @Param to: New state
+ */
+func (b *CircuitBreaker) changed(address string, from BreakerState, to BreakerState) {
+	if from != to && b.Options.OnStateChange != nil {
+		b.Options.OnStateChange(address, from, to)
+	}
+}
+
+/**
+ * @Description: Check whether an error counts as a failure of the upstream
+ * @Param err: Error of the request
+ * @Return bool: False for success and for error responses, the upstream answered them
+ */
+func IsFailure(err error) bool {
+	var ce *common.Error
+	return err != nil && !errors.As(err, &ce)
+}
@@ -1,5 +1,11 @@
 package client
 
+import (
+	"context"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
 /*
  * Protocol defines the interface for client protocol implementations.
  */
@@ -47,6 +53,22 @@ type Client interface {
 	 */
 	Call(string, any, any, bool) error
 
+	/*
+	 * CallContext executes a single JSON-RPC method call bound to a context.
+	 * The context deadline and cancellation apply to dialing, writing and reading.
+	 *
+	 * Parameters:
+	 *   context.Context - Context controlling the call
+	 *   string          - JSON-RPC method name
+	 *   any             - Method parameters
+	 *   any             - Pointer to store the result
+	 *   bool            - Whether this is a notification (no response expected)
+	 *
+	 * Returns:
+	 *   error - Error if the call fails, ErrTimeout or ErrCanceled if the context ends first
+	 */
+	CallContext(context.Context, string, any, any, bool) error
+
 	/*
 	 * BatchAppend adds a request to the batch operation list.
 	 *
@@ -68,6 +90,55 @@ type Client interface {
 	 *   error - Error if the batch operation fails
 	 */
 	BatchCall() error
+
+	/*
+	 * BatchCallContext executes all requests in the batch list bound to a context.
+	 *
+	 * Parameters:
+	 *   context.Context - Context controlling the batch call
+	 *
+	 * Returns:
+	 *   error - Error if the batch operation fails, ErrTimeout or ErrCanceled if the context ends first
+	 */
+	BatchCallContext(context.Context) error
+
+	/*
+	 * BatchCallRequests executes a list of requests as one batch bound to a context, leaving the batch list untouched,
+	 * so that concurrent batches do not share it.
+	 *
+	 * Parameters:
+	 *   context.Context         - Context controlling the batch call
+	 *   []*common.SingleRequest - Requests, their results and errors are filled in
+	 *
+	 * Returns:
+	 *   error - Error if the batch operation fails, ErrTimeout or ErrCanceled if the context ends first
+	 */
+	BatchCallRequests(context.Context, []*common.SingleRequest) error
+
+	/*
+	 * Use appends interceptors wrapping every single call; the first one added is the outermost.
+	 * RequestFromContext gives the interceptors the outgoing request data and HTTP headers.
+	 *
+	 * Parameters:
+	 *   interceptors ...Interceptor - Interceptors to append
+	 */
+	Use(interceptors ...Interceptor)
+
+	/*
+	 * UseBatch appends interceptors wrapping every batch call; the first one added is the outermost.
+	 *
+	 * Parameters:
+	 *   interceptors ...BatchInterceptor - Batch interceptors to append
+	 */
+	UseBatch(interceptors ...BatchInterceptor)
+
+	/*
+	 * Close stops watching the discovery service and closes the idle connections.
+	 *
+	 * Returns:
+	 *   error - Error if closing fails
+	 */
+	Close() error
 }
 
 /*
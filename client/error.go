@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+/**
+ * @Description: Error returned when a call exceeds its context deadline
+ */
+var ErrTimeout = errors.New("jsonrpc4go: request timed out")
+
+/**
+ * @Description: Error returned when the context of a call is canceled
+ */
+var ErrCanceled = errors.New("jsonrpc4go: request canceled")
+
+/**
+ * @Description: Error returned when the connection of a call is lost before its response arrives
+ */
+var ErrConnectionLost = errors.New("jsonrpc4go: connection lost")
+
+/**
+ * @Description: Error returned when a call is made on a closed client
+ */
+var ErrClientClosed = errors.New("jsonrpc4go: client closed")
+
+/**
+ * @Description: Convert an error caused by an ended context into ErrTimeout or ErrCanceled
+ * @Param ctx: Context of the call
+ * @Param err: Original error
+ * @Return error: ErrTimeout or ErrCanceled wrapping the context error, otherwise the original error
+ */
+func ContextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	case context.Canceled:
+		return fmt.Errorf("%w: %w", ErrCanceled, ctx.Err())
+	}
+	// The connection deadline is taken from the context, it may fire before the context itself.
+	var ne net.Error
+	if _, ok := ctx.Deadline(); ok && errors.As(err, &ne) && ne.Timeout() {
+		return fmt.Errorf("%w: %w: %w", ErrTimeout, context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+/**
+ * @Description: Apply the context deadline and cancellation to a connection
+ * @Param ctx: Context of the call
+ * @Param conn: Network connection
+ * @Return func(): Function that stops watching the context and clears the deadline
+ */
+func watchContext(ctx context.Context, conn net.Conn) func() {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock pending reads and writes immediately.
+		conn.SetDeadline(time.Unix(1, 0))
+		close(done)
+	})
+	return func() {
+		if !stop() {
+			<-done
+		}
+		conn.SetDeadline(time.Time{})
+	}
+}
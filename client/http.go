@@ -2,17 +2,17 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"os"
-	"strconv"
-	"strings"
-	"time"
+	"slices"
+	"sync"
 
 	"github.com/sunquakes/jsonrpc4go/common"
 	"github.com/sunquakes/jsonrpc4go/discovery"
@@ -43,38 +43,61 @@ type Http struct {
  * @property Protocol - The protocol to use (http or https)
  * @property Address - The address of the service
  * @property Discovery - The service discovery driver
- * @property AddressList - The list of addresses for load balancing
+ * @property Instances - The healthy instances for load balancing
+ * @property Balancer - The load balancer picking the instance of every request
+ * @property Breaker - The circuit breaker rejecting the instances failing too often
  * @property RequestList - The list of requests for batch calls
  * @property Options - The HTTP client options
+ * @property Lock - The mutex protecting the instances and the balancer
+ * @property StopWatch - The function stopping the discovery watch, nil when not watching
+ * @property Interceptors - The interceptors wrapping single calls, the first one is the outermost
+ * @property BatchInterceptors - The interceptors wrapping batch calls, the first one is the outermost
+ * @property transport - The transport shared by the requests, keeping their connections alive; created by the first request
  */
 type HttpClient struct {
-	Name        string
-	Protocol    string
-	Address     string
-	Discovery   discovery.Driver
-	AddressList []*AddressInfo
-	RequestList []*common.SingleRequest
-	Options     *HttpOptions
+	Name              string
+	Protocol          string
+	Address           string
+	Discovery         discovery.Driver
+	Instances         []discovery.Instance
+	Balancer          Balancer
+	Breaker           *CircuitBreaker
+	RequestList       []*common.SingleRequest
+	Options           *HttpOptions
+	Lock              sync.Mutex
+	StopWatch         func()
+	Interceptors      []Interceptor
+	BatchInterceptors []BatchInterceptor
+	transport         *http.Transport
 }
 
 /*
- * AddressInfo represents address information for load balancing
- * @property Address - The address of the service
- * @property Load - The load of the service
+ * StatusError is returned when the server answers with a non-2xx status and no JSON body,
+ * e.g. from a proxy; it is retried as a network error
+ * @property StatusCode - The HTTP status code
+ * @property Status - The HTTP status line
  */
-type AddressInfo struct {
-	Address string
-	Load    int
+type StatusError struct {
+	StatusCode int
+	Status     string
 }
 
 /*
  * HttpOptions represents the options for the HTTP client
  * @property CaPath - The path to the CA file
  * @property TLSClientConfig - The TLS client configuration
+ * @property Balancer - The load balancer, round-robin when nil
+ * @property Retry - The retry policy, a single attempt is made when nil
+ * @property Breaker - The circuit breaker, one with the default options when nil
+ * @property IdGenerator - The request id generator, a counter shared by the clients when nil
  */
 type HttpOptions struct {
 	CaPath          string
 	TLSClientConfig *tls.Config
+	Balancer        Balancer
+	Retry           *RetryPolicy
+	Breaker         *CircuitBreaker
+	IdGenerator     IdGenerator
 }
 
 /*
@@ -95,15 +118,15 @@ func (p *Http) NewClient() Client {
  */
 func NewHttpClient(name string, protocol string, address string, dc discovery.Driver) *HttpClient {
 	c := &HttpClient{
-		name,
-		protocol,
-		address,
-		dc,
-		nil,
-		nil,
-		nil,
+		Name:      name,
+		Protocol:  protocol,
+		Address:   address,
+		Discovery: dc,
+		Balancer:  NewRoundRobinBalancer(),
+		Breaker:   NewCircuitBreaker(BreakerOptions{}),
 	}
 	c.SetAddressList()
+	c.Watch()
 	return c
 }
 
@@ -114,6 +137,16 @@ func NewHttpClient(name string, protocol string, address string, dc discovery.Dr
 func (c *HttpClient) SetOptions(httpOptions any) {
 	// Set http request options.
 	c.Options = httpOptions.(*HttpOptions)
+	var (
+		balancer Balancer
+		breaker  *CircuitBreaker
+	)
+	if c.Options != nil {
+		balancer = c.Options.Balancer
+		breaker = c.Options.Breaker
+	}
+	c.SetBalancer(balancer)
+	c.SetBreaker(breaker)
 	if c.Protocol == HTTPS_PROTOCOL && c.Options != nil && c.Options.CaPath != "" {
 		file, err := os.Open(c.Options.CaPath)
 		if err != nil {
@@ -130,6 +163,13 @@ func (c *HttpClient) SetOptions(httpOptions any) {
 			RootCAs: caCertPool,
 		}
 	}
+	// The next request creates a transport with the new TLS configuration.
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+		c.transport = nil
+	}
 }
 
 /*
@@ -165,26 +205,28 @@ func (c *HttpClient) BatchAppend(method string, params any, result any, isNotify
  * @return error - An error if the batch call failed
  */
 func (c *HttpClient) BatchCall() error {
-	var (
-		err error
-		br  []any
-	)
-	for _, v := range c.RequestList {
-		var (
-			req any
-		)
-		method := fmt.Sprintf("%s/%s", c.Name, v.Method)
-		if v.IsNotify {
-			req = common.Rs(nil, method, v.Params)
-		} else {
-			req = common.Rs(strconv.FormatInt(time.Now().Unix(), 10), method, v.Params)
-		}
-		br = append(br, req)
-	}
-	bReq := common.JsonBatchRs(br)
-	err = c.handleFunc(bReq, c.RequestList)
+	return c.BatchCallContext(context.Background())
+}
+
+/*
+ * BatchCallContext executes all requests in the batch bound to a context
+ * @param ctx - The context controlling the batch call
+ * @return error - An error if the batch call failed
+ */
+func (c *HttpClient) BatchCallContext(ctx context.Context) error {
+	requests := c.RequestList
 	c.RequestList = make([]*common.SingleRequest, 0)
-	return err
+	return c.BatchCallRequests(ctx, requests)
+}
+
+/*
+ * BatchCallRequests executes a list of requests as one batch bound to a context, without the batch list
+ * @param ctx - The context controlling the batch call
+ * @param requests - The requests, their results and errors are filled in
+ * @return error - An error if the batch call failed
+ */
+func (c *HttpClient) BatchCallRequests(ctx context.Context, requests []*common.SingleRequest) error {
+	return interceptBatch(ctx, c.BatchInterceptors, c.idGenerator(), c.Name, http.Header{}, requests, c.handleFunc)
 }
 
 /*
@@ -196,104 +238,294 @@ func (c *HttpClient) BatchCall() error {
  * @return error - An error if the call failed
  */
 func (c *HttpClient) Call(method string, params any, result any, isNotify bool) error {
-	var (
-		err error
-		req []byte
-	)
-	method = fmt.Sprintf("%s/%s", c.Name, method)
-	if isNotify {
-		req = common.JsonRs(nil, method, params)
-	} else {
-		req = common.JsonRs(strconv.FormatInt(time.Now().Unix(), 10), method, params)
+	return c.CallContext(context.Background(), method, params, result, isNotify)
+}
+
+/*
+ * CallContext executes a single request bound to a context
+ * @param ctx - The context controlling the request
+ * @param method - The method to call
+ * @param params - The parameters for the method
+ * @param result - The result of the method
+ * @param isNotify - Whether the request is a notification
+ * @return error - An error if the call failed
+ */
+func (c *HttpClient) CallContext(ctx context.Context, method string, params any, result any, isNotify bool) error {
+	return intercept(ctx, c.Interceptors, c.idGenerator(), c.Name, http.Header{}, method, params, result, isNotify, c.handleFunc)
+}
+
+/*
+ * idGenerator gets the request id generator
+ * @return IdGenerator - The generator of the options, the default counter when none is set
+ */
+func (c *HttpClient) idGenerator() IdGenerator {
+	if c.Options == nil {
+		return idGenerator(nil)
 	}
-	err = c.handleFunc(req, result)
-	return err
+	return idGenerator(c.Options.IdGenerator)
 }
 
 /*
- * handleFunc handles the HTTP request
+ * Use appends interceptors wrapping single calls
+ * @param interceptors - The interceptors, the first one added is the outermost
+ */
+func (c *HttpClient) Use(interceptors ...Interceptor) {
+	c.Interceptors = append(c.Interceptors, interceptors...)
+}
+
+/*
+ * UseBatch appends interceptors wrapping batch calls
+ * @param interceptors - The batch interceptors, the first one added is the outermost
+ */
+func (c *HttpClient) UseBatch(interceptors ...BatchInterceptor) {
+	c.BatchInterceptors = append(c.BatchInterceptors, interceptors...)
+}
+
+/*
+ * handleFunc handles the HTTP request, retrying with the retry policy
+ * @param ctx - The context controlling the request
+ * @param info - The call information passed to the balancer
+ * @param methods - The methods sent by the request
  * @param b - The request body
  * @param result - The result of the request
  * @return error - An error if the request failed
  */
-func (c *HttpClient) handleFunc(b []byte, result any) error {
-	address, err := c.GetAddress()
+func (c *HttpClient) handleFunc(ctx context.Context, info PickInfo, methods []string, b []byte, result any) error {
+	var retry *RetryPolicy
+	if c.Options != nil {
+		retry = c.Options.Retry
+	}
+	return retry.Do(ctx, info, methods, func(ctx context.Context, info PickInfo) (string, error) {
+		return c.attempt(ctx, info, b, result)
+	})
+}
+
+/*
+ * attempt sends the HTTP request once
+ * @param ctx - The context controlling the attempt
+ * @param info - The call information passed to the balancer
+ * @param b - The request body
+ * @param result - The result of the request
+ * @return string - The address of the instance used, empty when none was picked
+ * @return error - An error if the request failed
+ */
+func (c *HttpClient) attempt(ctx context.Context, info PickInfo, b []byte, result any) (address string, err error) {
+	instance, balancer, err := c.Pick(info)
 	if err != nil {
-		return err
+		return "", err
+	}
+	address = instance.Address()
+	breaker := c.breaker()
+	if !breaker.Allow(address) {
+		balancer.Done(instance, ErrCircuitOpen)
+		return address, ErrCircuitOpen
 	}
+	defer func() {
+		balancer.Done(instance, err)
+		breaker.Report(address, err)
+	}()
 	url := fmt.Sprintf("%s://%s", c.Protocol, address)
-	transport := &http.Transport{}
-	if c.Protocol == HTTPS_PROTOCOL && c.Options != nil && c.Options.TLSClientConfig != nil {
-		transport.TLSClientConfig = c.Options.TLSClientConfig
+	client := &http.Client{Transport: c.httpTransport()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return address, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r, ok := RequestFromContext(ctx); ok {
+		for k, v := range r.Header {
+			req.Header[k] = v
+		}
 	}
-	client := &http.Client{Transport: transport}
-	resp, err := client.Post(url, "application/json", bytes.NewReader(b))
+	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return address, ContextError(ctx, err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// An error response of the server has a JSON body, the status of a proxy has none.
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return address, ContextError(ctx, err)
+		}
+		if !json.Valid(body) {
+			return address, &StatusError{resp.StatusCode, resp.Status}
+		}
+		if !expectsResponse(result) {
+			return address, nil
+		}
+		return address, common.GetResult(body, result)
+	}
+	if !expectsResponse(result) {
+		return address, nil
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return address, ContextError(ctx, err)
 	}
 	err = common.GetResult(body, result)
-	return err
+	return address, err
+}
+
+/*
+ * httpTransport gets the transport shared by the requests
+ * @return *http.Transport - The transport, created with the TLS configuration of the options
+ */
+func (c *HttpClient) httpTransport() *http.Transport {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if c.transport == nil {
+		c.transport = http.DefaultTransport.(*http.Transport).Clone()
+		if c.Protocol == HTTPS_PROTOCOL && c.Options != nil && c.Options.TLSClientConfig != nil {
+			c.transport.TLSClientConfig = c.Options.TLSClientConfig
+		}
+	}
+	return c.transport
 }
 
 /*
- * SetAddressList sets the address list from the discovery service
+ * Error returns the status line
+ * @return string - The error message
+ */
+func (e *StatusError) Error() string {
+	return "jsonrpc4go: unexpected http status " + e.Status
+}
+
+/*
+ * SetAddressList sets the instances from the address or the discovery service
  */
 func (c *HttpClient) SetAddressList() {
 	var (
-		err error
+		instances []discovery.Instance
+		err       error
 	)
-	address := c.Address
-	if c.Discovery != nil {
-		address, err = c.Discovery.Get(c.Name)
-		if err != nil {
-			common.Debug(err.Error())
+	if c.Discovery == nil {
+		instances, err = discovery.ParseAddresses(c.Name, c.Address)
+	} else {
+		instances, err = c.Discovery.GetInstances(c.Name)
+		instances = discovery.Healthy(instances)
+	}
+	if err != nil {
+		common.Debug(err.Error())
+	}
+	c.UpdateInstances(instances)
+}
+
+/*
+ * UpdateInstances replaces the instances to balance between
+ * @param instances - The healthy instances
+ */
+func (c *HttpClient) UpdateInstances(instances []discovery.Instance) {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	c.Instances = instances
+	c.Balancer.Update(instances)
+}
+
+/*
+ * SetBalancer sets the load balancer
+ * @param balancer - The load balancer, round-robin when nil
+ */
+func (c *HttpClient) SetBalancer(balancer Balancer) {
+	if balancer == nil {
+		balancer = NewRoundRobinBalancer()
+	}
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	balancer.Update(c.Instances)
+	c.Balancer = balancer
+}
+
+/*
+ * SetBreaker sets the circuit breaker
+ * @param breaker - The circuit breaker, one with the default options when nil
+ */
+func (c *HttpClient) SetBreaker(breaker *CircuitBreaker) {
+	if breaker == nil {
+		breaker = NewCircuitBreaker(BreakerOptions{})
+	}
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	c.Breaker = breaker
+}
+
+/*
+ * breaker gets the circuit breaker
+ * @return *CircuitBreaker - The circuit breaker
+ */
+func (c *HttpClient) breaker() *CircuitBreaker {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	return c.Breaker
+}
+
+/*
+ * Watch subscribes to the discovery service when it supports watching
+ */
+func (c *HttpClient) Watch() {
+	w, ok := c.Discovery.(discovery.Watcher)
+	if !ok {
+		return
+	}
+	ch, cancel := w.Watch(c.Name)
+	c.StopWatch = cancel
+	go func() {
+		for instances := range ch {
+			c.UpdateInstances(discovery.Healthy(instances))
 		}
+	}()
+}
+
+/*
+ * Close stops watching the discovery service and closes the idle connections
+ * @return error - An error if closing failed
+ */
+func (c *HttpClient) Close() error {
+	if c.StopWatch != nil {
+		c.StopWatch()
 	}
-	addresses := strings.Split(address, ",")
-	addressList := make([]*AddressInfo, 0)
-	for _, v := range addresses {
-		addressList = append(addressList, &AddressInfo{
-			Address: v,
-			Load:    0,
-		})
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
 	}
-	c.AddressList = addressList
+	return nil
 }
 
 /*
- * GetAddress gets an address from the address list using load balancing
- * @return string - The address to use
- * @return error - An error if no address is available
+ * Pick picks an instance for a request using the load balancer, avoiding the instances rejected by the circuit breaker
+ * @param info - The call information passed to the balancer
+ * @return discovery.Instance - The instance to use
+ * @return Balancer - The balancer to report the end of the request to
+ * @return error - An error if no instance is available
  */
-func (c *HttpClient) GetAddress() (string, error) {
-	size := len(c.AddressList)
+func (c *HttpClient) Pick(info PickInfo) (discovery.Instance, Balancer, error) {
+	c.Lock.Lock()
+	size := len(c.Instances)
+	c.Lock.Unlock()
 	if size == 0 {
 		c.SetAddressList()
 	}
-	size = len(c.AddressList)
-	if size == 0 {
-		return "", errors.New("fail to get service url")
-	}
-	if size == 1 {
-		return c.AddressList[0].Address, nil
-	}
-	// Randomly select two nodes
-	randSource := rand.New(rand.NewSource(time.Now().UnixNano()))
-	index1 := randSource.Intn(size)
-	index2 := randSource.Intn(size)
-	// Make sure the two nodes are different
-	for index1 == index2 {
-		index2 = rand.Intn(size)
+	c.Lock.Lock()
+	balancer := c.Balancer
+	info.Exclude = append(slices.Clone(info.Exclude), c.Breaker.Blocked()...)
+	c.Lock.Unlock()
+	instance, err := balancer.Pick(info)
+	if err != nil {
+		return instance, balancer, errors.New("fail to get service url")
 	}
-	if c.AddressList[index1].Load < c.AddressList[index2].Load {
-		c.AddressList[index1].Load++
-		return c.AddressList[index1].Address, nil
+	return instance, balancer, nil
+}
+
+/*
+ * GetAddress gets an address using the load balancer
+ * @return string - The address to use
+ * @return error - An error if no address is available
+ */
+func (c *HttpClient) GetAddress() (string, error) {
+	instance, balancer, err := c.Pick(PickInfo{})
+	if err != nil {
+		return "", err
 	}
-	c.AddressList[index2].Load++
-	return c.AddressList[index2].Address, nil
+	balancer.Done(instance, nil)
+	return instance.Address(), nil
 }
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+/**
+ * @Description: Function generating the id of a request, the ids must be unique among the requests in flight
+ * @Return any: Request ID, a string or a number
+ */
+type IdGenerator func() any
+
+/**
+ * @Description: Generator used when the options set none
+ */
+var defaultIdGenerator = NewCounterIdGenerator()
+
+/**
+ * @Description: Create a generator counting from 1, the ids are strings
+ * @Return IdGenerator: Id generator
+ */
+func NewCounterIdGenerator() IdGenerator {
+	var counter atomic.Uint64
+	return func() any {
+		return strconv.FormatUint(counter.Add(1), 10)
+	}
+}
+
+/**
+ * @Description: Generate a random (version 4) UUID
+ * @Return any: Request ID string
+ */
+func UUIDIdGenerator() any {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+/**
+ * @Description: Get the generator to use
+ * @Param g: Generator set in the options
+ * @Return IdGenerator: The generator, the default counter when nil
+ */
+func idGenerator(g IdGenerator) IdGenerator {
+	if g == nil {
+		return defaultIdGenerator
+	}
+	return g
+}
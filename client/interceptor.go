@@ -0,0 +1,215 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+type contextKey int
+
+const requestKey contextKey = iota
+
+/**
+ * @Description: Function sending a single call
+ * @Param ctx: Context of the call
+ * @Param method: Method name without the service name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Return error: Error message
+ */
+type Invoker func(ctx context.Context, method string, params any, result any) error
+
+/**
+ * @Description: Function wrapping a single call, it may act before and after calling invoker or skip it
+ * @Param ctx: Context of the call, carrying the outgoing Request
+ * @Param method: Method name without the service name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param invoker: Next interceptor or the transport
+ * @Return error: Error message
+ */
+type Interceptor func(ctx context.Context, method string, params any, result any, invoker Invoker) error
+
+/**
+ * @Description: Function sending a batch call
+ * @Param ctx: Context of the call
+ * @Param requests: Batch request list
+ * @Return error: Error message
+ */
+type BatchInvoker func(ctx context.Context, requests []*common.SingleRequest) error
+
+/**
+ * @Description: Function wrapping a batch call, it may act before and after calling invoker or skip it
+ * @Param ctx: Context of the call, carrying the outgoing Request
+ * @Param requests: Batch request list
+ * @Param invoker: Next interceptor or the transport
+ * @Return error: Error message
+ */
+type BatchInterceptor func(ctx context.Context, requests []*common.SingleRequest, invoker BatchInvoker) error
+
+/**
+ * @Description: Outgoing request seen by the interceptors
+ * @Field Id: Request ID, nil for notifications
+ * @Field Body: JSON-RPC request data, sent as is when an interceptor replaces it, otherwise encoded again from the method and params reaching the transport
+ * @Field Header: HTTP request headers, nil for transports without headers
+ */
+type Request struct {
+	Id      any
+	Body    []byte
+	Header  http.Header
+	encoded []byte
+}
+
+/**
+ * @Description: Get the outgoing request stored in the context
+ * @Param ctx: Context passed to an interceptor
+ * @Return *Request: Outgoing request
+ * @Return bool: Whether the context carries a request
+ */
+func RequestFromContext(ctx context.Context) (*Request, bool) {
+	r, ok := ctx.Value(requestKey).(*Request)
+	return r, ok
+}
+
+/**
+ * @Description: Get the body to send, the caller encodes it again when no interceptor replaced it
+ * @Receiver r: Request structure pointer
+ * @Param encode: Function encoding the body from the arguments reaching the transport
+ * @Return []byte: Request data
+ */
+func (r *Request) body(encode func() []byte) []byte {
+	if !bytes.Equal(r.Body, r.encoded) {
+		return r.Body
+	}
+	r.Body = encode()
+	r.encoded = r.Body
+	return r.Body
+}
+
+/**
+ * @Description: Check whether the server answers a request
+ * @Param result: Result, nil for a notification, or the batch request list
+ * @Return bool: False for a notification and for a batch of notifications
+ */
+func expectsResponse(result any) bool {
+	if result == nil {
+		return false
+	}
+	if requests, ok := result.([]*common.SingleRequest); ok {
+		return slices.ContainsFunc(requests, func(r *common.SingleRequest) bool {
+			return !r.IsNotify
+		})
+	}
+	return true
+}
+
+/**
+ * @Description: Run a single call through the interceptors
+ * @Param ctx: Context of the call
+ * @Param interceptors: Interceptors, the first one is the outermost
+ * @Param ids: Request id generator
+ * @Param name: Service name
+ * @Param header: HTTP request headers, nil for transports without headers
+ * @Param method: Method name without the service name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param isNotify: Whether it's a notification
+ * @Param send: Function sending the request data
+ * @Return error: Error message
+ */
+func intercept(ctx context.Context, interceptors []Interceptor, ids IdGenerator, name string, header http.Header, method string, params any, result any, isNotify bool,
+	send func(ctx context.Context, info PickInfo, methods []string, b []byte, result any) error) error {
+	var id any
+	if !isNotify {
+		id = ids()
+	}
+	encode := func(method string, params any) []byte {
+		return common.JsonRs(id, qualify(name, method), params)
+	}
+	req := &Request{Id: id, Header: header}
+	req.Body = encode(method, params)
+	req.encoded = req.Body
+	var invoker Invoker = func(ctx context.Context, method string, params any, result any) error {
+		b := req.body(func() []byte {
+			return encode(method, params)
+		})
+		// A nil result tells the transport not to wait for a response.
+		if isNotify {
+			result = nil
+		} else if result == nil {
+			result = new(any)
+		}
+		return send(ctx, PickInfo{Method: method, Params: params}, []string{method}, b, result)
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, method string, params any, result any) error {
+			return interceptor(ctx, method, params, result, next)
+		}
+	}
+	return invoker(context.WithValue(ctx, requestKey, req), method, params, result)
+}
+
+/**
+ * @Description: Run a batch call through the batch interceptors
+ * @Param ctx: Context of the call
+ * @Param interceptors: Batch interceptors, the first one is the outermost
+ * @Param ids: Request id generator
+ * @Param name: Service name
+ * @Param header: HTTP request headers, nil for transports without headers
+ * @Param requests: Batch request list
+ * @Param send: Function sending the request data
+ * @Return error: Error message
+ */
+func interceptBatch(ctx context.Context, interceptors []BatchInterceptor, ids IdGenerator, name string, header http.Header, requests []*common.SingleRequest,
+	send func(ctx context.Context, info PickInfo, methods []string, b []byte, result any) error) error {
+	encode := func(requests []*common.SingleRequest) []byte {
+		var br []any
+		for _, v := range requests {
+			method := qualify(name, v.Method)
+			if v.IsNotify {
+				br = append(br, common.Rs(nil, method, v.Params))
+				continue
+			}
+			// Every request gets its own id, the responses are matched by id.
+			if v.Id == nil {
+				v.Id = ids()
+			}
+			br = append(br, common.Rs(v.Id, method, v.Params))
+		}
+		return common.JsonBatchRs(br)
+	}
+	req := &Request{Header: header}
+	req.Body = encode(requests)
+	req.encoded = req.Body
+	var invoker BatchInvoker = func(ctx context.Context, requests []*common.SingleRequest) error {
+		b := req.body(func() []byte {
+			return encode(requests)
+		})
+		return send(ctx, batchPickInfo(requests), batchMethods(requests), b, requests)
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, requests []*common.SingleRequest) error {
+			return interceptor(ctx, requests, next)
+		}
+	}
+	return invoker(context.WithValue(ctx, requestKey, req), requests)
+}
+
+/**
+ * @Description: Get the method name sent to the server
+ * @Param name: Service name, empty for methods registered without a service prefix
+ * @Param method: Method name
+ * @Return string: Method name prefixed by the service name
+ */
+func qualify(name string, method string) string {
+	if name == "" {
+		return method
+	}
+	return name + "/" + method
+}
@@ -0,0 +1,365 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/**
+ * @Description: Connection carrying whole messages in both directions
+ */
+type streamConn interface {
+	/**
+	 * @Description: Read the next message
+	 * @Return []byte: Message data
+	 * @Return error: Error message, the connection is unusable afterwards
+	 */
+	ReadMessage() ([]byte, error)
+
+	/**
+	 * @Description: Write a message, safe for concurrent use
+	 * @Param ctx: Context whose deadline bounds the write
+	 * @Param b: Message data
+	 * @Return error: Error message
+	 */
+	WriteMessage(ctx context.Context, b []byte) error
+
+	/**
+	 * @Description: Close the connection
+	 * @Return error: Error message
+	 */
+	Close() error
+}
+
+/**
+ * @Description: Sends concurrent calls over one connection, matching the responses by id, and delivers subscription notifications
+ * @Field dial: Function opening the connection, called by the first call and after the connection drops
+ * @Field mu: Lock protecting the fields below
+ * @Field conn: Open connection, nil until dialed
+ * @Field pending: Calls waiting for a response, by id
+ * @Field subscriptions: Subscriptions receiving notifications, by subscription id
+ * @Field closed: Whether the multiplexer is closed
+ */
+type multiplexer struct {
+	dial          func(ctx context.Context) (streamConn, error)
+	mu            sync.Mutex
+	conn          streamConn
+	pending       map[string]*muxCall
+	subscriptions map[string]*Subscription
+	closed        bool
+}
+
+/**
+ * @Description: Call waiting for its response
+ * @Field conn: Connection the request was written to
+ * @Field keys: Keys of the request ids
+ * @Field subscription: Subscription registered from the response, nil for other calls
+ * @Field response: Channel receiving the response, closed when the connection is lost
+ */
+type muxCall struct {
+	conn         streamConn
+	keys         []string
+	subscription *Subscription
+	response     chan []byte
+}
+
+/**
+ * @Description: Create a multiplexer
+ * @Param dial: Function opening the connection
+ * @Return *multiplexer: Multiplexer
+ */
+func newMultiplexer(dial func(ctx context.Context) (streamConn, error)) *multiplexer {
+	return &multiplexer{
+		dial:          dial,
+		pending:       make(map[string]*muxCall),
+		subscriptions: make(map[string]*Subscription),
+	}
+}
+
+/**
+ * @Description: Send request data and wait for the response matching its ids
+ * @Receiver m: Multiplexer structure pointer
+ * @Param ctx: Context controlling the request
+ * @Param b: Request data
+ * @Param result: Result, nil for notifications, a *subscribeResult for subscriptions
+ * @Return error: Error message
+ */
+func (m *multiplexer) send(ctx context.Context, b []byte, result any) error {
+	var (
+		call *muxCall
+		err  error
+	)
+	// A connection dropped while idle is noticed by the write, reconnect once.
+	for i := 0; i < 2; i++ {
+		var conn streamConn
+		conn, err = m.connect(ctx)
+		if err != nil {
+			return ContextError(ctx, err)
+		}
+		if expectsResponse(result) {
+			call, err = m.register(conn, b, result)
+			if err != nil {
+				return err
+			}
+		}
+		err = conn.WriteMessage(ctx, b)
+		if err == nil {
+			break
+		}
+		m.drop(conn)
+		if ctx.Err() != nil {
+			return ContextError(ctx, err)
+		}
+	}
+	if err != nil || call == nil {
+		return ContextError(ctx, err)
+	}
+	select {
+	case data, ok := <-call.response:
+		if !ok {
+			return ErrConnectionLost
+		}
+		return common.GetResult(data, result)
+	case <-ctx.Done():
+		m.unregister(call)
+		return ContextError(ctx, ctx.Err())
+	}
+}
+
+/**
+ * @Description: Get the open connection or dial a new one
+ * @Receiver m: Multiplexer structure pointer
+ * @Param ctx: Context controlling the dial
+ * @Return streamConn: Connection
+ * @Return error: Error message
+ */
+func (m *multiplexer) connect(ctx context.Context) (streamConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClientClosed
+	}
+	if m.conn != nil {
+		return m.conn, nil
+	}
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.conn = conn
+	go m.read(conn)
+	return conn, nil
+}
+
+/**
+ * @Description: Register a call waiting for the response to its ids
+ * @Receiver m: Multiplexer structure pointer
+ * @Param conn: Connection the request is written to
+ * @Param b: Request data
+ * @Param result: Result of the call
+ * @Return *muxCall: Call waiting for its response
+ * @Return error: Error when an id is already waiting for a response
+ */
+func (m *multiplexer) register(conn streamConn, b []byte, result any) (*muxCall, error) {
+	call := &muxCall{conn: conn, keys: messageIds(b), response: make(chan []byte, 1)}
+	if r, ok := result.(*subscribeResult); ok {
+		call.subscription = r.subscription
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range call.keys {
+		if _, ok := m.pending[key]; ok {
+			return nil, errors.New("jsonrpc4go: request id already waiting for a response: " + key)
+		}
+	}
+	for _, key := range call.keys {
+		m.pending[key] = call
+	}
+	return call, nil
+}
+
+/**
+ * @Description: Stop waiting for the response of a call
+ * @Receiver m: Multiplexer structure pointer
+ * @Param call: Call
+ */
+func (m *multiplexer) unregister(call *muxCall) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range call.keys {
+		if m.pending[key] == call {
+			delete(m.pending, key)
+		}
+	}
+}
+
+/**
+ * @Description: Read the messages of a connection until it is lost
+ * @Receiver m: Multiplexer structure pointer
+ * @Param conn: Connection
+ */
+func (m *multiplexer) read(conn streamConn) {
+	defer m.drop(conn)
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			common.Debug(err.Error())
+			return
+		}
+		m.deliver(conn, data)
+	}
+}
+
+/**
+ * @Description: Hand a notification to its subscription, or a response to the call waiting for its ids
+ * @Receiver m: Multiplexer structure pointer
+ * @Param conn: Connection the message was read from
+ * @Param data: Message data
+ */
+func (m *multiplexer) deliver(conn streamConn, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, result, ok := parseNotification(data); ok {
+		if s := m.subscriptions[id]; s != nil && !s.push(result) {
+			// The subscriber does not keep up, the subscription is ended rather than blocking the other calls.
+			delete(m.subscriptions, id)
+			go s.cancel(ErrSubscriptionOverflow)
+		}
+		return
+	}
+	var call *muxCall
+	for _, key := range messageIds(data) {
+		if call = m.pending[key]; call != nil {
+			break
+		}
+	}
+	if call == nil {
+		// An error response to a request which could not be parsed has a null id,
+		// it can only be matched when a single call is waiting on the connection.
+		calls := m.waiting(conn)
+		if len(calls) != 1 {
+			common.Debug("unmatched response: " + string(data))
+			return
+		}
+		call = calls[0]
+	}
+	for _, key := range call.keys {
+		delete(m.pending, key)
+	}
+	if s := call.subscription; s != nil {
+		// The subscription is registered before the next message is read, the notifications follow the response.
+		if id, ok := subscriptionId(data); ok {
+			s.ID = id
+			s.conn = conn
+			m.subscriptions[id] = s
+		}
+	}
+	call.response <- data
+}
+
+/**
+ * @Description: Forget a lost connection, the calls waiting on it fail, its subscriptions end and the next call reconnects
+ * @Receiver m: Multiplexer structure pointer
+ * @Param conn: Connection
+ */
+func (m *multiplexer) drop(conn streamConn) {
+	conn.Close()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.conn == conn {
+		m.conn = nil
+	}
+	for _, call := range m.waiting(conn) {
+		for _, key := range call.keys {
+			delete(m.pending, key)
+		}
+		close(call.response)
+	}
+	for id, s := range m.subscriptions {
+		if s.conn == conn {
+			delete(m.subscriptions, id)
+			s.end(ErrConnectionLost)
+		}
+	}
+}
+
+/**
+ * @Description: Get the calls waiting for a response on a connection, the lock must be held
+ * @Receiver m: Multiplexer structure pointer
+ * @Param conn: Connection
+ * @Return []*muxCall: Distinct calls
+ */
+func (m *multiplexer) waiting(conn streamConn) []*muxCall {
+	var calls []*muxCall
+	for _, call := range m.pending {
+		if call.conn == conn && !slices.Contains(calls, call) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+/**
+ * @Description: Forget a subscription
+ * @Receiver m: Multiplexer structure pointer
+ * @Param s: Subscription
+ */
+func (m *multiplexer) forget(s *Subscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.subscriptions[s.ID] == s {
+		delete(m.subscriptions, s.ID)
+	}
+}
+
+/**
+ * @Description: Close the connection, calls waiting for a response fail with ErrConnectionLost
+ * @Receiver m: Multiplexer structure pointer
+ * @Return error: Error message
+ */
+func (m *multiplexer) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	conn := m.conn
+	m.mu.Unlock()
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+/**
+ * @Description: Get the keys of the ids of a request or a response message, notifications have none
+ * @Param b: Message data, an object or a batch array
+ * @Return []string: Compact JSON encodings of the non-null ids
+ */
+func messageIds(b []byte) []string {
+	var messages []map[string]json.RawMessage
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		json.Unmarshal(b, &messages)
+	} else {
+		var m map[string]json.RawMessage
+		json.Unmarshal(b, &m)
+		messages = append(messages, m)
+	}
+	keys := make([]string, 0, len(messages))
+	for _, m := range messages {
+		id, ok := m["id"]
+		if !ok {
+			continue
+		}
+		var key bytes.Buffer
+		if json.Compact(&key, id) != nil || key.String() == "null" {
+			continue
+		}
+		keys = append(keys, key.String())
+	}
+	return keys
+}
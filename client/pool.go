@@ -1,13 +1,12 @@
 package client
 
 import (
-	"errors"
+	"bufio"
+	"context"
 	"log"
 	"net"
-	"strings"
-	"sync"
-
 	"slices"
+	"sync"
 
 	"github.com/sunquakes/jsonrpc4go/discovery"
 )
@@ -22,26 +21,48 @@ type PoolOptions struct {
 	MaxActive int
 }
 
+/**
+ * @Description: Pooled connection structure
+ * @Field Conn: Network connection
+ * @Field Instance: Instance the connection is dialed to
+ * @Field reader: Buffered reader of the connection, created by the first read
+ */
+type Conn struct {
+	net.Conn
+	Instance discovery.Instance
+	reader   *bufio.Reader
+}
+
 /**
  * @Description: Main connection pool structure
  * @Field Name: Service name
  * @Field Discovery: Service discovery driver
  * @Field Address: Service address
- * @Field ActiveAddressList: Active address list
+ * @Field ActiveInstances: Active instance list
  * @Field Lock: Mutex lock
  * @Field Options: Connection pool options
+ * @Field Balancer: Load balancer picking the instance of every borrowed connection
+ * @Field Breaker: Circuit breaker rejecting the instances failing too often
  * @Field ActiveTotal: Total number of active connections
- * @Field Conns: Connection channel
+ * @Field Idle: Idle connections per address
+ * @Field Changed: Channel closed when a connection is released or removed
+ * @Field StopWatch: Function stopping the discovery watch, nil when not watching
+ * @Field Network: Network dialed, tcp or unix
  */
 type Pool struct {
-	Name              string
-	Discovery         discovery.Driver
-	Address           string
-	ActiveAddressList []string
-	Lock              sync.Mutex
-	Options           PoolOptions
-	ActiveTotal       int
-	Conns             chan net.Conn
+	Name            string
+	Discovery       discovery.Driver
+	Address         string
+	ActiveInstances []discovery.Instance
+	Lock            sync.Mutex
+	Options         PoolOptions
+	Balancer        Balancer
+	Breaker         *CircuitBreaker
+	ActiveTotal     int
+	Idle            map[string][]*Conn
+	Changed         chan struct{}
+	StopWatch       func()
+	Network         string
 }
 
 /**
@@ -53,112 +74,235 @@ type Pool struct {
  * @Return *Pool: Connection pool instance pointer
  */
 func NewPool(name, address string, dc discovery.Driver, option PoolOptions) *Pool {
-	ch := make(chan net.Conn, option.MaxActive)
+	return newPool(name, "tcp", address, dc, option)
+}
+
+/**
+ * @Description: Create a new connection pool instance dialing a network
+ * @Param name: Service name
+ * @Param network: Network dialed, tcp or unix
+ * @Param address: Service address, comma separated socket paths for unix
+ * @Param dc: Service discovery driver
+ * @Param option: Connection pool options
+ * @Return *Pool: Connection pool instance pointer
+ */
+func newPool(name, network, address string, dc discovery.Driver, option PoolOptions) *Pool {
 	pool := &Pool{
-		Name:              name,
-		Discovery:         dc,
-		Address:           address,
-		ActiveAddressList: nil,
-		Lock:              sync.Mutex{},
-		Options:           option,
-		ActiveTotal:       0,
-		Conns:             ch,
+		Name:            name,
+		Discovery:       dc,
+		Address:         address,
+		ActiveInstances: nil,
+		Lock:            sync.Mutex{},
+		Options:         option,
+		Balancer:        NewRoundRobinBalancer(),
+		Breaker:         NewCircuitBreaker(BreakerOptions{}),
+		ActiveTotal:     0,
+		Idle:            make(map[string][]*Conn),
+		Changed:         make(chan struct{}),
+		Network:         network,
 	}
+	pool.Lock.Lock()
 	pool.ActiveAddress()
+	pool.Lock.Unlock()
+	pool.Watch()
 	pool.Lock.Lock()
 	defer pool.Lock.Unlock()
 	for i := 0; i < option.MinIdle; i++ {
 		conn, err := pool.Create()
 		if err == nil {
 			pool.ActiveTotal++
-			pool.Conns <- conn
+			pool.done(conn.Instance, nil)
+			address := conn.Instance.Address()
+			pool.Idle[address] = append(pool.Idle[address], conn)
 		}
 	}
 	return pool
 }
 
 /**
- * @Description: Get active address list
+ * @Description: Get active instance list, the caller must hold the lock
  * @Receiver p: Pool structure pointer
- * @Return int: Number of active addresses
+ * @Return int: Number of active instances
  * @Return error: Error message
  */
 func (p *Pool) ActiveAddress() (int, error) {
 	var (
-		address string
-		err     error
+		instances []discovery.Instance
+		err       error
 	)
 	if p.Discovery != nil {
-		address, err = p.Discovery.Get(p.Name)
-		if err != nil {
-			return 0, err
-		}
+		instances, err = p.Discovery.GetInstances(p.Name)
+		instances = discovery.Healthy(instances)
+	} else if p.Network == "unix" {
+		instances = discovery.ParseUnixAddresses(p.Name, p.Address)
 	} else {
-		address = p.Address
+		instances, err = discovery.ParseAddresses(p.Name, p.Address)
 	}
-	addressList := strings.Split(address, ",")
-	p.ActiveAddressList = addressList
-	return len(addressList), nil
+	if err != nil {
+		return 0, err
+	}
+	p.setInstances(instances)
+	return len(instances), nil
 }
 
 /**
  * @Description: Get connection from connection pool
  * @Receiver p: Pool structure pointer
- * @Return net.Conn: Network connection
+ * @Return *Conn: Pooled connection
+ * @Return error: Error message
+ */
+func (p *Pool) Borrow() (*Conn, error) {
+	return p.BorrowContext(context.Background(), PickInfo{})
+}
+
+/**
+ * @Description: Get connection to the instance picked by the balancer, waiting no longer than the context allows
+ * @Receiver p: Pool structure pointer
+ * @Param ctx: Context controlling dialing and waiting for an idle connection
+ * @Param info: Call information passed to the balancer
+ * @Return *Conn: Pooled connection
+ * @Return error: Error message
+ */
+func (p *Pool) BorrowContext(ctx context.Context, info PickInfo) (*Conn, error) {
+	return p.borrow(ctx, info, false)
+}
+
+/**
+ * @Description: Get connection to the instance picked by the balancer
+ * @Receiver p: Pool structure pointer
+ * @Param ctx: Context controlling dialing and waiting for an idle connection
+ * @Param info: Call information passed to the balancer
+ * @Param fresh: Whether to dial a new connection instead of reusing an idle one
+ * @Return *Conn: Pooled connection
  * @Return error: Error message
  */
-func (p *Pool) Borrow() (net.Conn, error) {
+func (p *Pool) borrow(ctx context.Context, info PickInfo, fresh bool) (*Conn, error) {
 	p.Lock.Lock()
 	defer p.Lock.Unlock()
-	if p.ActiveTotal <= 0 {
-		return nil, errors.New("unable to connect to the server")
+	if len(p.ActiveInstances) == 0 {
+		p.ActiveAddress()
 	}
-	if p.ActiveTotal >= p.Options.MaxActive {
-		return <-p.Conns, nil
+	instance, err := p.pick(info)
+	if err != nil {
+		return nil, err
 	}
-	conn, err := p.Create()
-	if err == nil {
-		p.ActiveTotal++
+	address := instance.Address()
+	for {
+		if idle := p.Idle[address]; len(idle) > 0 && !fresh {
+			conn := idle[len(idle)-1]
+			p.Idle[address] = idle[:len(idle)-1]
+			conn.Instance = instance
+			return conn, nil
+		}
+		if p.ActiveTotal >= p.Options.MaxActive {
+			// Make room by closing an idle connection to another instance.
+			p.closeIdle(func(conn *Conn) bool {
+				return true
+			}, 1)
+		}
+		if p.ActiveTotal < p.Options.MaxActive {
+			// Reserve the slot and dial without the lock, a slow instance must not block the whole pool.
+			p.ActiveTotal++
+			p.Lock.Unlock()
+			conn, err := p.connect(ctx, instance)
+			p.Lock.Lock()
+			if err != nil {
+				p.discard()
+				p.done(instance, err)
+				return nil, err
+			}
+			return conn, nil
+		}
+		// Do not hold the lock while waiting, Release needs it to return a connection.
+		changed := p.Changed
+		p.Lock.Unlock()
+		select {
+		case <-changed:
+			p.Lock.Lock()
+		case <-ctx.Done():
+			p.Lock.Lock()
+			// The pool is saturated, the instance did nothing wrong.
+			p.cancel(instance)
+			return nil, ctx.Err()
+		}
 	}
-	return conn, err
 }
 
 /**
  * @Description: Release connection back to connection pool
  * @Receiver p: Pool structure pointer
- * @Param conn: Network connection
+ * @Param conn: Pooled connection
+ * @Param err: Error of the call, reported to the balancer
  */
-func (p *Pool) Release(conn net.Conn) {
+func (p *Pool) Release(conn *Conn, err error) {
 	p.Lock.Lock()
 	defer p.Lock.Unlock()
-	p.Conns <- conn
+	p.done(conn.Instance, err)
+	// The instance was removed from the discovery service while the connection was borrowed.
+	if !p.isActive(conn.Instance) {
+		conn.Close()
+		p.discard()
+		return
+	}
+	address := conn.Instance.Address()
+	p.Idle[address] = append(p.Idle[address], conn)
+	p.notify()
 }
 
 /**
- * @Description: Create new connection
+ * @Description: Create new connection to the instance picked by the balancer, the caller must hold the lock
  * @Receiver p: Pool structure pointer
- * @Return net.Conn: Network connection
+ * @Return *Conn: Pooled connection
+ * @Return error: Error message
+ */
+func (p *Pool) Create() (*Conn, error) {
+	return p.CreateContext(context.Background())
+}
+
+/**
+ * @Description: Create new connection to the instance picked by the balancer, dialing no longer than the context allows
+ * @Receiver p: Pool structure pointer
+ * @Param ctx: Context controlling dialing
+ * @Return *Conn: Pooled connection
  * @Return error: Error message
  */
-func (p *Pool) Create() (net.Conn, error) {
-	var err error
-	size := len(p.ActiveAddressList)
-	if size == 0 {
-		size, err = p.ActiveAddress()
-		if err != nil {
+func (p *Pool) CreateContext(ctx context.Context) (*Conn, error) {
+	if len(p.ActiveInstances) == 0 {
+		if _, err := p.ActiveAddress(); err != nil {
 			return nil, err
 		}
 	}
-	key := p.ActiveTotal % size
-	address := p.ActiveAddressList[key]
-	conn, err := p.Connect(address)
+	instance, err := p.pick(PickInfo{})
 	if err != nil {
-		p.ActiveAddressList = slices.Delete(p.ActiveAddressList, key, key+1)
-		log.Printf("Can not connect %s", address)
+		return nil, err
+	}
+	conn, err := p.connect(ctx, instance)
+	if err != nil {
+		p.done(instance, err)
 	}
 	return conn, err
 }
 
+/**
+ * @Description: Dial an instance
+ * @Receiver p: Pool structure pointer
+ * @Param ctx: Context controlling dialing
+ * @Param instance: Instance to dial
+ * @Return *Conn: Pooled connection
+ * @Return error: DialError when the instance is unreachable
+ */
+func (p *Pool) connect(ctx context.Context, instance discovery.Instance) (*Conn, error) {
+	address := instance.Address()
+	conn, err := p.ConnectContext(ctx, address)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Can not connect %s", address)
+		}
+		return nil, &DialError{address, err}
+	}
+	return &Conn{Conn: conn, Instance: instance}, nil
+}
+
 /**
  * @Description: Connect to specified address
  * @Receiver p: Pool structure pointer
@@ -167,41 +311,269 @@ func (p *Pool) Create() (net.Conn, error) {
  * @Return error: Error message
  */
 func (p *Pool) Connect(address string) (net.Conn, error) {
-	return net.Dial("tcp", address)
+	return p.ConnectContext(context.Background(), address)
+}
+
+/**
+ * @Description: Connect to specified address, dialing no longer than the context allows
+ * @Receiver p: Pool structure pointer
+ * @Param ctx: Context controlling dialing
+ * @Param address: Service address
+ * @Return net.Conn: Network connection
+ * @Return error: Error message
+ */
+func (p *Pool) ConnectContext(ctx context.Context, address string) (net.Conn, error) {
+	network := p.Network
+	if network == "" {
+		network = "tcp"
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
 }
 
 /**
  * @Description: Get new connection after removing old one
  * @Receiver p: Pool structure pointer
  * @Param conn: Old connection
- * @Return net.Conn: New connection
+ * @Param err: Error of the old connection
+ * @Return *Conn: New connection
  * @Return error: Error message
  */
-func (p *Pool) BorrowAfterRemove(conn net.Conn) (net.Conn, error) {
+func (p *Pool) BorrowAfterRemove(conn *Conn, err error) (*Conn, error) {
+	return p.BorrowAfterRemoveContext(context.Background(), conn, err, PickInfo{})
+}
+
+/**
+ * @Description: Get new connection after removing old one, dialing no longer than the context allows
+ * @Receiver p: Pool structure pointer
+ * @Param ctx: Context controlling dialing
+ * @Param conn: Old connection
+ * @Param err: Error of the old connection
+ * @Param info: Call information passed to the balancer
+ * @Return *Conn: New connection
+ * @Return error: Error message
+ */
+func (p *Pool) BorrowAfterRemoveContext(ctx context.Context, conn *Conn, err error, info PickInfo) (*Conn, error) {
+	p.Remove(conn, err)
+	// When disconnected, reconnect instead of fetch from pool.
+	return p.borrow(ctx, info, true)
+}
+
+/**
+ * @Description: Close and remove connection
+ * @Receiver p: Pool structure pointer
+ * @Param conn: Connection to remove
+ * @Param err: Error of the call, reported to the balancer
+ */
+func (p *Pool) Remove(conn *Conn, err error) {
 	p.Lock.Lock()
 	defer p.Lock.Unlock()
 	if conn != nil {
-		p.ActiveTotal--
+		conn.Close()
+		p.done(conn.Instance, err)
+		p.discard()
 	}
-	// When disconnected, reconnect instead of fetch from pool.
-	conn, err := p.Create()
-	if err == nil {
-		p.ActiveTotal++
+}
+
+/**
+ * @Description: Pick an instance whose circuit breaker lets the request through, the caller must hold the lock
+ * @Receiver p: Pool structure pointer
+ * @Param info: Call information passed to the balancer
+ * @Return discovery.Instance: Picked instance
+ * @Return error: ErrCircuitOpen when the breaker of every instance rejects the request
+ */
+func (p *Pool) pick(info PickInfo) (discovery.Instance, error) {
+	info.Exclude = append(slices.Clone(info.Exclude), p.Breaker.Blocked()...)
+	instance, err := p.Balancer.Pick(info)
+	if err != nil {
+		return instance, err
 	}
-	return conn, err
+	if !p.Breaker.Allow(instance.Address()) {
+		p.Balancer.Done(instance, ErrCircuitOpen)
+		return instance, ErrCircuitOpen
+	}
+	return instance, nil
 }
 
 /**
- * @Description: Remove connection
+ * @Description: Pick an instance whose circuit breaker lets the request through, for calls not borrowing a connection;
+ * report the end of the request with Done
  * @Receiver p: Pool structure pointer
- * @Param conn: Connection to remove
+ * @Param info: Call information passed to the balancer
+ * @Return discovery.Instance: Instance picked
+ * @Return error: ErrCircuitOpen, or the error of the balancer or the service discovery
  */
-func (p *Pool) Remove(conn net.Conn) {
+func (p *Pool) Pick(info PickInfo) (discovery.Instance, error) {
 	p.Lock.Lock()
 	defer p.Lock.Unlock()
-	if conn != nil {
-		p.ActiveTotal--
+	if len(p.ActiveInstances) == 0 {
+		if _, err := p.ActiveAddress(); err != nil {
+			return discovery.Instance{}, err
+		}
 	}
+	return p.pick(info)
+}
+
+/**
+ * @Description: Report the end of a request to an instance returned by Pick
+ * @Receiver p: Pool structure pointer
+ * @Param instance: Instance returned by Pick
+ * @Param err: Error of the request
+ */
+func (p *Pool) Done(instance discovery.Instance, err error) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.done(instance, err)
+}
+
+/**
+ * @Description: Check whether an instance address is active
+ * @Receiver p: Pool structure pointer
+ * @Param address: Instance address
+ * @Return bool: Whether an active instance has the address
+ */
+func (p *Pool) IsActive(address string) bool {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	return slices.ContainsFunc(p.ActiveInstances, func(i discovery.Instance) bool {
+		return i.Address() == address
+	})
+}
+
+/**
+ * @Description: Report the end of a request to the balancer and the circuit breaker
+ * @Receiver p: Pool structure pointer
+ * @Param instance: Instance returned by pick
+ * @Param err: Error of the request
+ */
+func (p *Pool) done(instance discovery.Instance, err error) {
+	p.Balancer.Done(instance, err)
+	p.Breaker.Report(instance.Address(), err)
+}
+
+/**
+ * @Description: Give back an instance returned by pick without sending a request, the caller must hold the lock;
+ * the balancer and the circuit breaker release it without counting a failure
+ * @Receiver p: Pool structure pointer
+ * @Param instance: Instance returned by pick
+ */
+func (p *Pool) cancel(instance discovery.Instance) {
+	p.Balancer.Done(instance, context.Canceled)
+	p.Breaker.Report(instance.Address(), context.Canceled)
+}
+
+/**
+ * @Description: Forget a closed active connection, the caller must hold the lock
+ * @Receiver p: Pool structure pointer
+ */
+func (p *Pool) discard() {
+	p.ActiveTotal--
+	p.notify()
+}
+
+/**
+ * @Description: Wake up the borrowers waiting for a connection, the caller must hold the lock
+ * @Receiver p: Pool structure pointer
+ */
+func (p *Pool) notify() {
+	close(p.Changed)
+	p.Changed = make(chan struct{})
+}
+
+/**
+ * @Description: Close idle connections, the caller must hold the lock
+ * @Receiver p: Pool structure pointer
+ * @Param match: Function selecting the connections to close
+ * @Param limit: Maximum number of connections to close, negative for no limit
+ */
+func (p *Pool) closeIdle(match func(conn *Conn) bool, limit int) {
+	for address, idle := range p.Idle {
+		kept := idle[:0]
+		for _, conn := range idle {
+			if limit != 0 && match(conn) {
+				conn.Close()
+				p.discard()
+				limit--
+				continue
+			}
+			kept = append(kept, conn)
+		}
+		if len(kept) == 0 {
+			delete(p.Idle, address)
+		} else {
+			p.Idle[address] = kept
+		}
+	}
+}
+
+/**
+ * @Description: Check whether an instance is active, the caller must hold the lock
+ * @Receiver p: Pool structure pointer
+ * @Param instance: Instance to check
+ * @Return bool: Whether the address of the instance is active
+ */
+func (p *Pool) isActive(instance discovery.Instance) bool {
+	address := instance.Address()
+	return slices.ContainsFunc(p.ActiveInstances, func(i discovery.Instance) bool {
+		return i.Address() == address
+	})
+}
+
+/**
+ * @Description: Replace the active instances, the caller must hold the lock
+ * @Receiver p: Pool structure pointer
+ * @Param instances: Healthy instances
+ */
+func (p *Pool) setInstances(instances []discovery.Instance) {
+	p.ActiveInstances = instances
+	p.Balancer.Update(instances)
+}
+
+/**
+ * @Description: Subscribe to the discovery service when it supports watching
+ * @Receiver p: Pool structure pointer
+ */
+func (p *Pool) Watch() {
+	w, ok := p.Discovery.(discovery.Watcher)
+	if !ok {
+		return
+	}
+	ch, cancel := w.Watch(p.Name)
+	p.StopWatch = cancel
+	go func() {
+		for instances := range ch {
+			p.UpdateInstances(discovery.Healthy(instances))
+		}
+	}()
+}
+
+/**
+ * @Description: Replace the active instances and close the idle connections to removed instances
+ * @Receiver p: Pool structure pointer
+ * @Param instances: Healthy instances
+ */
+func (p *Pool) UpdateInstances(instances []discovery.Instance) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.setInstances(instances)
+	p.closeIdle(func(conn *Conn) bool {
+		return !p.isActive(conn.Instance)
+	}, -1)
+}
+
+/**
+ * @Description: Stop watching the discovery service and close the idle connections
+ * @Receiver p: Pool structure pointer
+ */
+func (p *Pool) Close() {
+	if p.StopWatch != nil {
+		p.StopWatch()
+	}
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.closeIdle(func(conn *Conn) bool {
+		return true
+	}, -1)
 }
 
 /**
@@ -212,3 +584,32 @@ func (p *Pool) Remove(conn net.Conn) {
 func (p *Pool) SetOptions(options PoolOptions) {
 	p.Options = options
 }
+
+/**
+ * @Description: Set the circuit breaker
+ * @Receiver p: Pool structure pointer
+ * @Param breaker: Circuit breaker, one with the default options when nil
+ */
+func (p *Pool) SetBreaker(breaker *CircuitBreaker) {
+	if breaker == nil {
+		breaker = NewCircuitBreaker(BreakerOptions{})
+	}
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.Breaker = breaker
+}
+
+/**
+ * @Description: Set the load balancer
+ * @Receiver p: Pool structure pointer
+ * @Param balancer: Load balancer, round-robin when nil
+ */
+func (p *Pool) SetBalancer(balancer Balancer) {
+	if balancer == nil {
+		balancer = NewRoundRobinBalancer()
+	}
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	balancer.Update(p.ActiveInstances)
+	p.Balancer = balancer
+}
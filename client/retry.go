@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/**
+ * @Description: Class of errors a call may be retried on
+ */
+type RetryOn int
+
+const (
+	/**
+	 * @Description: The connection could not be established or the circuit breaker rejected it, the request was not sent so every method is retried
+	 */
+	RETRY_ON_DIAL RetryOn = 1 << iota
+	/**
+	 * @Description: The attempt timed out before the response was read
+	 */
+	RETRY_ON_TIMEOUT
+	/**
+	 * @Description: The server returned an internal error (-32603)
+	 */
+	RETRY_ON_INTERNAL_ERROR
+	/**
+	 * @Description: The connection broke after the request was sent
+	 */
+	RETRY_ON_NETWORK
+	/**
+	 * @Description: Classes retried when RetryPolicy.RetryOn is zero
+	 */
+	RETRY_ON_DEFAULT = RETRY_ON_DIAL | RETRY_ON_TIMEOUT | RETRY_ON_INTERNAL_ERROR
+)
+
+const (
+	/**
+	 * @Description: Default backoff before the first retry
+	 */
+	DEFAULT_INITIAL_BACKOFF = 100 * time.Millisecond
+	/**
+	 * @Description: Default maximum backoff between two attempts
+	 */
+	DEFAULT_MAX_BACKOFF = 2 * time.Second
+	/**
+	 * @Description: Default backoff growth factor
+	 */
+	DEFAULT_BACKOFF_MULTIPLIER = 2.0
+	/**
+	 * @Description: Default fraction of the backoff randomly removed
+	 */
+	DEFAULT_BACKOFF_JITTER = 0.2
+)
+
+/**
+ * @Description: Client retry policy, every retry fails over to an instance not tried yet when there is one
+ * @Field MaxAttempts: Maximum number of attempts including the first one, retries are disabled when lower than 2
+ * @Field InitialBackoff: Backoff before the first retry
+ * @Field MaxBackoff: Maximum backoff, unlimited when zero
+ * @Field Multiplier: Backoff growth factor between two retries
+ * @Field Jitter: Fraction of the backoff randomly removed, between 0 and 1
+ * @Field AttemptTimeout: Timeout of every attempt, only the call context applies when zero
+ * @Field RetryOn: Retried error classes, RETRY_ON_DEFAULT when zero
+ * @Field Idempotent: Methods safe to send twice, the others are only retried when the connection could not be established
+ */
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	AttemptTimeout time.Duration
+	RetryOn        RetryOn
+	Idempotent     []string
+}
+
+/**
+ * @Description: Create a retry policy with the default backoff
+ * @Param maxAttempts: Maximum number of attempts including the first one
+ * @Param idempotent: Methods safe to send twice
+ * @Return *RetryPolicy: Retry policy pointer
+ */
+func NewRetryPolicy(maxAttempts int, idempotent ...string) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: DEFAULT_INITIAL_BACKOFF,
+		MaxBackoff:     DEFAULT_MAX_BACKOFF,
+		Multiplier:     DEFAULT_BACKOFF_MULTIPLIER,
+		Jitter:         DEFAULT_BACKOFF_JITTER,
+		Idempotent:     idempotent,
+	}
+}
+
+/**
+ * @Description: Error returned when a connection to an instance could not be established
+ * @Field Address: Address of the instance
+ * @Field Err: Dial error
+ */
+type DialError struct {
+	Address string
+	Err     error
+}
+
+/**
+ * @Description: Get the error message
+ * @Receiver e: DialError structure pointer
+ * @Return string: Error message of the dial error
+ */
+func (e *DialError) Error() string {
+	return e.Err.Error()
+}
+
+/**
+ * @Description: Get the dial error
+ * @Receiver e: DialError structure pointer
+ * @Return error: Dial error
+ */
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+/**
+ * @Description: Run a call until it succeeds, fails with an error not retried or runs out of attempts
+ * @Receiver r: RetryPolicy structure pointer, a single attempt is made when nil
+ * @Param ctx: Context of the call
+ * @Param info: Call information passed to the balancer
+ * @Param methods: Methods sent by the call
+ * @Param attempt: Function making one attempt and returning the address it used
+ * @Return error: Error of the last attempt
+ */
+func (r *RetryPolicy) Do(ctx context.Context, info PickInfo, methods []string, attempt func(ctx context.Context, info PickInfo) (string, error)) error {
+	attempts := 1
+	if r != nil && r.MaxAttempts > 1 {
+		attempts = r.MaxAttempts
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(r.Backoff(i)):
+			case <-ctx.Done():
+				return ContextError(ctx, ctx.Err())
+			}
+		}
+		actx, cancel := r.attemptContext(ctx)
+		var address string
+		address, err = attempt(actx, info)
+		cancel()
+		if err == nil || i == attempts-1 || !r.Retryable(ctx, err, methods) {
+			return err
+		}
+		var de *DialError
+		if address == "" && errors.As(err, &de) {
+			address = de.Address
+		}
+		if address != "" {
+			info.Exclude = append(slices.Clone(info.Exclude), address)
+		}
+		common.Debug(err.Error())
+	}
+	return err
+}
+
+/**
+ * @Description: Get the backoff before a retry
+ * @Receiver r: RetryPolicy structure pointer
+ * @Param retry: Number of the retry, starting from 1
+ * @Return time.Duration: Backoff with jitter
+ */
+func (r *RetryPolicy) Backoff(retry int) time.Duration {
+	backoff := float64(r.InitialBackoff) * math.Pow(max(r.Multiplier, 1), float64(retry-1))
+	if r.MaxBackoff > 0 {
+		backoff = min(backoff, float64(r.MaxBackoff))
+	}
+	backoff -= backoff * min(max(r.Jitter, 0), 1) * rand.Float64()
+	return time.Duration(backoff)
+}
+
+/**
+ * @Description: Check whether a failed attempt is retried
+ * @Receiver r: RetryPolicy structure pointer
+ * @Param ctx: Context of the call
+ * @Param err: Error of the attempt
+ * @Param methods: Methods sent by the call
+ * @Return bool: Whether the error belongs to a retried class and the methods allow it
+ */
+func (r *RetryPolicy) Retryable(ctx context.Context, err error, methods []string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	retryOn := r.RetryOn
+	if retryOn == 0 {
+		retryOn = RETRY_ON_DEFAULT
+	}
+	var (
+		oe *net.OpError
+		ne net.Error
+		ce *common.Error
+		se *StatusError
+	)
+	if errors.As(err, &oe) && oe.Op == "dial" || errors.Is(err, ErrCircuitOpen) {
+		return retryOn&RETRY_ON_DIAL != 0
+	}
+	for _, method := range methods {
+		if !slices.Contains(r.Idempotent, method) {
+			return false
+		}
+	}
+	switch {
+	case errors.Is(err, ErrTimeout), errors.As(err, &ne) && ne.Timeout():
+		return retryOn&RETRY_ON_TIMEOUT != 0
+	case errors.As(err, &ce):
+		return ce.Code == common.InternalError && retryOn&RETRY_ON_INTERNAL_ERROR != 0
+	case errors.As(err, &ne), errors.As(err, &se), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return retryOn&RETRY_ON_NETWORK != 0
+	}
+	return false
+}
+
+/**
+ * @Description: Get the context of an attempt
+ * @Receiver r: RetryPolicy structure pointer
+ * @Param ctx: Context of the call
+ * @Return context.Context: Context bounded by the attempt timeout
+ * @Return context.CancelFunc: Function releasing the context
+ */
+func (r *RetryPolicy) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r == nil || r.AttemptTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.AttemptTimeout)
+}
+
+/**
+ * @Description: Get the methods of a batch
+ * @Param requests: Batch request list
+ * @Return []string: Method names without the service name
+ */
+func batchMethods(requests []*common.SingleRequest) []string {
+	methods := make([]string, 0, len(requests))
+	for _, request := range requests {
+		methods = append(methods, request.Method)
+	}
+	return methods
+}
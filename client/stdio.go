@@ -0,0 +1,271 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/**
+ * @Description: Basic configuration structure for stdio client
+ * @Field Name: Service name
+ * @Field Reader: Reader receiving the responses, the stdout of a child process; os.Stdin when nil
+ * @Field Writer: Writer sending the requests, the stdin of a child process; os.Stdout when nil
+ */
+type Stdio struct {
+	Name   string
+	Reader io.Reader
+	Writer io.Writer
+}
+
+/**
+ * @Description: Main structure for stdio client, concurrent calls share the pipes and are matched by id
+ * @Field Name: Service name
+ * @Field RequestList: Request list for batch calls
+ * @Field Options: Stdio client options
+ * @Field Interceptors: Interceptors wrapping single calls, the first one is the outermost
+ * @Field BatchInterceptors: Interceptors wrapping batch calls, the first one is the outermost
+ */
+type StdioClient struct {
+	Name              string
+	RequestList       []*common.SingleRequest
+	Options           StdioOptions
+	Interceptors      []Interceptor
+	BatchInterceptors []BatchInterceptor
+	conn              *common.PipeConn
+	mux               *multiplexer
+	mu                sync.Mutex
+	dialed            bool
+}
+
+/**
+ * @Description: Options structure for stdio client
+ * @Field PackageEof: Packet end delimiter, used by the default framer
+ * @Field PackageMaxLength: Maximum packet length, a larger response fails the call and closes the pipes
+ * @Field Framer: Framer splitting the stream into packages, a delimiter framer with PackageEof when nil,
+ * it must match the framer of the server
+ * @Field IdGenerator: Request id generator, a counter shared by the clients when nil
+ */
+type StdioOptions struct {
+	PackageEof       string
+	PackageMaxLength int64
+	Framer           Framer
+	IdGenerator      IdGenerator
+}
+
+/**
+ * @Description: Create a new stdio client
+ * @Receiver p: Stdio structure pointer
+ * @Return Client: Client interface
+ */
+func (p *Stdio) NewClient() Client {
+	var (
+		r io.Reader = os.Stdin
+		w io.Writer = os.Stdout
+	)
+	if p.Reader != nil {
+		r = p.Reader
+	}
+	if p.Writer != nil {
+		w = p.Writer
+	}
+	return NewStdioClient(p.Name, r, w)
+}
+
+/**
+ * @Description: Create a new StdioClient instance talking over a pair of pipes, e.g. those of a child process
+ * @Param name: Service name
+ * @Param r: Reader receiving the responses
+ * @Param w: Writer sending the requests
+ * @Return *StdioClient: StdioClient instance pointer
+ */
+func NewStdioClient(name string, r io.Reader, w io.Writer) *StdioClient {
+	c := &StdioClient{
+		Name: name,
+		Options: StdioOptions{
+			PackageEof:       "\r\n",
+			PackageMaxLength: 1024 * 1024 * 2,
+		},
+		conn: common.NewPipeConn(r, w),
+	}
+	c.mux = newMultiplexer(c.dial)
+	return c
+}
+
+/**
+ * @Description: Set stdio options
+ * @Receiver c: StdioClient structure pointer
+ * @Param stdioOptions: Stdio options
+ */
+func (c *StdioClient) SetOptions(stdioOptions any) {
+	c.Options = stdioOptions.(StdioOptions)
+}
+
+/**
+ * @Description: Set connection pool options, a stdio client uses a single pair of pipes
+ * @Receiver c: StdioClient structure pointer
+ * @Param poolOption: Connection pool options, ignored
+ */
+func (c *StdioClient) SetPoolOptions(poolOption any) {
+}
+
+/**
+ * @Description: Batch add requests
+ * @Receiver c: StdioClient structure pointer
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param isNotify: Whether it's a notification
+ * @Return *error: Error pointer
+ */
+func (c *StdioClient) BatchAppend(method string, params any, result any, isNotify bool) *error {
+	singleRequest := &common.SingleRequest{
+		Method:   method,
+		Params:   params,
+		Result:   result,
+		Error:    new(error),
+		IsNotify: isNotify,
+	}
+	c.RequestList = append(c.RequestList, singleRequest)
+	return singleRequest.Error
+}
+
+/**
+ * @Description: Execute batch requests
+ * @Receiver c: StdioClient structure pointer
+ * @Return error: Error message
+ */
+func (c *StdioClient) BatchCall() error {
+	return c.BatchCallContext(context.Background())
+}
+
+/**
+ * @Description: Execute batch requests bound to a context
+ * @Receiver c: StdioClient structure pointer
+ * @Param ctx: Context controlling the batch call
+ * @Return error: Error message
+ */
+func (c *StdioClient) BatchCallContext(ctx context.Context) error {
+	requests := c.RequestList
+	c.RequestList = make([]*common.SingleRequest, 0)
+	return c.BatchCallRequests(ctx, requests)
+}
+
+/**
+ * @Description: Execute a list of requests as one batch bound to a context, without the batch list
+ * @Receiver c: StdioClient structure pointer
+ * @Param ctx: Context controlling the batch call
+ * @Param requests: Requests, their results and errors are filled in
+ * @Return error: Error message
+ */
+func (c *StdioClient) BatchCallRequests(ctx context.Context, requests []*common.SingleRequest) error {
+	return interceptBatch(ctx, c.BatchInterceptors, idGenerator(c.Options.IdGenerator), c.Name, nil, requests, c.send)
+}
+
+/**
+ * @Description: Call a method
+ * @Receiver c: StdioClient structure pointer
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param isNotify: Whether it's a notification
+ * @Return error: Error message
+ */
+func (c *StdioClient) Call(method string, params any, result any, isNotify bool) error {
+	return c.CallContext(context.Background(), method, params, result, isNotify)
+}
+
+/**
+ * @Description: Call a method bound to a context
+ * @Receiver c: StdioClient structure pointer
+ * @Param ctx: Context controlling the call
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param isNotify: Whether it's a notification
+ * @Return error: Error message
+ */
+func (c *StdioClient) CallContext(ctx context.Context, method string, params any, result any, isNotify bool) error {
+	return intercept(ctx, c.Interceptors, idGenerator(c.Options.IdGenerator), c.Name, nil, method, params, result, isNotify, c.send)
+}
+
+/**
+ * @Description: Append interceptors wrapping single calls
+ * @Receiver c: StdioClient structure pointer
+ * @Param interceptors: Interceptors, the first one added is the outermost
+ */
+func (c *StdioClient) Use(interceptors ...Interceptor) {
+	c.Interceptors = append(c.Interceptors, interceptors...)
+}
+
+/**
+ * @Description: Append interceptors wrapping batch calls
+ * @Receiver c: StdioClient structure pointer
+ * @Param interceptors: Interceptors, the first one added is the outermost
+ */
+func (c *StdioClient) UseBatch(interceptors ...BatchInterceptor) {
+	c.BatchInterceptors = append(c.BatchInterceptors, interceptors...)
+}
+
+/**
+ * @Description: Close the pipes, calls waiting for a response fail with ErrConnectionLost
+ * @Receiver c: StdioClient structure pointer
+ * @Return error: Error message
+ */
+func (c *StdioClient) Close() error {
+	c.mux.Close()
+	return c.conn.Close()
+}
+
+/**
+ * @Description: Call a method returning a subscription id and receive its notifications
+ * @Receiver c: StdioClient structure pointer
+ * @Param ctx: Context controlling the subscribing call
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Return *Subscription: Subscription, ended with ErrConnectionLost when the pipes are closed
+ * @Return error: Error message, an *Error for error responses
+ */
+func (c *StdioClient) Subscribe(ctx context.Context, method string, params any) (*Subscription, error) {
+	return subscribe(ctx, c.mux, c.Interceptors, idGenerator(c.Options.IdGenerator), c.Name, method, params)
+}
+
+/**
+ * @Description: Send request data and wait for the response matching its ids
+ * @Receiver c: StdioClient structure pointer
+ * @Param ctx: Context controlling the request
+ * @Param info: Call information
+ * @Param methods: Methods sent by the request
+ * @Param b: Request data
+ * @Param result: Result, nil for notifications
+ * @Return error: Error message
+ */
+func (c *StdioClient) send(ctx context.Context, info PickInfo, methods []string, b []byte, result any) error {
+	return c.mux.send(ctx, b, result)
+}
+
+/**
+ * @Description: Start reading the pipes, they cannot be reopened once lost
+ * @Receiver c: StdioClient structure pointer
+ * @Param ctx: Context controlling the dial, unused
+ * @Return streamConn: Connection
+ * @Return error: ErrConnectionLost after the pipes were lost
+ */
+func (c *StdioClient) dial(ctx context.Context) (streamConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dialed {
+		return nil, ErrConnectionLost
+	}
+	c.dialed = true
+	return &tcpStream{
+		conn:   c.conn,
+		reader: bufio.NewReader(c.conn),
+		framer: TcpOptions{PackageEof: c.Options.PackageEof, Framer: c.Options.Framer}.framer(),
+		max:    c.Options.PackageMaxLength,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/**
+ * @Description: Number of notifications a subscription holds before the subscriber reads them
+ */
+const SUBSCRIPTION_BUFFER = 128
+
+/**
+ * @Description: Time allowed to send the unsubscribe request of a subscription ended by the client itself
+ */
+const UNSUBSCRIBE_TIMEOUT = 5 * time.Second
+
+/**
+ * @Description: Error ending a subscription whose subscriber does not read the notifications fast enough
+ */
+var ErrSubscriptionOverflow = errors.New("jsonrpc4go: subscription buffer overflow")
+
+/**
+ * @Description: Error returned when the client cannot receive notifications, only tcp, ws, wss, unix and stdio clients can
+ */
+var ErrSubscriptionsUnsupported = errors.New("jsonrpc4go: subscriptions need a tcp, ws, wss, unix or stdio client")
+
+/**
+ * @Description: Client receiving subscription notifications, implemented by the tcp and WebSocket clients
+ */
+type Subscriber interface {
+	/**
+	 * @Description: Call a method returning a subscription id and receive its notifications
+	 * @Param ctx: Context controlling the subscribing call
+	 * @Param method: Method name
+	 * @Param params: Parameters
+	 * @Return *Subscription: Subscription
+	 * @Return error: Error message, an *Error for error responses
+	 */
+	Subscribe(ctx context.Context, method string, params any) (*Subscription, error)
+}
+
+/**
+ * @Description: Subscription receiving the notifications sent by the server
+ * @Field ID: Subscription id returned by the server
+ * @Field C: Channel receiving the result of each notification, closed when the subscription ends
+ */
+type Subscription struct {
+	ID          string
+	C           <-chan json.RawMessage
+	ch          chan json.RawMessage
+	conn        streamConn
+	mux         *multiplexer
+	unsubscribe func(ctx context.Context) error
+	once        sync.Once
+	done        chan struct{}
+	err         error
+}
+
+/**
+ * @Description: Result of a subscribing call, the subscription is registered when its response is read
+ * @Field subscription: Subscription
+ */
+type subscribeResult struct {
+	subscription *Subscription
+}
+
+/**
+ * @Description: Check the result of a subscribing call, the id itself is set by the connection reader
+ * @Receiver r: subscribeResult structure pointer
+ * @Param b: Result data
+ * @Return error: Error when the result is not a subscription id
+ */
+func (r *subscribeResult) UnmarshalJSON(b []byte) error {
+	var id string
+	return json.Unmarshal(b, &id)
+}
+
+/**
+ * @Description: Call a subscribing method through a multiplexer
+ * @Param ctx: Context controlling the subscribing call
+ * @Param m: Multiplexer of the connection
+ * @Param interceptors: Interceptors wrapping the subscribing call
+ * @Param ids: Request id generator
+ * @Param name: Service name
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Return *Subscription: Subscription
+ * @Return error: Error message
+ */
+func subscribe(ctx context.Context, m *multiplexer, interceptors []Interceptor, ids IdGenerator, name string, method string, params any) (*Subscription, error) {
+	ch := make(chan json.RawMessage, SUBSCRIPTION_BUFFER)
+	s := &Subscription{C: ch, ch: ch, mux: m, done: make(chan struct{})}
+	s.unsubscribe = func(ctx context.Context) error {
+		var ok bool
+		return m.send(ctx, common.JsonRs(ids(), common.UnsubscribeMethod, []string{s.ID}), &ok)
+	}
+	err := intercept(ctx, interceptors, ids, name, nil, method, params, &subscribeResult{s}, false,
+		func(ctx context.Context, info PickInfo, methods []string, b []byte, result any) error {
+			return m.send(ctx, b, result)
+		})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+/**
+ * @Description: End the subscription and tell the server to stop sending notifications
+ * @Receiver s: Subscription structure pointer
+ * @Param ctx: Context controlling the unsubscribe call
+ * @Return error: Error message
+ */
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	s.mux.forget(s)
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	err := s.unsubscribe(ctx)
+	s.end(nil)
+	return err
+}
+
+/**
+ * @Description: Get a channel closed when the subscription ends
+ * @Receiver s: Subscription structure pointer
+ * @Return <-chan struct{}: Channel closed when the subscription ends
+ */
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+/**
+ * @Description: Get the reason the subscription ended
+ * @Receiver s: Subscription structure pointer
+ * @Return error: nil while active or after Unsubscribe, ErrConnectionLost or ErrSubscriptionOverflow otherwise
+ */
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+/**
+ * @Description: Queue the result of a notification, the multiplexer lock must be held
+ * @Receiver s: Subscription structure pointer
+ * @Param result: Result of the notification
+ * @Return bool: False when the buffer is full
+ */
+func (s *Subscription) push(result json.RawMessage) bool {
+	select {
+	case s.ch <- result:
+		return true
+	default:
+		return false
+	}
+}
+
+/**
+ * @Description: End a subscription the client gave up on and tell the server
+ * @Receiver s: Subscription structure pointer
+ * @Param err: Reason
+ */
+func (s *Subscription) cancel(err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), UNSUBSCRIBE_TIMEOUT)
+	defer cancel()
+	s.unsubscribe(ctx)
+	s.end(err)
+}
+
+/**
+ * @Description: End the subscription, once it is no longer registered with the multiplexer
+ * @Receiver s: Subscription structure pointer
+ * @Param err: Reason
+ */
+func (s *Subscription) end(err error) {
+	s.once.Do(func() {
+		s.err = err
+		close(s.ch)
+		close(s.done)
+	})
+}
+
+/**
+ * @Description: Read a subscription notification
+ * @Param data: Message data
+ * @Return string: Subscription id
+ * @Return json.RawMessage: Result of the notification
+ * @Return bool: Whether the message is a subscription notification
+ */
+func parseNotification(data []byte) (string, json.RawMessage, bool) {
+	var n struct {
+		Id     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			Subscription string          `json:"subscription"`
+			Result       json.RawMessage `json:"result"`
+		} `json:"params"`
+	}
+	if json.Unmarshal(data, &n) != nil || n.Id != nil || n.Method != common.NotificationMethod {
+		return "", nil, false
+	}
+	return n.Params.Subscription, n.Params.Result, true
+}
+
+/**
+ * @Description: Read the subscription id from the response of a subscribing call
+ * @Param data: Response data
+ * @Return string: Subscription id
+ * @Return bool: Whether the response holds a subscription id
+ */
+func subscriptionId(data []byte) (string, bool) {
+	var r struct {
+		Result *string `json:"result"`
+	}
+	if json.Unmarshal(data, &r) != nil || r.Result == nil {
+		return "", false
+	}
+	return *r.Result, true
+}
@@ -1,10 +1,12 @@
 package client
 
 import (
-	"bytes"
-	"fmt"
+	"bufio"
+	"context"
 	"net"
-	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sunquakes/jsonrpc4go/common"
@@ -14,8 +16,8 @@ import (
 /**
  * @Description: Basic configuration structure for TCP client
  * @Field Name: Service name
- * @Field Protocol: Protocol type
- * @Field Address: Service address
+ * @Field Protocol: Protocol type, tcp or unix
+ * @Field Address: Service address, comma separated socket paths for unix, abstract when starting with @
  * @Field Discovery: Service discovery driver
  */
 type Tcp struct {
@@ -34,27 +36,94 @@ type Tcp struct {
  * @Field RequestList: Request list for batch calls
  * @Field Options: TCP client options
  * @Field Pool: Connection pool
+ * @Field Interceptors: Interceptors wrapping single calls, the first one is the outermost
+ * @Field BatchInterceptors: Interceptors wrapping batch calls, the first one is the outermost
+ * @Field subscriptions: Multiplexer of the connection carrying the subscriptions, kept apart from the pool
+ * @Field multiplexers: Multiplexers of the connections shared by the calls in multiplexed mode, per instance address
+ * @Field muxMu: Lock guarding multiplexers
+ * @Field next: Counter spreading the calls over the multiplexers of an instance
  */
 type TcpClient struct {
-	Name        string
-	Protocol    string
-	Address     string
-	Discovery   discovery.Driver
-	RequestList []*common.SingleRequest
-	Options     TcpOptions
-	Pool        *Pool
+	Name              string
+	Protocol          string
+	Address           string
+	Discovery         discovery.Driver
+	RequestList       []*common.SingleRequest
+	Options           TcpOptions
+	Pool              *Pool
+	Interceptors      []Interceptor
+	BatchInterceptors []BatchInterceptor
+	subscriptions     *multiplexer
+	multiplexers      map[string][]*multiplexer
+	muxMu             sync.Mutex
+	next              atomic.Uint64
+}
+
+/**
+ * @Description: TCP connection carrying framed packages
+ * @Field conn: Network connection
+ * @Field reader: Buffered reader of the connection
+ * @Field framer: Framer of the packages
+ * @Field max: Maximum package length
+ * @Field writeMu: Lock serializing the writes
+ */
+type tcpStream struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	framer  Framer
+	max     int64
+	writeMu sync.Mutex
 }
 
 /**
  * @Description: Options structure for TCP client
  * @Field PackageEof: Packet end delimiter
- * @Field PackageMaxLength: Maximum packet length
+ * @Field PackageMaxLength: Maximum packet length, a larger response fails the call and closes the connection
+ * @Field Balancer: Load balancer, round-robin when nil
+ * @Field Retry: Retry policy, a single attempt is made when nil
+ * @Field Breaker: Circuit breaker, one with the default options when nil
+ * @Field IdGenerator: Request id generator, a counter shared by the clients when nil
+ * @Field Multiplex: Number of connections per instance shared by concurrent calls, the responses are matched by id;
+ * the instance is still picked per call by the balancer; when 0 each call borrows a pooled connection until its response is read
+ * @Field Framer: Framer splitting the stream into packages, a delimiter framer with PackageEof when nil,
+ * it must match the framer of the server
  */
 type TcpOptions struct {
 	PackageEof       string
 	PackageMaxLength int64
+	Balancer         Balancer
+	Retry            *RetryPolicy
+	Breaker          *CircuitBreaker
+	IdGenerator      IdGenerator
+	Multiplex        int
+	Framer           Framer
 }
 
+/**
+ * @Description: Framer splitting a tcp stream into packages
+ */
+type Framer = common.Framer
+
+/**
+ * @Description: Create a framer ending each package with a delimiter, the default framer
+ */
+var NewDelimiterFramer = common.NewDelimiterFramer
+
+/**
+ * @Description: Create a framer preceding each package with its size as a 4-byte big-endian integer
+ */
+var NewLengthPrefixFramer = common.NewLengthPrefixFramer
+
+/**
+ * @Description: Create a framer preceding each package with a Content-Length header
+ */
+var NewContentLengthFramer = common.NewContentLengthFramer
+
+/**
+ * @Description: Create a framer sending each package on its own line
+ */
+var NewNDJSONFramer = common.NewNDJSONFramer
+
 /**
  * @Description: Create a new TCP client
  * @Receiver p: Tcp structure pointer
@@ -67,18 +136,22 @@ func (p *Tcp) NewClient() Client {
 /**
  * @Description: Create a new TcpClient instance
  * @Param name: Service name
- * @Param protocol: Protocol type
- * @Param address: Service address
+ * @Param protocol: Protocol type, tcp or unix
+ * @Param address: Service address, comma separated socket paths for unix
  * @Param dc: Service discovery driver
  * @Return *TcpClient: TcpClient instance pointer
  */
 func NewTcpClient(name string, protocol string, address string, dc discovery.Driver) *TcpClient {
 	options := &TcpOptions{
-		"\r\n",
-		1024 * 1024 * 2,
+		PackageEof:       "\r\n",
+		PackageMaxLength: 1024 * 1024 * 2,
+	}
+	network := "tcp"
+	if strings.EqualFold(protocol, "unix") {
+		network = "unix"
 	}
-	pool := NewPool(name, address, dc, PoolOptions{5, 5})
-	return &TcpClient{
+	pool := newPool(name, network, address, dc, PoolOptions{5, 5})
+	c := &TcpClient{
 		Name:        name,
 		Protocol:    protocol,
 		Address:     address,
@@ -87,6 +160,8 @@ func NewTcpClient(name string, protocol string, address string, dc discovery.Dri
 		Options:     *options,
 		Pool:        pool,
 	}
+	c.subscriptions = newMultiplexer(c.dial)
+	return c
 }
 
 /**
@@ -116,27 +191,57 @@ func (c *TcpClient) BatchAppend(method string, params any, result any, isNotify
  * @Return error: Error message
  */
 func (c *TcpClient) BatchCall() error {
-	var (
-		err error
-		br  []any
-	)
-	for _, v := range c.RequestList {
-		var (
-			req any
-		)
-		method := fmt.Sprintf("%s/%s", c.Name, v.Method)
-		if v.IsNotify {
-			req = common.Rs(nil, method, v.Params)
-		} else {
-			req = common.Rs(strconv.FormatInt(time.Now().Unix(), 10), method, v.Params)
-		}
-		br = append(br, req)
-	}
-	bReq := common.JsonBatchRs(br)
-	bReq = append(bReq, []byte(c.Options.PackageEof)...)
-	err = c.handleFunc(bReq, c.RequestList)
+	return c.BatchCallContext(context.Background())
+}
+
+/**
+ * @Description: Execute batch requests bound to a context
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the batch call
+ * @Return error: Error message
+ */
+func (c *TcpClient) BatchCallContext(ctx context.Context) error {
+	requests := c.RequestList
 	c.RequestList = make([]*common.SingleRequest, 0)
-	return err
+	return c.BatchCallRequests(ctx, requests)
+}
+
+/**
+ * @Description: Execute a list of requests as one batch bound to a context, without the batch list
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the batch call
+ * @Param requests: Requests, their results and errors are filled in
+ * @Return error: Error message
+ */
+func (c *TcpClient) BatchCallRequests(ctx context.Context, requests []*common.SingleRequest) error {
+	return interceptBatch(ctx, c.BatchInterceptors, idGenerator(c.Options.IdGenerator), c.Name, nil, requests, c.send)
+}
+
+/**
+ * @Description: Stop watching the discovery service, close the idle and multiplexed connections and end the subscriptions
+ * @Receiver c: TcpClient structure pointer
+ * @Return error: Error message
+ */
+func (c *TcpClient) Close() error {
+	c.Pool.Close()
+	c.closeMultiplexers(func(address string) bool {
+		return true
+	})
+	return c.subscriptions.Close()
+}
+
+/**
+ * @Description: Call a method returning a subscription id and receive its notifications,
+ * the subscriptions share a connection of their own, dialed by the first one
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the subscribing call
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Return *Subscription: Subscription, ended with ErrConnectionLost when the connection drops
+ * @Return error: Error message, an *Error for error responses
+ */
+func (c *TcpClient) Subscribe(ctx context.Context, method string, params any) (*Subscription, error) {
+	return subscribe(ctx, c.subscriptions, c.Interceptors, idGenerator(c.Options.IdGenerator), c.Name, method, params)
 }
 
 /**
@@ -146,6 +251,11 @@ func (c *TcpClient) BatchCall() error {
  */
 func (c *TcpClient) SetOptions(tcpOptions any) {
 	c.Options = tcpOptions.(TcpOptions)
+	c.Pool.SetBalancer(c.Options.Balancer)
+	c.Pool.SetBreaker(c.Options.Breaker)
+	c.closeMultiplexers(func(address string) bool {
+		return true
+	})
 }
 
 /**
@@ -157,6 +267,18 @@ func (c *TcpClient) SetPoolOptions(poolOption any) {
 	c.Pool.SetOptions(poolOption.(PoolOptions))
 }
 
+/**
+ * @Description: Get the framer of the packages
+ * @Receiver o: TcpOptions structure
+ * @Return Framer: Framer, a delimiter framer with PackageEof when none is set
+ */
+func (o TcpOptions) framer() Framer {
+	if o.Framer == nil {
+		return NewDelimiterFramer(o.PackageEof)
+	}
+	return o.Framer
+}
+
 /**
  * @Description: Execute a single request
  * @Receiver c: TcpClient structure pointer
@@ -167,73 +289,299 @@ func (c *TcpClient) SetPoolOptions(poolOption any) {
  * @Return error: Error message
  */
 func (c *TcpClient) Call(method string, params any, result any, isNotify bool) error {
-	var (
-		err error
-		req []byte
-	)
-	method = fmt.Sprintf("%s/%s", c.Name, method)
-	if isNotify {
-		req = common.JsonRs(nil, method, params)
-	} else {
-		req = common.JsonRs(strconv.FormatInt(time.Now().Unix(), 10), method, params)
-	}
-	req = append(req, []byte(c.Options.PackageEof)...)
-	err = c.handleFunc(req, result)
-	return err
+	return c.CallContext(context.Background(), method, params, result, isNotify)
+}
+
+/**
+ * @Description: Execute a single request bound to a context
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling dialing, writing and reading
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param isNotify: Whether it's a notification
+ * @Return error: Error message
+ */
+func (c *TcpClient) CallContext(ctx context.Context, method string, params any, result any, isNotify bool) error {
+	return intercept(ctx, c.Interceptors, idGenerator(c.Options.IdGenerator), c.Name, nil, method, params, result, isNotify, c.send)
+}
+
+/**
+ * @Description: Append interceptors wrapping single calls
+ * @Receiver c: TcpClient structure pointer
+ * @Param interceptors: Interceptors, the first one added is the outermost
+ */
+func (c *TcpClient) Use(interceptors ...Interceptor) {
+	c.Interceptors = append(c.Interceptors, interceptors...)
+}
+
+/**
+ * @Description: Append interceptors wrapping batch calls
+ * @Receiver c: TcpClient structure pointer
+ * @Param interceptors: Batch interceptors, the first one added is the outermost
+ */
+func (c *TcpClient) UseBatch(interceptors ...BatchInterceptor) {
+	c.BatchInterceptors = append(c.BatchInterceptors, interceptors...)
+}
+
+/**
+ * @Description: Send request data over a multiplexed connection, or framed over a pooled connection
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the request
+ * @Param info: Call information passed to the balancer
+ * @Param methods: Methods sent by the request
+ * @Param b: Request data
+ * @Param result: Result
+ * @Return error: Error message
+ */
+func (c *TcpClient) send(ctx context.Context, info PickInfo, methods []string, b []byte, result any) error {
+	if c.Options.Multiplex > 0 {
+		return c.Options.Retry.Do(ctx, info, methods, func(ctx context.Context, info PickInfo) (string, error) {
+			return c.attemptMultiplexed(ctx, info, b, result)
+		})
+	}
+	return c.handleFunc(ctx, info, methods, c.Options.framer().Frame(b), result)
+}
+
+/**
+ * @Description: Send a request once over a multiplexed connection to the instance picked by the balancer
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the attempt
+ * @Param info: Call information passed to the balancer
+ * @Param b: Request data
+ * @Param result: Result
+ * @Return string: Address of the instance used, empty when none was picked
+ * @Return error: Error message
+ */
+func (c *TcpClient) attemptMultiplexed(ctx context.Context, info PickInfo, b []byte, result any) (string, error) {
+	instance, err := c.Pool.Pick(info)
+	if err != nil {
+		return "", err
+	}
+	err = c.multiplexer(instance).send(ctx, b, result)
+	c.Pool.Done(instance, err)
+	return instance.Address(), err
+}
+
+/**
+ * @Description: Get one of the multiplexers of an instance, creating them on first use
+ * @Receiver c: TcpClient structure pointer
+ * @Param instance: Instance picked by the balancer
+ * @Return *multiplexer: Multiplexer
+ */
+func (c *TcpClient) multiplexer(instance discovery.Instance) *multiplexer {
+	address := instance.Address()
+	c.muxMu.Lock()
+	multiplexers, ok := c.multiplexers[address]
+	if !ok {
+		if c.multiplexers == nil {
+			c.multiplexers = make(map[string][]*multiplexer)
+		}
+		for i := 0; i < c.Options.Multiplex; i++ {
+			multiplexers = append(multiplexers, newMultiplexer(func(ctx context.Context) (streamConn, error) {
+				conn, err := c.Pool.connect(ctx, instance)
+				if err != nil {
+					return nil, err
+				}
+				return c.stream(conn), nil
+			}))
+		}
+		c.multiplexers[address] = multiplexers
+	}
+	c.muxMu.Unlock()
+	if !ok {
+		// The instances changed, close the connections to the removed ones.
+		c.closeMultiplexers(func(address string) bool {
+			return !c.Pool.IsActive(address)
+		})
+	}
+	return multiplexers[c.next.Add(1)%uint64(len(multiplexers))]
 }
 
 /**
- * @Description: Handle request and response
+ * @Description: Close the multiplexers of the instances matched, the calls waiting on them fail with ErrClientClosed
  * @Receiver c: TcpClient structure pointer
+ * @Param match: Function selecting the instance addresses
+ */
+func (c *TcpClient) closeMultiplexers(match func(address string) bool) {
+	c.muxMu.Lock()
+	defer c.muxMu.Unlock()
+	for address, multiplexers := range c.multiplexers {
+		if !match(address) {
+			continue
+		}
+		for _, m := range multiplexers {
+			m.Close()
+		}
+		delete(c.multiplexers, address)
+	}
+}
+
+/**
+ * @Description: Handle request and response, retrying with the retry policy
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the request
+ * @Param info: Call information passed to the balancer
+ * @Param methods: Methods sent by the request
  * @Param b: Request data
  * @Param result: Result
  * @Return error: Error message
  */
-func (c *TcpClient) handleFunc(b []byte, result any) error {
-	var (
-		err  error
-		conn net.Conn
-	)
+func (c *TcpClient) handleFunc(ctx context.Context, info PickInfo, methods []string, b []byte, result any) error {
+	return c.Options.Retry.Do(ctx, info, methods, func(ctx context.Context, info PickInfo) (string, error) {
+		return c.attempt(ctx, info, b, result)
+	})
+}
 
-	conn, err = c.Pool.Borrow()
+/**
+ * @Description: Send a request once and read its response, notifications are not answered
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the attempt
+ * @Param info: Call information passed to the balancer
+ * @Param b: Request data
+ * @Param result: Result
+ * @Return string: Address of the instance used, empty when no connection was borrowed
+ * @Return error: Error message
+ */
+func (c *TcpClient) attempt(ctx context.Context, info PickInfo, b []byte, result any) (string, error) {
+	conn, err := c.Pool.BorrowContext(ctx, info)
 	if err == nil {
-		_, err = conn.Write(b)
+		err = c.write(ctx, conn, b)
 	}
 	if err != nil {
-		conn, err = c.Pool.BorrowAfterRemove(conn)
+		if ctx.Err() != nil {
+			c.Pool.Remove(conn, err)
+			return "", ContextError(ctx, err)
+		}
+		// The idle connection may have been closed by the server, reconnect once.
+		conn, err = c.Pool.BorrowAfterRemoveContext(ctx, conn, err, info)
 		if err != nil {
-			c.Pool.Remove(conn)
-			return err
+			return "", ContextError(ctx, err)
 		}
-		_, err = conn.Write(b)
+		err = c.write(ctx, conn, b)
 		if err != nil {
-			c.Pool.Remove(conn)
-			return err
+			c.Pool.Remove(conn, err)
+			return conn.Instance.Address(), ContextError(ctx, err)
 		}
 	}
-	defer c.Pool.Release(conn)
-
-	eofb := []byte(c.Options.PackageEof)
-	eofl := len(eofb)
-	var (
-		data []byte
-	)
-	l := 0
-	for {
-		var buf = make([]byte, c.Options.PackageMaxLength)
-		n, readErr := conn.Read(buf)
-		if readErr != nil {
-			if n == 0 {
-				return readErr
-			}
-			common.Debug(readErr.Error())
-		}
-		l += n
-		data = append(data, buf[:n]...)
-		if bytes.Equal(data[l-eofl:], eofb) {
-			break
-		}
+
+	// A released connection may be borrowed by another call at once, read its address before.
+	address := conn.Instance.Address()
+	if !expectsResponse(result) {
+		c.Pool.Release(conn, nil)
+		return address, nil
+	}
+	data, err := c.read(ctx, conn)
+	if err != nil {
+		// The connection may hold a partial response, do not reuse it.
+		c.Pool.Remove(conn, err)
+		return address, ContextError(ctx, err)
+	}
+	err = common.GetResult(data, result)
+	c.Pool.Release(conn, err)
+	return address, err
+}
+
+/**
+ * @Description: Write request data to the connection
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the write
+ * @Param conn: Network connection
+ * @Param b: Request data
+ * @Return error: Error message
+ */
+func (c *TcpClient) write(ctx context.Context, conn net.Conn, b []byte) error {
+	defer watchContext(ctx, conn)()
+	_, err := conn.Write(b)
+	return err
+}
+
+/**
+ * @Description: Read a response package from the connection
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the read
+ * @Param conn: Pooled connection
+ * @Return []byte: Response data without the framing
+ * @Return error: Error message
+ */
+func (c *TcpClient) read(ctx context.Context, conn *Conn) ([]byte, error) {
+	defer watchContext(ctx, conn)()
+	if conn.reader == nil {
+		conn.reader = bufio.NewReader(conn.Conn)
 	}
-	err = common.GetResult(data[:l-eofl], result)
+	return c.Options.framer().ReadFrame(conn.reader, c.Options.PackageMaxLength)
+}
+
+/**
+ * @Description: Open a connection carrying the subscriptions to the instance picked by the balancer
+ * @Receiver c: TcpClient structure pointer
+ * @Param ctx: Context controlling the dial
+ * @Return streamConn: Connection
+ * @Return error: Error message
+ */
+func (c *TcpClient) dial(ctx context.Context) (streamConn, error) {
+	instance, err := c.Pool.Pick(PickInfo{})
+	if err != nil {
+		return nil, err
+	}
+	// Dial without the pool lock, calls borrowing connections must not wait for it.
+	conn, err := c.Pool.connect(ctx, instance)
+	c.Pool.Done(instance, err)
+	if err != nil {
+		return nil, err
+	}
+	return c.stream(conn), nil
+}
+
+/**
+ * @Description: Wrap a connection into a stream of framed packages
+ * @Receiver c: TcpClient structure pointer
+ * @Param conn: Network connection
+ * @Return *tcpStream: Stream
+ */
+func (c *TcpClient) stream(conn net.Conn) *tcpStream {
+	return &tcpStream{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		framer: c.Options.framer(),
+		max:    c.Options.PackageMaxLength,
+	}
+}
+
+/**
+ * @Description: Read the next package
+ * @Receiver s: tcpStream structure pointer
+ * @Return []byte: Package data without the framing
+ * @Return error: Error message
+ */
+func (s *tcpStream) ReadMessage() ([]byte, error) {
+	return s.framer.ReadFrame(s.reader, s.max)
+}
+
+/**
+ * @Description: Write a framed package
+ * @Receiver s: tcpStream structure pointer
+ * @Param ctx: Context whose deadline bounds the write
+ * @Param b: Package data
+ * @Return error: Error message
+ */
+func (s *tcpStream) WriteMessage(ctx context.Context, b []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	// The read deadline is left alone, the connection is shared by the other calls.
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+		defer s.conn.SetWriteDeadline(time.Time{})
+	}
+	_, err := s.conn.Write(s.framer.Frame(b))
 	return err
 }
+
+/**
+ * @Description: Close the connection
+ * @Receiver s: tcpStream structure pointer
+ * @Return error: Error message
+ */
+func (s *tcpStream) Close() error {
+	return s.conn.Close()
+}
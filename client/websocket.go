@@ -0,0 +1,384 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+	"github.com/sunquakes/jsonrpc4go/discovery"
+	"golang.org/x/net/websocket"
+)
+
+/**
+ * @Description: Basic configuration structure for WebSocket client
+ * @Field Name: Service name
+ * @Field Protocol: Protocol type, ws or wss
+ * @Field Address: Service address, host:port or a ws:// or wss:// URL
+ * @Field Discovery: Service discovery driver
+ */
+type WebSocket struct {
+	Name      string
+	Protocol  string
+	Address   string
+	Discovery discovery.Driver
+}
+
+/**
+ * @Description: Main structure for WebSocket client, concurrent calls share one connection and are matched by id
+ * @Field Name: Service name
+ * @Field Protocol: Protocol type, ws or wss
+ * @Field Address: Service address
+ * @Field Discovery: Service discovery driver
+ * @Field RequestList: Request list for batch calls
+ * @Field Options: WebSocket client options
+ * @Field Interceptors: Interceptors wrapping single calls, the first one is the outermost
+ * @Field BatchInterceptors: Interceptors wrapping batch calls, the first one is the outermost
+ */
+type WebSocketClient struct {
+	Name              string
+	Protocol          string
+	Address           string
+	Discovery         discovery.Driver
+	RequestList       []*common.SingleRequest
+	Options           WebSocketOptions
+	Interceptors      []Interceptor
+	BatchInterceptors []BatchInterceptor
+	mux               *multiplexer
+	next              int
+}
+
+/**
+ * @Description: Options structure for WebSocket client
+ * @Field Path: Path of the WebSocket endpoint, "/" when empty, ignored when the address is a URL
+ * @Field Origin: Origin sent with the handshake, the origin of the server when empty
+ * @Field Header: Headers sent with the handshake
+ * @Field TLSConfig: TLS configuration of wss connections, e.g. RootCAs trusting a private CA or a self-signed certificate;
+ * the system roots when nil
+ * @Field IdGenerator: Request id generator, a counter shared by the clients when nil
+ */
+type WebSocketOptions struct {
+	Path        string
+	Origin      string
+	Header      http.Header
+	TLSConfig   *tls.Config
+	IdGenerator IdGenerator
+}
+
+/**
+ * @Description: WebSocket connection carrying one JSON-RPC message per WebSocket message
+ * @Field conn: WebSocket connection
+ * @Field writeMu: Lock serializing the writes
+ */
+type webSocketStream struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+/**
+ * @Description: Create a new WebSocket client
+ * @Receiver p: WebSocket structure pointer
+ * @Return Client: Client interface
+ */
+func (p *WebSocket) NewClient() Client {
+	return NewWebSocketClient(p.Name, p.Protocol, p.Address, p.Discovery)
+}
+
+/**
+ * @Description: Create a new WebSocketClient instance, the connection is opened by the first call
+ * @Param name: Service name
+ * @Param protocol: Protocol type, ws or wss
+ * @Param address: Service address
+ * @Param dc: Service discovery driver
+ * @Return *WebSocketClient: WebSocketClient instance pointer
+ */
+func NewWebSocketClient(name string, protocol string, address string, dc discovery.Driver) *WebSocketClient {
+	c := &WebSocketClient{
+		Name:      name,
+		Protocol:  protocol,
+		Address:   address,
+		Discovery: dc,
+	}
+	c.mux = newMultiplexer(c.dial)
+	return c
+}
+
+/**
+ * @Description: Set WebSocket options
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param webSocketOptions: WebSocket options
+ */
+func (c *WebSocketClient) SetOptions(webSocketOptions any) {
+	c.Options = webSocketOptions.(WebSocketOptions)
+}
+
+/**
+ * @Description: Set connection pool options, a WebSocket client uses a single connection
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param poolOption: Connection pool options, ignored
+ */
+func (c *WebSocketClient) SetPoolOptions(poolOption any) {
+}
+
+/**
+ * @Description: Batch add requests
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param isNotify: Whether it's a notification
+ * @Return *error: Error pointer
+ */
+func (c *WebSocketClient) BatchAppend(method string, params any, result any, isNotify bool) *error {
+	singleRequest := &common.SingleRequest{
+		Method:   method,
+		Params:   params,
+		Result:   result,
+		Error:    new(error),
+		IsNotify: isNotify,
+	}
+	c.RequestList = append(c.RequestList, singleRequest)
+	return singleRequest.Error
+}
+
+/**
+ * @Description: Execute batch requests
+ * @Receiver c: WebSocketClient structure pointer
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) BatchCall() error {
+	return c.BatchCallContext(context.Background())
+}
+
+/**
+ * @Description: Execute batch requests bound to a context
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param ctx: Context controlling the batch call
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) BatchCallContext(ctx context.Context) error {
+	requests := c.RequestList
+	c.RequestList = make([]*common.SingleRequest, 0)
+	return c.BatchCallRequests(ctx, requests)
+}
+
+/**
+ * @Description: Execute a list of requests as one batch bound to a context, without the batch list
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param ctx: Context controlling the batch call
+ * @Param requests: Requests, their results and errors are filled in
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) BatchCallRequests(ctx context.Context, requests []*common.SingleRequest) error {
+	return interceptBatch(ctx, c.BatchInterceptors, idGenerator(c.Options.IdGenerator), c.Name, nil, requests, c.send)
+}
+
+/**
+ * @Description: Call a method
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param isNotify: Whether it's a notification
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) Call(method string, params any, result any, isNotify bool) error {
+	return c.CallContext(context.Background(), method, params, result, isNotify)
+}
+
+/**
+ * @Description: Call a method bound to a context
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param ctx: Context controlling the call
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Param result: Result
+ * @Param isNotify: Whether it's a notification
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) CallContext(ctx context.Context, method string, params any, result any, isNotify bool) error {
+	return intercept(ctx, c.Interceptors, idGenerator(c.Options.IdGenerator), c.Name, nil, method, params, result, isNotify, c.send)
+}
+
+/**
+ * @Description: Append interceptors wrapping single calls
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param interceptors: Interceptors, the first one added is the outermost
+ */
+func (c *WebSocketClient) Use(interceptors ...Interceptor) {
+	c.Interceptors = append(c.Interceptors, interceptors...)
+}
+
+/**
+ * @Description: Append interceptors wrapping batch calls
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param interceptors: Interceptors, the first one added is the outermost
+ */
+func (c *WebSocketClient) UseBatch(interceptors ...BatchInterceptor) {
+	c.BatchInterceptors = append(c.BatchInterceptors, interceptors...)
+}
+
+/**
+ * @Description: Close the connection, calls waiting for a response fail with ErrConnectionLost
+ * @Receiver c: WebSocketClient structure pointer
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) Close() error {
+	return c.mux.Close()
+}
+
+/**
+ * @Description: Call a method returning a subscription id and receive its notifications
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param ctx: Context controlling the subscribing call
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Return *Subscription: Subscription, ended with ErrConnectionLost when the connection drops
+ * @Return error: Error message, an *Error for error responses
+ */
+func (c *WebSocketClient) Subscribe(ctx context.Context, method string, params any) (*Subscription, error) {
+	return subscribe(ctx, c.mux, c.Interceptors, idGenerator(c.Options.IdGenerator), c.Name, method, params)
+}
+
+/**
+ * @Description: Send request data and wait for the response matching its ids
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param ctx: Context controlling the request
+ * @Param info: Call information
+ * @Param methods: Methods sent by the request
+ * @Param b: Request data
+ * @Param result: Result, nil for notifications
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) send(ctx context.Context, info PickInfo, methods []string, b []byte, result any) error {
+	return c.mux.send(ctx, b, result)
+}
+
+/**
+ * @Description: Open a connection, the instances are tried in turn on each reconnection
+ * @Receiver c: WebSocketClient structure pointer
+ * @Param ctx: Context controlling the dial
+ * @Return streamConn: Connection
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) dial(ctx context.Context) (streamConn, error) {
+	url, err := c.url()
+	if err != nil {
+		return nil, err
+	}
+	origin := c.Options.Origin
+	if origin == "" {
+		// The server accepts its own origin.
+		origin = serverOrigin(url)
+	}
+	config, err := websocket.NewConfig(url, origin)
+	if err != nil {
+		return nil, err
+	}
+	if c.Options.Header != nil {
+		config.Header = c.Options.Header.Clone()
+	}
+	if c.Options.TLSConfig != nil {
+		config.TlsConfig = c.Options.TLSConfig.Clone()
+	}
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &webSocketStream{conn: conn}, nil
+}
+
+/**
+ * @Description: Get the URL to dial
+ * @Receiver c: WebSocketClient structure pointer
+ * @Return string: WebSocket URL
+ * @Return error: Error message
+ */
+func (c *WebSocketClient) url() (string, error) {
+	var (
+		instances []discovery.Instance
+		err       error
+	)
+	if c.Discovery == nil {
+		if strings.Contains(c.Address, "://") {
+			return c.Address, nil
+		}
+		instances, err = discovery.ParseAddresses(c.Name, c.Address)
+	} else {
+		instances, err = c.Discovery.GetInstances(c.Name)
+		instances = discovery.Healthy(instances)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(instances) == 0 {
+		return "", ErrNoInstance
+	}
+	instance := instances[c.next%len(instances)]
+	c.next++
+	scheme := strings.ToLower(c.Protocol)
+	if scheme != "wss" {
+		scheme = "ws"
+	}
+	path := c.Options.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return scheme + "://" + instance.Address() + path, nil
+}
+
+/**
+ * @Description: Read the next message
+ * @Receiver s: webSocketStream structure pointer
+ * @Return []byte: Message data
+ * @Return error: Error message
+ */
+func (s *webSocketStream) ReadMessage() ([]byte, error) {
+	var data []byte
+	err := websocket.Message.Receive(s.conn, &data)
+	return data, err
+}
+
+/**
+ * @Description: Write a message
+ * @Receiver s: webSocketStream structure pointer
+ * @Param ctx: Context whose deadline bounds the write
+ * @Param b: Message data
+ * @Return error: Error message
+ */
+func (s *webSocketStream) WriteMessage(ctx context.Context, b []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	// The read deadline is left alone, the connection is shared by the other calls.
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+		defer s.conn.SetWriteDeadline(time.Time{})
+	}
+	return websocket.Message.Send(s.conn, string(b))
+}
+
+/**
+ * @Description: Close the connection
+ * @Receiver s: webSocketStream structure pointer
+ * @Return error: Error message
+ */
+func (s *webSocketStream) Close() error {
+	return s.conn.Close()
+}
+
+/**
+ * @Description: Get the origin of the server of a WebSocket URL
+ * @Param rawURL: WebSocket URL
+ * @Return string: Origin, https for wss and http otherwise
+ */
+func serverOrigin(rawURL string) string {
+	scheme, rest, _ := strings.Cut(rawURL, "://")
+	host, _, _ := strings.Cut(rest, "/")
+	if strings.EqualFold(scheme, "wss") {
+		return "https://" + host
+	}
+	return "http://" + host
+}
@@ -0,0 +1,287 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+ * BindOptions configures how request params are bound to the params of a service method.
+ *
+ * Fields:
+ *   AllowUnknownFields bool - Whether params the struct has no field for are ignored instead of rejected
+ */
+type BindOptions struct {
+	AllowUnknownFields bool
+}
+
+/*
+ * bindField describes a struct field params are bound to.
+ *
+ * Fields:
+ *   Name       string - Param name, the json tag name or the field name
+ *   Index      []int  - Index sequence of the field, through embedded structs
+ *   Optional   bool   - Whether the param may be omitted
+ *   Default    string - Default value used when the param is omitted
+ *   HasDefault bool   - Whether the field has a default value
+ */
+type bindField struct {
+	Name       string
+	Index      []int
+	Optional   bool
+	Default    string
+	HasDefault bool
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+/*
+ * Bind decodes request params into the params of a service method.
+ *
+ * A struct is bound by name from an object, matching the json tag name or the field name exactly and
+ * then case-insensitively, or by position from an array in field order. A field is optional when it
+ * is a pointer, has the omitempty json option or a default tag, e.g. `json:"size" default:"10"`;
+ * other fields are required. Embedded structs are flattened as by encoding/json.
+ * Other types are decoded as is, a scalar also from an array holding it alone.
+ *
+ * Parameters:
+ *   data    json.RawMessage - Params as sent by the client, empty when omitted
+ *   v       any             - Pointer to the params
+ *   options BindOptions     - Binding options
+ *
+ * Returns:
+ *   error - Error naming the param which could not be bound
+ */
+func Bind(data json.RawMessage, v any, options BindOptions) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("bind: need a non-nil pointer, %T got", v)
+	}
+	e := rv.Elem()
+	data = bytes.TrimSpace(data)
+	if e.Kind() == reflect.Struct && !rv.Type().Implements(unmarshalerType) {
+		fields := structFields(e.Type())
+		if len(data) == 0 {
+			return bindObject(nil, e, fields, options)
+		}
+		switch data[0] {
+		case '{':
+			var m map[string]json.RawMessage
+			if err := json.Unmarshal(data, &m); err != nil {
+				return err
+			}
+			return bindObject(m, e, fields, options)
+		case '[':
+			var list []json.RawMessage
+			if err := json.Unmarshal(data, &list); err != nil {
+				return err
+			}
+			return bindArray(list, e, fields, options)
+		}
+		return errors.New("params must be an object or an array")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if data[0] == '[' && isScalar(e.Type()) {
+		var list []json.RawMessage
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		if len(list) != 1 {
+			return fmt.Errorf("params must hold 1 value, %d got", len(list))
+		}
+		data = list[0]
+	}
+	return json.Unmarshal(data, v)
+}
+
+/*
+ * bindObject binds params by name.
+ *
+ * Parameters:
+ *   m       map[string]json.RawMessage - Params object
+ *   e       reflect.Value              - Struct to fill
+ *   fields  []bindField                - Fields of the struct
+ *   options BindOptions                - Binding options
+ *
+ * Returns:
+ *   error - Error naming the param which could not be bound
+ */
+func bindObject(m map[string]json.RawMessage, e reflect.Value, fields []bindField, options BindOptions) error {
+	used := make(map[string]bool)
+	for _, f := range fields {
+		key, ok := lookup(m, used, f.Name)
+		if !ok {
+			if err := missing(e, f); err != nil {
+				return err
+			}
+			continue
+		}
+		used[key] = true
+		if err := json.Unmarshal(m[key], e.FieldByIndex(f.Index).Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid param %q: %v", f.Name, err)
+		}
+	}
+	if !options.AllowUnknownFields {
+		for key := range m {
+			if !used[key] {
+				return fmt.Errorf("unknown param %q", key)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+ * bindArray binds params by position.
+ *
+ * Parameters:
+ *   list    []json.RawMessage - Params array
+ *   e       reflect.Value     - Struct to fill
+ *   fields  []bindField       - Fields of the struct
+ *   options BindOptions       - Binding options
+ *
+ * Returns:
+ *   error - Error naming the param which could not be bound
+ */
+func bindArray(list []json.RawMessage, e reflect.Value, fields []bindField, options BindOptions) error {
+	if len(list) > len(fields) && !options.AllowUnknownFields {
+		return fmt.Errorf("params must hold at most %d values, %d got", len(fields), len(list))
+	}
+	for i, f := range fields {
+		if i >= len(list) {
+			if err := missing(e, f); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := json.Unmarshal(list[i], e.FieldByIndex(f.Index).Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid param %q: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+/*
+ * lookup finds the key of a param, an exact match first and then a case-insensitive one.
+ *
+ * Parameters:
+ *   m    map[string]json.RawMessage - Params object
+ *   used map[string]bool            - Keys already bound
+ *   name string                     - Param name
+ *
+ * Returns:
+ *   string - Key found
+ *   bool   - Whether a key was found
+ */
+func lookup(m map[string]json.RawMessage, used map[string]bool, name string) (string, bool) {
+	if _, ok := m[name]; ok && !used[name] {
+		return name, true
+	}
+	for key := range m {
+		if !used[key] && strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+/*
+ * missing handles an omitted param, setting its default value.
+ *
+ * Parameters:
+ *   e reflect.Value - Struct to fill
+ *   f bindField     - Field of the omitted param
+ *
+ * Returns:
+ *   error - Error when the param is required or its default value is invalid
+ */
+func missing(e reflect.Value, f bindField) error {
+	if !f.HasDefault {
+		if f.Optional {
+			return nil
+		}
+		return fmt.Errorf("missing param %q", f.Name)
+	}
+	fv := e.FieldByIndex(f.Index)
+	if fv.Kind() == reflect.Ptr {
+		fv.Set(reflect.New(fv.Type().Elem()))
+		fv = fv.Elem()
+	}
+	if fv.Kind() == reflect.String {
+		fv.SetString(f.Default)
+		return nil
+	}
+	if err := json.Unmarshal([]byte(f.Default), fv.Addr().Interface()); err != nil {
+		return fmt.Errorf("invalid default of param %q: %v", f.Name, err)
+	}
+	return nil
+}
+
+/*
+ * structFields lists the fields params are bound to, in declaration order.
+ *
+ * Parameters:
+ *   t reflect.Type - Struct type
+ *
+ * Returns:
+ *   []bindField - Fields of the struct and of its embedded structs
+ */
+func structFields(t reflect.Type) []bindField {
+	fields := make([]bindField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			for _, f := range structFields(sf.Type) {
+				f.Index = append([]int{i}, f.Index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		def, hasDefault := sf.Tag.Lookup("default")
+		fields = append(fields, bindField{
+			Name:       name,
+			Index:      []int{i},
+			Optional:   sf.Type.Kind() == reflect.Ptr || strings.Contains(","+opts+",", ",omitempty,"),
+			Default:    def,
+			HasDefault: hasDefault,
+		})
+	}
+	return fields
+}
+
+/*
+ * isScalar reports whether params of a type are a single JSON value rather than an array.
+ *
+ * Parameters:
+ *   t reflect.Type - Params type
+ *
+ * Returns:
+ *   bool - True for booleans, numbers and strings
+ */
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return !reflect.PointerTo(t).Implements(unmarshalerType)
+	}
+	return false
+}
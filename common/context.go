@@ -0,0 +1,98 @@
+package common
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey int
+
+const (
+	peerKey contextKey = iota
+	requestInfoKey
+	notifierKey
+)
+
+/*
+ * Peer describes the remote side of a JSON-RPC call.
+ *
+ * Fields:
+ *   Transport  string      - Transport the request arrived on (http, https, tcp, ws, wss, unix or stdio)
+ *   RemoteAddr string      - Network address of the client
+ *   Header     http.Header - HTTP request headers, nil for transports without headers
+ */
+type Peer struct {
+	Transport  string
+	RemoteAddr string
+	Header     http.Header
+}
+
+/*
+ * RequestInfo describes the JSON-RPC request being processed.
+ *
+ * Fields:
+ *   Id     any    - Request ID, nil for notifications
+ *   Method string - Method name as sent by the client
+ */
+type RequestInfo struct {
+	Id     any
+	Method string
+}
+
+/*
+ * WithPeer returns a copy of the context carrying the peer.
+ *
+ * Parameters:
+ *   ctx  context.Context - Parent context
+ *   peer *Peer           - Peer of the connection or HTTP request
+ *
+ * Returns:
+ *   context.Context - Context carrying the peer
+ */
+func WithPeer(ctx context.Context, peer *Peer) context.Context {
+	return context.WithValue(ctx, peerKey, peer)
+}
+
+/*
+ * PeerFromContext returns the peer stored in the context.
+ *
+ * Parameters:
+ *   ctx context.Context - Context passed to a service method or hook
+ *
+ * Returns:
+ *   *Peer - Peer of the call
+ *   bool  - Whether the context carries a peer
+ */
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	peer, ok := ctx.Value(peerKey).(*Peer)
+	return peer, ok
+}
+
+/*
+ * WithRequestInfo returns a copy of the context carrying the request information.
+ *
+ * Parameters:
+ *   ctx  context.Context - Parent context
+ *   info *RequestInfo    - Information of the request being processed
+ *
+ * Returns:
+ *   context.Context - Context carrying the request information
+ */
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey, info)
+}
+
+/*
+ * RequestInfoFromContext returns the request information stored in the context.
+ *
+ * Parameters:
+ *   ctx context.Context - Context passed to a service method or hook
+ *
+ * Returns:
+ *   *RequestInfo - Information of the request being processed
+ *   bool         - Whether the context carries request information
+ */
+func RequestInfoFromContext(ctx context.Context) (*RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey).(*RequestInfo)
+	return info, ok
+}
@@ -0,0 +1,266 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+ * ErrFrameTooLarge is returned when a frame exceeds the maximum frame size.
+ */
+var ErrFrameTooLarge = errors.New("jsonrpc4go: frame exceeds the maximum size")
+
+/*
+ * ErrMalformedFrame is wrapped by the errors returned for frames which do not follow the framing.
+ */
+var ErrMalformedFrame = errors.New("jsonrpc4go: malformed frame")
+
+/*
+ * Framer splits a tcp stream into messages. The server answers a frame it cannot read with a parse error
+ * (-32700) and closes the connection, since the next frame can no longer be found.
+ */
+type Framer interface {
+	/*
+	 * ReadFrame reads the next message.
+	 *
+	 * Parameters:
+	 *   r   *bufio.Reader - Reader of the connection, the bytes past the frame stay in it
+	 *   max int64         - Maximum message size, unlimited when not positive
+	 *
+	 * Returns:
+	 *   []byte - Message
+	 *   error  - Error reading the connection, ErrFrameTooLarge, or an error wrapping ErrMalformedFrame
+	 */
+	ReadFrame(r *bufio.Reader, max int64) ([]byte, error)
+
+	/*
+	 * Frame frames a message.
+	 *
+	 * Parameters:
+	 *   b []byte - Message
+	 *
+	 * Returns:
+	 *   []byte - Frame written to the connection at once
+	 */
+	Frame(b []byte) []byte
+}
+
+/*
+ * delimiterFramer ends each message with a delimiter.
+ *
+ * Fields:
+ *   delimiter []byte - Delimiter, it must not appear in the messages
+ */
+type delimiterFramer struct {
+	delimiter []byte
+}
+
+/*
+ * lengthPrefixFramer precedes each message with its size as a 4-byte big-endian integer.
+ */
+type lengthPrefixFramer struct{}
+
+/*
+ * contentLengthFramer precedes each message with Content-Length headers, as the Language Server Protocol does.
+ */
+type contentLengthFramer struct{}
+
+/*
+ * ndjsonFramer sends each message on its own line, as newline-delimited JSON.
+ */
+type ndjsonFramer struct{}
+
+/*
+ * NewDelimiterFramer creates a framer ending each message with a delimiter, the framer used by default
+ * with the PackageEof of the tcp options.
+ *
+ * Parameters:
+ *   delimiter string - Delimiter, "\r\n" when empty
+ *
+ * Returns:
+ *   Framer - Delimiter framer
+ */
+func NewDelimiterFramer(delimiter string) Framer {
+	if delimiter == "" {
+		delimiter = "\r\n"
+	}
+	return delimiterFramer{[]byte(delimiter)}
+}
+
+/*
+ * NewLengthPrefixFramer creates a framer preceding each message with its size as a 4-byte big-endian integer.
+ *
+ * Returns:
+ *   Framer - Length prefix framer
+ */
+func NewLengthPrefixFramer() Framer {
+	return lengthPrefixFramer{}
+}
+
+/*
+ * NewContentLengthFramer creates a framer preceding each message with a "Content-Length: n\r\n\r\n" header,
+ * as the Language Server Protocol does. Other headers are ignored when reading.
+ *
+ * Returns:
+ *   Framer - Content-Length framer
+ */
+func NewContentLengthFramer() Framer {
+	return contentLengthFramer{}
+}
+
+/*
+ * NewNDJSONFramer creates a framer sending each message on its own line, blank lines are skipped when reading.
+ *
+ * Returns:
+ *   Framer - Newline-delimited JSON framer
+ */
+func NewNDJSONFramer() Framer {
+	return ndjsonFramer{}
+}
+
+/*
+ * ReadFrame reads the bytes up to the next delimiter, even when the delimiter spans several reads.
+ */
+func (f delimiterFramer) ReadFrame(r *bufio.Reader, max int64) ([]byte, error) {
+	var data []byte
+	for {
+		line, err := r.ReadSlice(f.delimiter[len(f.delimiter)-1])
+		data = append(data, line...)
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+		ended := bytes.HasSuffix(data, f.delimiter)
+		size := int64(len(data))
+		if ended {
+			size -= int64(len(f.delimiter))
+		}
+		// An unfinished message is too large once it cannot end within the maximum size.
+		if max > 0 && (ended && size > max || size > max+int64(len(f.delimiter))) {
+			return nil, ErrFrameTooLarge
+		}
+		if ended {
+			return data[:size], nil
+		}
+	}
+}
+
+/*
+ * Frame appends the delimiter to the message.
+ */
+func (f delimiterFramer) Frame(b []byte) []byte {
+	frame := make([]byte, 0, len(b)+len(f.delimiter))
+	return append(append(frame, b...), f.delimiter...)
+}
+
+/*
+ * ReadFrame reads the size, then the message.
+ */
+func (f lengthPrefixFramer) ReadFrame(r *bufio.Reader, max int64) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := int64(binary.BigEndian.Uint32(size[:]))
+	if max > 0 && n > max {
+		return nil, ErrFrameTooLarge
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+/*
+ * Frame precedes the message with its size.
+ */
+func (f lengthPrefixFramer) Frame(b []byte) []byte {
+	frame := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	return append(frame, b...)
+}
+
+/*
+ * ReadFrame reads the headers up to the blank line, then Content-Length bytes.
+ */
+func (f contentLengthFramer) ReadFrame(r *bufio.Reader, max int64) ([]byte, error) {
+	n := int64(-1)
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, fmt.Errorf("%w: header too long", ErrMalformedFrame)
+		}
+		if err != nil {
+			return nil, err
+		}
+		header := strings.TrimRight(string(line), "\r\n")
+		if header == "" {
+			break
+		}
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: header %q", ErrMalformedFrame, header)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if n, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64); err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: Content-Length %q", ErrMalformedFrame, value)
+			}
+		}
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("%w: no Content-Length", ErrMalformedFrame)
+	}
+	if max > 0 && n > max {
+		return nil, ErrFrameTooLarge
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+/*
+ * Frame precedes the message with its Content-Length header.
+ */
+func (f contentLengthFramer) Frame(b []byte) []byte {
+	frame := fmt.Appendf(make([]byte, 0, len(b)+32), "Content-Length: %d\r\n\r\n", len(b))
+	return append(frame, b...)
+}
+
+/*
+ * ReadFrame reads the next non-blank line, without its line ending.
+ */
+func (f ndjsonFramer) ReadFrame(r *bufio.Reader, max int64) ([]byte, error) {
+	for {
+		limit := max
+		if limit > 0 {
+			// A line ending with \r\n is one byte longer than the message.
+			limit++
+		}
+		line, err := delimiterFramer{[]byte("\n")}.ReadFrame(r, limit)
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if max > 0 && int64(len(line)) > max {
+			return nil, ErrFrameTooLarge
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, nil
+		}
+	}
+}
+
+/*
+ * Frame appends a newline to the message, which encoding/json never writes inside it.
+ */
+func (f ndjsonFramer) Frame(b []byte) []byte {
+	return delimiterFramer{[]byte("\n")}.Frame(b)
+}
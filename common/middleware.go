@@ -0,0 +1,95 @@
+package common
+
+import (
+	"context"
+	"errors"
+)
+
+/*
+ * Invocation describes a resolved JSON-RPC call passed through the middleware chain.
+ *
+ * Fields:
+ *   Context context.Context - Context of the request, carrying the Peer and the RequestInfo
+ *   Id      any             - Request ID, nil for notifications
+ *   Method  string          - Method name as sent by the client
+ *   Service string          - Name of the registered service
+ *   Name    string          - Name of the service method
+ *   Params  any             - Pointer to the decoded params, middleware may modify it in place
+ *   Result  any             - Result sent to the client, set by the service method or by a middleware
+ */
+type Invocation struct {
+	Context context.Context
+	Id      any
+	Method  string
+	Service string
+	Name    string
+	Params  any
+	Result  any
+}
+
+/*
+ * Handler processes an invocation.
+ *
+ * Parameters:
+ *   inv *Invocation - Invocation to process
+ *
+ * Returns:
+ *   error - *Error sent verbatim, any other error is sent as a custom error with its message
+ */
+type Handler func(inv *Invocation) error
+
+/*
+ * Middleware wraps a handler, it may act before and after calling next or skip it.
+ *
+ * Parameters:
+ *   next Handler - Next handler of the chain
+ *
+ * Returns:
+ *   Handler - Wrapping handler
+ */
+type Middleware func(next Handler) Handler
+
+/*
+ * Use appends middleware to the chain; the first one added is the outermost.
+ *
+ * Parameters:
+ *   mw ...Middleware - Middleware to append
+ */
+func (svr *Server) Use(mw ...Middleware) {
+	svr.Middlewares = append(svr.Middlewares, mw...)
+}
+
+/*
+ * Chain wraps a handler with the middleware.
+ *
+ * Parameters:
+ *   h Handler - Innermost handler
+ *
+ * Returns:
+ *   Handler - Handler running the middleware in order and then h
+ */
+func (svr *Server) Chain(h Handler) Handler {
+	for i := len(svr.Middlewares) - 1; i >= 0; i-- {
+		h = svr.Middlewares[i](h)
+	}
+	return h
+}
+
+/*
+ * errorResponse creates the error response of an error returned by the chain.
+ *
+ * Parameters:
+ *   id      any    - Request ID
+ *   jsonRpc string - JSON-RPC version
+ *   err     error  - Error returned by a handler
+ *
+ * Returns:
+ *   any - Error response structure, keeping the code, message and data of an *Error
+ */
+func errorResponse(id any, jsonRpc string, err error) any {
+	var e *Error
+	if errors.As(err, &e) {
+		return EE(id, jsonRpc, *e)
+	}
+	return CE(id, jsonRpc, err.Error())
+}
@@ -0,0 +1,359 @@
+package common
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+/*
+ * NotificationMethod is the method of the notifications sent to the subscribers,
+ * their params are {"subscription": id, "result": data}.
+ */
+const NotificationMethod = "notification"
+
+/*
+ * UnsubscribeMethod is the method ending a subscription, its params are [id].
+ */
+const UnsubscribeMethod = "unsubscribe"
+
+/*
+ * ErrSubscriptionClosed is returned by Notify after the subscription ended.
+ */
+var ErrSubscriptionClosed = errors.New("jsonrpc4go: subscription closed")
+
+/*
+ * Subscriptions holds the subscriptions of a connection, it is created by the persistent transports.
+ *
+ * Fields:
+ *   send func(b []byte) error    - Function writing a message to the connection
+ *   mu   sync.Mutex              - Lock protecting the subscriptions
+ *   subs map[string]*Subscription - Map of subscription ids to subscriptions
+ *   closed bool                  - Whether the connection is gone
+ */
+type Subscriptions struct {
+	send   func(b []byte) error
+	mu     sync.Mutex
+	subs   map[string]*Subscription
+	closed bool
+}
+
+/*
+ * Notifier creates subscriptions for the request being processed, see NotifierFromContext.
+ *
+ * Fields:
+ *   subs    *Subscriptions  - Subscriptions of the connection
+ *   mu      sync.Mutex      - Lock protecting the fields below
+ *   created []*Subscription - Subscriptions created by the request, activated once its response is sent
+ *   active  bool            - Whether the response has been sent
+ */
+type Notifier struct {
+	subs    *Subscriptions
+	mu      sync.Mutex
+	created []*Subscription
+	active  bool
+}
+
+/*
+ * Subscription sends notifications to the client which created it.
+ *
+ * Fields:
+ *   ID     string          - Subscription id, returned to the client as the result of the subscribing method
+ *   subs   *Subscriptions  - Subscriptions of the connection
+ *   mu     sync.Mutex      - Lock protecting the fields below
+ *   queued [][]byte        - Notifications sent before the response of the subscribing request
+ *   active bool            - Whether the response of the subscribing request has been sent
+ *   done   chan struct{}   - Channel closed when the subscription ends
+ *   closed bool            - Whether the subscription ended
+ */
+type Subscription struct {
+	ID     string
+	subs   *Subscriptions
+	mu     sync.Mutex
+	queued [][]byte
+	active bool
+	done   chan struct{}
+	closed bool
+}
+
+/*
+ * notification is the message sent for a subscription.
+ *
+ * Fields:
+ *   JsonRpc string             - JSON-RPC version
+ *   Method  string             - NotificationMethod
+ *   Params  notificationParams - Subscription id and data
+ */
+type notification struct {
+	JsonRpc string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  notificationParams `json:"params"`
+}
+
+/*
+ * notificationParams are the params of a notification.
+ *
+ * Fields:
+ *   Subscription string - Subscription id
+ *   Result       any    - Data sent by the service
+ */
+type notificationParams struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
+}
+
+/*
+ * NewSubscriptions creates the subscriptions of a connection.
+ *
+ * Parameters:
+ *   send func(b []byte) error - Function writing a message to the connection, safe for concurrent use
+ *
+ * Returns:
+ *   *Subscriptions - Subscriptions of the connection
+ */
+func NewSubscriptions(send func(b []byte) error) *Subscriptions {
+	return &Subscriptions{send: send, subs: make(map[string]*Subscription)}
+}
+
+/*
+ * Context returns a copy of the context carrying a Notifier for a request. The subscriptions created
+ * by the request queue their notifications until activate is called, after its response is sent.
+ *
+ * Parameters:
+ *   ctx context.Context - Context of the request
+ *
+ * Returns:
+ *   context.Context - Context carrying the Notifier
+ *   func()          - Function activating the subscriptions created by the request
+ */
+func (s *Subscriptions) Context(ctx context.Context) (context.Context, func()) {
+	n := &Notifier{subs: s}
+	return context.WithValue(ctx, notifierKey, n), n.activate
+}
+
+/*
+ * Close ends the subscriptions when the connection goes away.
+ */
+func (s *Subscriptions) Close() {
+	s.mu.Lock()
+	s.closed = true
+	subs := s.subs
+	s.subs = make(map[string]*Subscription)
+	s.mu.Unlock()
+	for _, sub := range subs {
+		sub.end()
+	}
+}
+
+/*
+ * Unsubscribe ends a subscription of the connection.
+ *
+ * Parameters:
+ *   id string - Subscription id
+ *
+ * Returns:
+ *   bool - Whether the subscription existed
+ */
+func (s *Subscriptions) Unsubscribe(id string) bool {
+	s.mu.Lock()
+	sub, ok := s.subs[id]
+	delete(s.subs, id)
+	s.mu.Unlock()
+	if ok {
+		sub.end()
+	}
+	return ok
+}
+
+/*
+ * NotifierFromContext returns the Notifier of the request, only persistent transports (tcp, ws, wss, unix and stdio) carry one.
+ *
+ * Parameters:
+ *   ctx context.Context - Context passed to a service method
+ *
+ * Returns:
+ *   *Notifier - Notifier of the request
+ *   bool      - Whether the transport supports notifications
+ */
+func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
+	n, ok := ctx.Value(notifierKey).(*Notifier)
+	return n, ok
+}
+
+/*
+ * withRequestNotifier gives a single request of a message its own Notifier, so that the subscriptions it creates
+ * are kept only if the request succeeds.
+ *
+ * Parameters:
+ *   ctx context.Context - Context of the message, carrying its Notifier on persistent transports
+ *
+ * Returns:
+ *   context.Context - Context carrying the Notifier of the request
+ *   func(ok bool)   - Function settling the subscriptions once the response is known: they are handed to the
+ *                     Notifier of the message when ok, ended and removed otherwise
+ */
+func withRequestNotifier(ctx context.Context) (context.Context, func(ok bool)) {
+	parent, ok := NotifierFromContext(ctx)
+	if !ok {
+		return ctx, func(ok bool) {}
+	}
+	n := &Notifier{subs: parent.subs}
+	return context.WithValue(ctx, notifierKey, n), func(ok bool) {
+		n.mu.Lock()
+		n.active = true
+		created := n.created
+		n.created = nil
+		n.mu.Unlock()
+		if !ok {
+			for _, sub := range created {
+				n.subs.Unsubscribe(sub.ID)
+			}
+			return
+		}
+		parent.mu.Lock()
+		active := parent.active
+		if !active {
+			parent.created = append(parent.created, created...)
+		}
+		parent.mu.Unlock()
+		if active {
+			for _, sub := range created {
+				sub.activate()
+			}
+		}
+	}
+}
+
+/*
+ * CreateSubscription creates a subscription, the service method returns its ID as the result.
+ * Notifications sent before the response are delivered after it. The subscription is ended when the request
+ * fails or is a notification, the client never learns its id.
+ *
+ * Returns:
+ *   *Subscription - New subscription, already ended when the connection is gone
+ */
+func (n *Notifier) CreateSubscription() *Subscription {
+	b := make([]byte, 16)
+	rand.Read(b)
+	sub := &Subscription{ID: hex.EncodeToString(b), subs: n.subs, done: make(chan struct{})}
+	n.mu.Lock()
+	sub.active = n.active
+	if !n.active {
+		n.created = append(n.created, sub)
+	}
+	n.mu.Unlock()
+	n.subs.mu.Lock()
+	closed := n.subs.closed
+	if !closed {
+		n.subs.subs[sub.ID] = sub
+	}
+	n.subs.mu.Unlock()
+	if closed {
+		sub.end()
+	}
+	return sub
+}
+
+/*
+ * activate sends the notifications queued by the subscriptions created by the request.
+ */
+func (n *Notifier) activate() {
+	n.mu.Lock()
+	n.active = true
+	created := n.created
+	n.created = nil
+	n.mu.Unlock()
+	for _, sub := range created {
+		sub.activate()
+	}
+}
+
+/*
+ * Notify sends data to the subscriber.
+ *
+ * Parameters:
+ *   data any - Data sent as the result of the notification
+ *
+ * Returns:
+ *   error - ErrSubscriptionClosed after the subscription ended, or the error writing to the connection
+ */
+func (sub *Subscription) Notify(data any) error {
+	b, err := json.Marshal(notification{JsonRpc, NotificationMethod, notificationParams{sub.ID, data}})
+	if err != nil {
+		return err
+	}
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return ErrSubscriptionClosed
+	}
+	if !sub.active {
+		sub.queued = append(sub.queued, b)
+		return nil
+	}
+	return sub.subs.send(b)
+}
+
+/*
+ * Done returns a channel closed when the client unsubscribes or the connection goes away.
+ *
+ * Returns:
+ *   <-chan struct{} - Channel closed when the subscription ends
+ */
+func (sub *Subscription) Done() <-chan struct{} {
+	return sub.done
+}
+
+/*
+ * activate sends the queued notifications.
+ */
+func (sub *Subscription) activate() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	sub.active = true
+	for _, b := range sub.queued {
+		if sub.closed || sub.subs.send(b) != nil {
+			break
+		}
+	}
+	sub.queued = nil
+}
+
+/*
+ * end ends the subscription.
+ */
+func (sub *Subscription) end() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if !sub.closed {
+		sub.closed = true
+		sub.queued = nil
+		close(sub.done)
+	}
+}
+
+/*
+ * unsubscribe is the method ending a subscription of the connection.
+ *
+ * Parameters:
+ *   ctx    context.Context - Context of the request
+ *   params *[]string       - Subscription id
+ *   result *bool           - Whether the subscription existed
+ *
+ * Returns:
+ *   error - Method not found on transports without notifications
+ */
+func unsubscribe(ctx context.Context, params *[]string, result *bool) error {
+	n, ok := NotifierFromContext(ctx)
+	if !ok {
+		return &Error{MethodNotFound, CodeMap[MethodNotFound], nil}
+	}
+	if len(*params) != 1 {
+		return &Error{InvalidParams, CodeMap[InvalidParams], "params must hold the subscription id"}
+	}
+	*result = n.subs.Unsubscribe((*params)[0])
+	return nil
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+/*
+ * PipeConn turns a reader and a writer, such as stdin and stdout or the pipes of a child process,
+ * into a connection for the stdio transport.
+ *
+ * Fields:
+ *   r    io.Reader - Reader receiving the messages
+ *   w    io.Writer - Writer sending the messages
+ *   once sync.Once - Once closing the reader and the writer
+ */
+type PipeConn struct {
+	r    io.Reader
+	w    io.Writer
+	once sync.Once
+}
+
+/*
+ * pipeAddr is the address of both ends of a PipeConn.
+ */
+type pipeAddr struct{}
+
+/*
+ * NewPipeConn creates a connection reading from r and writing to w.
+ *
+ * Parameters:
+ *   r io.Reader - Reader receiving the messages, closed with the connection when it is an io.Closer
+ *   w io.Writer - Writer sending the messages, closed with the connection when it is an io.Closer other than os.Stdout
+ *
+ * Returns:
+ *   *PipeConn - Connection
+ */
+func NewPipeConn(r io.Reader, w io.Writer) *PipeConn {
+	return &PipeConn{r: r, w: w}
+}
+
+/*
+ * Read reads from the reader.
+ */
+func (c *PipeConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+/*
+ * Write writes to the writer.
+ */
+func (c *PipeConn) Write(b []byte) (int, error) {
+	return c.w.Write(b)
+}
+
+/*
+ * Close closes the reader, unblocking a pending read, and the writer. The stdout of the process is left open,
+ * the program may still write to it.
+ *
+ * Returns:
+ *   error - Error closing the reader, or else the writer
+ */
+func (c *PipeConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		if r, ok := c.r.(io.Closer); ok {
+			err = r.Close()
+		}
+		if c.w == os.Stdout {
+			return
+		}
+		if w, ok := c.w.(io.Closer); ok {
+			if e := w.Close(); err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
+
+/*
+ * LocalAddr returns the stdio address.
+ */
+func (c *PipeConn) LocalAddr() net.Addr {
+	return pipeAddr{}
+}
+
+/*
+ * RemoteAddr returns the stdio address.
+ */
+func (c *PipeConn) RemoteAddr() net.Addr {
+	return pipeAddr{}
+}
+
+/*
+ * SetDeadline sets the read and write deadlines, when the reader and the writer support them (e.g. *os.File pipes).
+ */
+func (c *PipeConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+/*
+ * SetReadDeadline sets the read deadline, when the reader supports it.
+ */
+func (c *PipeConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
+
+/*
+ * SetWriteDeadline sets the write deadline, when the writer supports it.
+ */
+func (c *PipeConn) SetWriteDeadline(t time.Time) error {
+	if d, ok := c.w.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+/*
+ * Network returns the network name.
+ */
+func (a pipeAddr) Network() string {
+	return "stdio"
+}
+
+/*
+ * String returns the address.
+ */
+func (a pipeAddr) String() string {
+	return "stdio"
+}
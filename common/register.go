@@ -0,0 +1,261 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+/*
+ * RegisterOptions configures which methods of a service are exposed and under which names.
+ *
+ * Fields:
+ *   Aliases map[string]string - Map of Go method names to the names exposed instead
+ *   Exclude map[string]bool   - Go method names which are not exposed
+ */
+type RegisterOptions struct {
+	Aliases map[string]string
+	Exclude map[string]bool
+}
+
+/*
+ * RegisterOption sets a registration option.
+ *
+ * Parameters:
+ *   o *RegisterOptions - Options to modify
+ */
+type RegisterOption func(o *RegisterOptions)
+
+/*
+ * WithAlias exposes a method under another name, e.g. WithAlias("BlockNumber", "eth_blockNumber").
+ *
+ * Parameters:
+ *   method string - Go method name
+ *   alias  string - Name exposed instead, prefixed by the service name unless the service is flat
+ *
+ * Returns:
+ *   RegisterOption - Registration option
+ */
+func WithAlias(method string, alias string) RegisterOption {
+	return func(o *RegisterOptions) {
+		if o.Aliases == nil {
+			o.Aliases = make(map[string]string)
+		}
+		o.Aliases[method] = alias
+	}
+}
+
+/*
+ * WithExclude keeps methods matching the signature from being exposed.
+ *
+ * Parameters:
+ *   methods ...string - Go method names
+ *
+ * Returns:
+ *   RegisterOption - Registration option
+ */
+func WithExclude(methods ...string) RegisterOption {
+	return func(o *RegisterOptions) {
+		if o.Exclude == nil {
+			o.Exclude = make(map[string]bool)
+		}
+		for _, m := range methods {
+			o.Exclude[m] = true
+		}
+	}
+}
+
+/*
+ * Route is a method resolved from the name sent by the client.
+ *
+ * Fields:
+ *   Service *Service - Service of the method
+ *   Method  *Method  - Method to call
+ *   Name    string   - Exposed method name, without the service name
+ */
+type Route struct {
+	Service *Service
+	Method  *Method
+	Name    string
+}
+
+/*
+ * RegisterName registers a service under a name, its methods are exposed as name.Method.
+ * The name may hold dots for nested namespaces, e.g. v1.users, or be empty to expose the methods
+ * without a service prefix.
+ *
+ * Parameters:
+ *   name string             - Service name, empty for flat method names
+ *   s    any                - Service instance to register
+ *   opts ...RegisterOption  - Aliases and excluded methods
+ *
+ * Returns:
+ *   error - Error if the service or one of its method names is already registered, or an option names an unknown method
+ */
+func (svr *Server) RegisterName(name string, s any, opts ...RegisterOption) error {
+	o := RegisterOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	svc := new(Service)
+	svc.V = reflect.ValueOf(s)
+	svc.T = reflect.TypeOf(s)
+	svc.Name = name
+	methods := RegisterMethods(svc.T)
+	for m := range o.Aliases {
+		if _, ok := methods[m]; !ok {
+			return fmt.Errorf("rpc: alias of unknown method: %s", m)
+		}
+	}
+	svc.Mm = make(map[string]*Method, len(methods))
+	for m, mt := range methods {
+		if o.Exclude[m] {
+			continue
+		}
+		if alias, ok := o.Aliases[m]; ok {
+			m = alias
+		}
+		if _, ok := svc.Mm[m]; ok {
+			return fmt.Errorf("rpc: method already defined: %s", qualify(name, m))
+		}
+		svc.Mm[m] = mt
+	}
+	for m := range o.Exclude {
+		if _, ok := methods[m]; !ok {
+			return fmt.Errorf("rpc: exclusion of unknown method: %s", m)
+		}
+	}
+	return svr.addRoutes(svc)
+}
+
+/*
+ * RegisterFunc registers a function as a method, e.g. RegisterFunc("eth_blockNumber", blockNumber).
+ * The function has the signature of a service method without the receiver,
+ * either func(params *P, result *R) error or func(ctx context.Context, params *P, result *R) error.
+ *
+ * Parameters:
+ *   name string - Method name, it may hold dots for nested namespaces
+ *   fn   any    - Function to register
+ *
+ * Returns:
+ *   error - Error if the function does not conform or the name is already registered
+ */
+func (svr *Server) RegisterFunc(name string, fn any) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Errorf("rpc: %s is not a function: %T", name, fn)
+	}
+	m := newMethod(reflect.Method{Name: name, Type: v.Type(), Func: v}, 0)
+	if m == nil {
+		return fmt.Errorf("rpc: function %s needs the signature func([ctx context.Context, ]params *P, result *R) error", name)
+	}
+	return svr.addRoutes(&Service{T: v.Type(), Mm: map[string]*Method{name: m}})
+}
+
+/*
+ * addRoutes adds the routes of a service, none of them when one of its names is already registered.
+ *
+ * Parameters:
+ *   svc *Service - Service to add
+ *
+ * Returns:
+ *   error - Error if the service or one of its method names is already registered, or if the validate tags of its params are invalid
+ */
+func (svr *Server) addRoutes(svc *Service) error {
+	svr.registerMu.Lock()
+	defer svr.registerMu.Unlock()
+	if svc.Name != "" {
+		if _, ok := svr.Sm.Load(svc.Name); ok {
+			return errors.New("rpc: service already defined: " + svc.Name)
+		}
+	}
+	for m, mt := range svc.Mm {
+		if _, ok := svr.Routes.Load(qualify(svc.Name, m)); ok {
+			return errors.New("rpc: method already defined: " + qualify(svc.Name, m))
+		}
+		if err := CheckValidateTags(mt.ParamsType); err != nil {
+			return fmt.Errorf("rpc: params of %s: %w", qualify(svc.Name, m), err)
+		}
+	}
+	// Flat methods and functions are not services, they are not registered with the discovery service.
+	if svc.Name != "" {
+		svr.Sm.Store(svc.Name, svc)
+	}
+	for m, mt := range svc.Mm {
+		svr.Routes.Store(qualify(svc.Name, m), &Route{svc, mt, m})
+	}
+	return nil
+}
+
+/*
+ * route resolves the method sent by the client. The full method name is looked up first, with
+ * slashes read as dots, then the service and method names split by ParseRequestMethod, so that
+ * hello_world.Method still reaches the HelloWorld service.
+ *
+ * Parameters:
+ *   method string - Method name as sent by the client
+ *
+ * Returns:
+ *   *Route - Resolved method
+ *   bool   - Whether the method was found
+ */
+func (svr *Server) route(method string) (*Route, bool) {
+	name := method
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "/") {
+		name = name[1:]
+	}
+	if r, ok := svr.Routes.Load(name); ok {
+		return r.(*Route), true
+	}
+	if r, ok := svr.Routes.Load(strings.ReplaceAll(name, "/", ".")); ok {
+		return r.(*Route), true
+	}
+	if name == UnsubscribeMethod {
+		return unsubscribeRoute(), true
+	}
+	sName, mName, err := ParseRequestMethod(method)
+	if err != nil {
+		return nil, false
+	}
+	s, ok := svr.Sm.Load(sName)
+	if !ok {
+		sName = lineToHump(sName) // support HelloWorld and hello_world
+		s, ok = svr.Sm.Load(sName)
+		if !ok {
+			return nil, false
+		}
+	}
+	m, ok := s.(*Service).Mm[mName]
+	if !ok {
+		return nil, false
+	}
+	return &Route{s.(*Service), m, mName}, true
+}
+
+/*
+ * unsubscribeRoute is the route of the unsubscribe method, used unless a method of that name is registered.
+ */
+var unsubscribeRoute = sync.OnceValue(func() *Route {
+	fn := reflect.ValueOf(unsubscribe)
+	m := newMethod(reflect.Method{Name: UnsubscribeMethod, Type: fn.Type(), Func: fn}, 0)
+	return &Route{&Service{T: fn.Type(), Mm: map[string]*Method{UnsubscribeMethod: m}}, m, UnsubscribeMethod}
+})
+
+/*
+ * qualify prefixes a method name with its service name.
+ *
+ * Parameters:
+ *   service string - Service name, empty for flat methods
+ *   method  string - Method name
+ *
+ * Returns:
+ *   string - Full method name
+ */
+func qualify(service string, method string) string {
+	if service == "" {
+		return method
+	}
+	return service + "." + method
+}
@@ -1,9 +1,11 @@
 package common
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 )
@@ -21,6 +23,7 @@ var RequiredFields = map[string]string{
 
 /**
  * @Description: Single request structure
+ * @Field Id: Request ID, set when the batch is sent, nil for notifications
  * @Field Method: Method name
  * @Field Params: Parameters
  * @Field Result: Result
@@ -28,6 +31,7 @@ var RequiredFields = map[string]string{
  * @Field IsNotify: Whether it is a notification
  */
 type SingleRequest struct {
+	Id       any
 	Method   string
 	Params   any
 	Result   any
@@ -37,13 +41,13 @@ type SingleRequest struct {
 
 /**
  * @Description: Request structure
- * @Field Id: Request ID
+ * @Field Id: Request ID, a string, a number or null
  * @Field JsonRpc: JSON-RPC version
  * @Field Method: Method name
  * @Field Params: Parameters
  */
 type Request struct {
-	Id      string `json:"id"`
+	Id      any    `json:"id"`
 	JsonRpc string `json:"jsonrpc"`
 	Method  string `json:"method"`
 	Params  any    `json:"params"`
@@ -73,11 +77,16 @@ func ParseRequestMethod(method string) (sName string, mName string, err error) {
 		m  string
 		sp int
 	)
+	msg := "rpc: method request ill-formed: %s; need x.y or x/y"
+	if method == "" {
+		m = fmt.Sprintf(msg, method)
+		Debug(m)
+		return sName, mName, errors.New(m)
+	}
 	first := method[0:1]
 	if first == "." || first == "/" {
 		method = method[1:]
 	}
-	msg := "rpc: method request ill-formed: %s; need x.y or x/y"
 	if strings.Count(method, ".") != 1 && strings.Count(method, "/") != 1 {
 		m = fmt.Sprintf(msg, method)
 		Debug(m)
@@ -120,35 +129,108 @@ func FilterRequestBody(jsonMap map[string]any) map[string]any {
 }
 
 /**
- * @Description: Parse single request body
+ * @Description: Parse single request body, the request must follow JSON-RPC 2.0
  * @Param jsonMap: JSON map
- * @Return id: Request ID
+ * @Return id: Request ID as raw JSON to be echoed back verbatim, nil for notifications
  * @Return jsonrpc: JSON-RPC version
  * @Return method: Method name
- * @Return params: Parameters
+ * @Return params: Parameters as raw JSON, nil when omitted
  * @Return errCode: Error code
  */
 func ParseSingleRequestBody(jsonMap map[string]any) (id any, jsonrpc string, method string, params any, errCode int) {
-	jsonMap = FilterRequestBody(jsonMap)
-	if _, ok := jsonMap["id"]; !ok {
-		st := NotifyRequest{}
-		err := GetStruct(jsonMap, &st)
-		if err != nil {
+	return parseSingleRequestBody(rawRequestBody(jsonMap), false)
+}
+
+/**
+ * @Description: Parse single request body, accepting legacy requests without or with another JSON-RPC version
+ * @Param jsonMap: JSON map
+ * @Return id: Request ID as raw JSON to be echoed back verbatim, nil for notifications including a legacy null id
+ * @Return jsonrpc: JSON-RPC version
+ * @Return method: Method name
+ * @Return params: Parameters as raw JSON, nil when omitted
+ * @Return errCode: Error code
+ */
+func ParseLenientSingleRequestBody(jsonMap map[string]any) (id any, jsonrpc string, method string, params any, errCode int) {
+	return parseSingleRequestBody(rawRequestBody(jsonMap), true)
+}
+
+/**
+ * @Description: Encode the members of a parsed request, json.Number values are encoded verbatim
+ * @Param jsonMap: JSON map
+ * @Return map[string]json.RawMessage: Request members as raw JSON
+ */
+func rawRequestBody(jsonMap map[string]any) map[string]json.RawMessage {
+	raw := make(map[string]json.RawMessage)
+	for k, v := range FilterRequestBody(jsonMap) {
+		if b, err := json.Marshal(v); err == nil {
+			raw[k] = b
+		}
+	}
+	return raw
+}
+
+/**
+ * @Description: Validate and parse single request body
+ * @Param raw: Request members as raw JSON
+ * @Param lenient: Whether to accept legacy requests
+ * @Return id: Request ID as raw JSON, nil for notifications and when the id is invalid
+ * @Return jsonrpc: JSON-RPC version
+ * @Return method: Method name
+ * @Return params: Parameters as raw JSON, nil when omitted
+ * @Return errCode: InvalidRequest when a member is missing or has a wrong type
+ */
+func parseSingleRequestBody(raw map[string]json.RawMessage, lenient bool) (id any, jsonrpc string, method string, params any, errCode int) {
+	errCode = WithoutError
+	rawId, hasId := raw["id"]
+	if hasId {
+		// An id which is not a string, a number or null can not be echoed back.
+		switch kind(rawId) {
+		case '"', '0', 'n':
+			id = rawId
+		default:
 			errCode = InvalidRequest
 		}
-		return nil, st.JsonRpc, st.Method, st.Params, errCode
-	} else {
-		st := Request{}
-		err := GetStruct(jsonMap, &st)
-		if err != nil {
+	}
+	if err := json.Unmarshal(raw["jsonrpc"], &jsonrpc); lenient {
+		// JSON-RPC 1.0 notifications have a null id.
+		if jsonrpc != JsonRpc && kind(rawId) == 'n' {
+			id = nil
+		}
+	} else if err != nil || jsonrpc != JsonRpc {
+		errCode = InvalidRequest
+	}
+	if err := json.Unmarshal(raw["method"], &method); err != nil || method == "" {
+		errCode = InvalidRequest
+	}
+	if rawParams, ok := raw["params"]; ok {
+		switch kind(rawParams) {
+		case '{', '[':
+			params = rawParams
+		default:
 			errCode = InvalidRequest
 		}
-		return st.Id, st.JsonRpc, st.Method, st.Params, errCode
 	}
+	return id, jsonrpc, method, params, errCode
+}
+
+/**
+ * @Description: Get the kind of a raw JSON value
+ * @Param raw: Raw JSON value
+ * @Return byte: '{', '[', '"', '0' for numbers, 't' and 'f' for booleans, 'n' for null, 0 when empty
+ */
+func kind(raw json.RawMessage) byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return 0
+	}
+	if raw[0] == '-' || (raw[0] >= '0' && raw[0] <= '9') {
+		return '0'
+	}
+	return raw[0]
 }
 
 /**
- * @Description: Parse request body
+ * @Description: Parse request body, numbers are kept as json.Number so that ids are echoed back verbatim
  * @Param b: Request data
  * @Return any: Parsed data
  * @Return error: Error message
@@ -156,7 +238,14 @@ func ParseSingleRequestBody(jsonMap map[string]any) (id any, jsonrpc string, met
 func ParseRequestBody(b []byte) (any, error) {
 	var err error
 	var jsonData any
-	err = json.Unmarshal(b, &jsonData)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err = d.Decode(&jsonData)
+	if err == nil {
+		if _, e := d.Token(); e != io.EOF {
+			err = errors.New("json: invalid character after top-level value")
+		}
+	}
 	if err != nil {
 		Debug(err)
 	}
@@ -232,7 +321,7 @@ func GetStruct(d any, s any) error {
 func Rs(id any, method string, params any) any {
 	var req any
 	if id != nil {
-		req = Request{id.(string), JsonRpc, method, params}
+		req = Request{id, JsonRpc, method, params}
 	} else {
 		req = NotifyRequest{JsonRpc, method, params}
 	}
@@ -1,8 +1,9 @@
 package common
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"reflect"
 )
 
@@ -13,7 +14,7 @@ import (
  * @Field Result: Result
  */
 type SuccessResponse struct {
-	Id      string `json:"id"`
+	Id      any    `json:"id"`
 	JsonRpc string `json:"jsonrpc"`
 	Result  any    `json:"result"`
 }
@@ -40,6 +41,26 @@ type Error struct {
 	Data    any    `json:"data"`
 }
 
+/**
+ * @Description: Create an error which service methods, hooks and middleware return to send its code, message and data as is
+ * @Param code: Error code, -32000 to -32099 are reserved for implementation-defined server errors
+ * @Param message: Error message
+ * @Param data: Additional information about the error, nil to send null
+ * @Return *Error: Error structure pointer
+ */
+func NewError(code int, message string, data any) *Error {
+	return &Error{Code: code, Message: message, Data: data}
+}
+
+/**
+ * @Description: Get the error message
+ * @Receiver e: Error structure pointer
+ * @Return string: Error message
+ */
+func (e *Error) Error() string {
+	return e.Message
+}
+
 /**
  * @Description: Error response structure
  * @Field Id: Request ID
@@ -47,7 +68,7 @@ type Error struct {
  * @Field Error: Error information
  */
 type ErrorResponse struct {
-	Id      string `json:"id"`
+	Id      any    `json:"id"`
 	JsonRpc string `json:"jsonrpc"`
 	Error   Error  `json:"error"`
 }
@@ -75,13 +96,7 @@ func E(id any, jsonRpc string, errCode int) any {
 		CodeMap[errCode],
 		nil,
 	}
-	var res any
-	if id != nil {
-		res = ErrorResponse{id.(string), jsonRpc, e}
-	} else {
-		res = ErrorNotifyResponse{jsonRpc, e}
-	}
-	return res
+	return EE(id, jsonRpc, e)
 }
 
 /**
@@ -97,13 +112,18 @@ func CE(id any, jsonRpc string, errMessage string) any {
 		errMessage,
 		nil,
 	}
-	var res any
-	if id != nil {
-		res = ErrorResponse{id.(string), jsonRpc, e}
-	} else {
-		res = ErrorNotifyResponse{jsonRpc, e}
-	}
-	return res
+	return EE(id, jsonRpc, e)
+}
+
+/**
+ * @Description: Create error response from an error structure
+ * @Param id: Request ID, nil when it could not be read from the request
+ * @Param jsonRpc: JSON-RPC version
+ * @Param e: Error information
+ * @Return any: Error response structure, the id is null when it could not be read from the request
+ */
+func EE(id any, jsonRpc string, e Error) any {
+	return ErrorResponse{id, jsonRpc, e}
 }
 
 /**
@@ -116,7 +136,7 @@ func CE(id any, jsonRpc string, errMessage string) any {
 func S(id any, jsonRpc string, result any) any {
 	var res any
 	if id != nil {
-		res = SuccessResponse{id.(string), jsonRpc, result}
+		res = SuccessResponse{id, jsonRpc, result}
 	} else {
 		res = SuccessNotifyResponse{jsonRpc, result}
 	}
@@ -139,7 +159,7 @@ func jsonE(id any, jsonRpc string, errCode int) []byte {
  * @Description: Get single response
  * @Param jsonData: JSON data
  * @Param result: Result
- * @Return error: Error information
+ * @Return error: Error information, an *Error keeping the code, message and data of an error response
  */
 func GetSingleResponse(jsonData map[string]any, result any) error {
 	var (
@@ -147,10 +167,18 @@ func GetSingleResponse(jsonData map[string]any, result any) error {
 	)
 	emData, ok := jsonData["error"]
 	if ok {
+		// The data member is optional.
 		resErr := new(Error)
-		err = GetStruct(emData, resErr)
+		b, err := json.Marshal(emData)
+		if err == nil {
+			err = json.Unmarshal(b, resErr)
+		}
+		if err != nil {
+			Debug(err)
+			return err
+		}
 		Debug(resErr.Message)
-		return errors.New(resErr.Message)
+		return resErr
 	}
 	jsonStr, err := json.Marshal(jsonData["result"])
 	if err != nil {
@@ -168,7 +196,7 @@ func GetSingleResponse(jsonData map[string]any, result any) error {
 /**
  * @Description: Get result
  * @Param b: Response data
- * @Param result: Result
+ * @Param result: Result, or the batch request list whose responses are matched by id
  * @Return error: Error information
  */
 func GetResult(b []byte, result any) error {
@@ -176,26 +204,83 @@ func GetResult(b []byte, result any) error {
 		err      error
 		jsonData any
 	)
-	err = json.Unmarshal(b, &jsonData)
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err = d.Decode(&jsonData)
 	if err != nil {
 		Debug(err)
 	}
+	requests, batch := result.([]*SingleRequest)
 	if reflect.ValueOf(jsonData).Kind() == reflect.Map {
 		err = GetSingleResponse(jsonData.(map[string]any), result)
 		if err != nil {
+			if batch {
+				// The server rejected the whole batch, e.g. it could not be parsed.
+				unanswered(requests, nil, err)
+			}
 			return err
 		}
 	} else if reflect.ValueOf(jsonData).Kind() == reflect.Slice {
-		for k, v := range jsonData.([]any) {
-			err = GetSingleResponse(v.(map[string]any), (result.([]*SingleRequest)[k].Result))
+		// Notifications are not answered, the other requests are matched by id.
+		index := make(map[string]*SingleRequest)
+		for _, r := range requests {
+			if !r.IsNotify {
+				index[idKey(r.Id)] = r
+			}
+		}
+		var batchErr error
+		answered := make(map[*SingleRequest]bool)
+		for _, v := range jsonData.([]any) {
+			m, _ := v.(map[string]any)
+			r, ok := index[idKey(m["id"])]
+			if !ok || answered[r] {
+				// A response with an unknown or null id cannot be given to any request, it fails the batch.
+				if batchErr = GetSingleResponse(m, new(any)); batchErr == nil {
+					batchErr = NewError(InternalError, fmt.Sprintf("Response for unknown id %s", idKey(m["id"])), nil)
+				}
+				continue
+			}
+			answered[r] = true
+			err = GetSingleResponse(m, r.Result)
 			if err != nil {
-				*(result.([]*SingleRequest)[k].Error) = err
+				*(r.Error) = err
 			}
 		}
+		unanswered(requests, answered, batchErr)
+		return batchErr
 	}
 	return nil
 }
 
+/**
+ * @Description: Set an error on every request of a batch left without a response
+ * @Param requests: Batch request list
+ * @Param answered: Requests a response was given to
+ * @Param err: Error set on the requests, a "No response for id" error when nil
+ */
+func unanswered(requests []*SingleRequest, answered map[*SingleRequest]bool, err error) {
+	for _, r := range requests {
+		if r.IsNotify || answered[r] {
+			continue
+		}
+		e := err
+		if e == nil {
+			e = NewError(InternalError, fmt.Sprintf("No response for id %s", idKey(r.Id)), nil)
+		}
+		*(r.Error) = e
+	}
+}
+
+/**
+ * @Description: Get the key comparing a request id with a response id
+ * @Param id: Request ID, or the response ID decoded with json.Number
+ * @Return string: JSON encoding of the id
+ */
+func idKey(id any) string {
+	b, _ := json.Marshal(id)
+	return string(b)
+}
+
 /**
  * @Description: Parse response body
  * @Param b: Response data
@@ -1,10 +1,12 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime/debug"
 	"strings"
 	"sync"
 
@@ -15,26 +17,28 @@ import (
  * Method represents a JSON-RPC method definition.
  *
  * Fields:
- *   Name       string        - Method name
- *   ParamsType reflect.Type  - Type of the method parameters
- *   ResultType reflect.Type  - Type of the method result
- *   Method     reflect.Method - Reflect method object
+ *   Name        string        - Method name
+ *   ParamsType  reflect.Type  - Type of the method parameters
+ *   ResultType  reflect.Type  - Type of the method result
+ *   Method      reflect.Method - Reflect method object
+ *   WithContext bool          - Whether the method takes a context.Context as first argument
  */
 type Method struct {
-	Name       string
-	ParamsType reflect.Type
-	ResultType reflect.Type
-	Method     reflect.Method
+	Name        string
+	ParamsType  reflect.Type
+	ResultType  reflect.Type
+	Method      reflect.Method
+	WithContext bool
 }
 
 /*
  * Service represents a JSON-RPC service containing multiple methods.
  *
  * Fields:
- *   Name string           - Service name
- *   V    reflect.Value    - Reflect value of the service instance
+ *   Name string           - Service name, empty for flat methods and functions
+ *   V    reflect.Value    - Reflect value of the service instance, invalid for functions
  *   T    reflect.Type     - Reflect type of the service instance
- *   Mm   map[string]*Method - Map of method names to Method objects
+ *   Mm   map[string]*Method - Map of exposed method names to Method objects
  */
 type Service struct {
 	Name string
@@ -50,29 +54,48 @@ type Service struct {
  *   Sm          sync.Map      - Map of service names to Service objects
  *   Hooks       Hooks         - Before and after function hooks
  *   RateLimiter *rate.Limiter - Rate limiter for request throttling
+ *   Middlewares []Middleware  - Middleware wrapping every invocation, outermost first
+ *   PanicHandler func(ctx context.Context, p any, stack []byte) - Function called with the value and stack of a recovered panic
+ *   Lenient     bool          - Whether to accept legacy requests without or with another JSON-RPC version
+ *   BindOptions BindOptions   - Options binding the params of the service methods
+ *   BatchParallelism int      - Maximum number of batch elements processed at once, in order when 1 or less
+ *   Routes      sync.Map      - Map of full method names to Route objects
  */
 type Server struct {
-	Sm          sync.Map
-	Hooks       Hooks
-	RateLimiter *rate.Limiter
+	Sm               sync.Map
+	Hooks            Hooks
+	RateLimiter      *rate.Limiter
+	Middlewares      []Middleware
+	PanicHandler     func(ctx context.Context, p any, stack []byte)
+	Lenient          bool
+	BindOptions      BindOptions
+	BatchParallelism int
+	Routes           sync.Map
+	registerMu       sync.Mutex
 }
 
 /*
  * Hooks contains callback functions that are executed before and after method calls.
  *
  * Fields:
- *   BeforeFunc func(id any, method string, params any) error - Function called before processing a request
- *   AfterFunc  func(id any, method string, result any) error - Function called after processing a request
- *   StartFunc  func() - Function called after server starts
+ *   BeforeFunc        func(id any, method string, params any) error - Function called before processing a request
+ *   AfterFunc         func(id any, method string, result any) error - Function called after processing a request
+ *   BeforeContextFunc func(ctx context.Context, id any, method string, params any) error - Context-aware function called before processing a request
+ *   AfterContextFunc  func(ctx context.Context, id any, method string, result any) error - Context-aware function called after processing a request
+ *   StartFunc         func() - Function called after server starts
  */
 type Hooks struct {
-	BeforeFunc func(id any, method string, params any) error
-	AfterFunc  func(id any, method string, result any) error
-	StartFunc  func()
+	BeforeFunc        func(id any, method string, params any) error
+	AfterFunc         func(id any, method string, result any) error
+	BeforeContextFunc func(ctx context.Context, id any, method string, params any) error
+	AfterContextFunc  func(ctx context.Context, id any, method string, result any) error
+	StartFunc         func()
 }
 
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 /*
- * Register registers a service with the server.
+ * Register registers a service with the server under the name of its type.
  *
  * Parameters:
  *   s any - Service instance to register
@@ -81,16 +104,7 @@ type Hooks struct {
  *   error - Error if the service is already registered
  */
 func (svr *Server) Register(s any) error {
-	svc := new(Service)
-	svc.V = reflect.ValueOf(s)
-	svc.T = reflect.TypeOf(s)
-	sname := reflect.Indirect(svc.V).Type().Name()
-	svc.Name = sname
-	svc.Mm = RegisterMethods(svc.T)
-	if _, err := svr.Sm.LoadOrStore(sname, svc); err {
-		return errors.New("rpc: service already defined: " + sname)
-	}
-	return nil
+	return svr.RegisterName(reflect.Indirect(reflect.ValueOf(s)).Type().Name(), s)
 }
 
 /*
@@ -114,7 +128,8 @@ func RegisterMethods(s reflect.Type) map[string]*Method {
 }
 
 /*
- * RegisterMethod registers a single method if it conforms to the JSON-RPC method signature.
+ * RegisterMethod registers a single method if it conforms to the JSON-RPC method signature,
+ * either (params *P, result *R) error or (ctx context.Context, params *P, result *R) error.
  *
  * Parameters:
  *   rm reflect.Method - Reflect method to register
@@ -123,23 +138,47 @@ func RegisterMethods(s reflect.Type) map[string]*Method {
  *   *Method - Method object if registration succeeds, nil otherwise
  */
 func RegisterMethod(rm reflect.Method) *Method {
+	return newMethod(rm, 1)
+}
+
+/*
+ * newMethod checks the signature of a method or a function.
+ *
+ * Parameters:
+ *   rm       reflect.Method - Reflect method, or a function wrapped in a reflect.Method
+ *   receiver int            - Number of receiver arguments, 1 for a method and 0 for a function
+ *
+ * Returns:
+ *   *Method - Method object if the signature conforms, nil otherwise
+ */
+func newMethod(rm reflect.Method, receiver int) *Method {
 	var (
 		msg string
 	)
 	rmt := rm.Type
 	rmn := rm.Name
-	if rm.Type.NumIn() != 3 {
-		msg = fmt.Sprintf("RegisterMethod: method %q has %d input parameters; needs exactly three", rmn, rmt.NumIn())
+	if rm.Type.NumIn() != receiver+2 && rm.Type.NumIn() != receiver+3 {
+		msg = fmt.Sprintf("RegisterMethod: method %q has %d input parameters; needs exactly %d or %d", rmn, rmt.NumIn(), receiver+2, receiver+3)
 		Debug(msg)
 		return nil
 	}
-	p := rmt.In(1)
+	offset := receiver
+	withContext := rm.Type.NumIn() == receiver+3
+	if withContext {
+		if rmt.In(receiver) != contextType {
+			msg = fmt.Sprintf("RegisterMethod: First argument of method %q is not a context.Context:%q", rmn, rmt.In(receiver))
+			Debug(msg)
+			return nil
+		}
+		offset++
+	}
+	p := rmt.In(offset)
 	if p.Kind() != reflect.Ptr {
 		msg = fmt.Sprintf("RegisterMethod: Params type of method %q is not a reflect.Ptr:%q", rmn, p)
 		Debug(msg)
 		return nil
 	}
-	r := rmt.In(2)
+	r := rmt.In(offset + 1)
 	if r.Kind() != reflect.Ptr {
 		msg = fmt.Sprintf("RegisterMethod: Result type of method %q is not a reflect.Ptr:%q", rmn, r)
 		Debug(msg)
@@ -157,7 +196,7 @@ func RegisterMethod(rm reflect.Method) *Method {
 		Debug(msg)
 		return nil
 	}
-	m := &Method{rmn, p, r, rm}
+	m := &Method{rmn, p, r, rm, withContext}
 	return m
 }
 
@@ -168,25 +207,52 @@ func RegisterMethod(rm reflect.Method) *Method {
  *   b []byte - JSON-RPC request data
  *
  * Returns:
- *   []byte - JSON-RPC response data
+ *   []byte - JSON-RPC response data, empty when the request holds only notifications
  */
 func (svr *Server) Handler(b []byte) []byte {
-	data, err := ParseRequestBody(b)
-	if err != nil {
+	return svr.HandlerContext(context.Background(), b)
+}
+
+/*
+ * HandlerContext handles JSON-RPC requests bound to a context and returns responses.
+ *
+ * Parameters:
+ *   ctx context.Context - Context of the connection or HTTP request
+ *   b   []byte          - JSON-RPC request data
+ *
+ * Returns:
+ *   []byte - JSON-RPC response data, empty when the request holds only notifications
+ */
+func (svr *Server) HandlerContext(ctx context.Context, b []byte) []byte {
+	var data json.RawMessage
+	if err := json.Unmarshal(b, &data); err != nil {
+		Debug(err)
 		return jsonE(nil, JsonRpc, ParseError)
 	}
 	var res any
-	if reflect.ValueOf(data).Kind() == reflect.Slice {
+	switch kind(data) {
+	case '[':
+		var list []json.RawMessage
+		json.Unmarshal(data, &list)
+		if len(list) == 0 {
+			return jsonE(nil, JsonRpc, InvalidRequest)
+		}
 		var resList []any
-		for _, v := range data.([]any) {
-			r := svr.SingleHandler(v.(map[string]any))
-			resList = append(resList, r)
+		for _, r := range svr.batchHandler(ctx, list) {
+			if r != nil {
+				resList = append(resList, r)
+			}
+		}
+		if len(resList) == 0 {
+			return nil
 		}
 		res = resList
-	} else if reflect.ValueOf(data).Kind() == reflect.Map {
-		r := svr.SingleHandler(data.(map[string]any))
-		res = r
-	} else {
+	case '{':
+		res = svr.rawHandler(ctx, data)
+		if res == nil {
+			return nil
+		}
+	default:
 		return jsonE(nil, JsonRpc, InvalidRequest)
 	}
 
@@ -194,6 +260,57 @@ func (svr *Server) Handler(b []byte) []byte {
 	return response
 }
 
+/*
+ * batchHandler handles the elements of a batch, BatchParallelism of them at once.
+ *
+ * Parameters:
+ *   ctx  context.Context   - Context of the connection or HTTP request
+ *   list []json.RawMessage - JSON-RPC requests of the batch
+ *
+ * Returns:
+ *   []any - JSON-RPC response objects in the order of the requests, nil for notifications
+ */
+func (svr *Server) batchHandler(ctx context.Context, list []json.RawMessage) []any {
+	results := make([]any, len(list))
+	if svr.BatchParallelism <= 1 {
+		for i, v := range list {
+			results[i] = svr.rawHandler(ctx, v)
+		}
+		return results
+	}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, svr.BatchParallelism)
+	for i, v := range list {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = svr.rawHandler(ctx, v)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+/*
+ * rawHandler handles a single JSON-RPC request as sent by the client.
+ *
+ * Parameters:
+ *   ctx  context.Context - Context of the connection or HTTP request
+ *   data json.RawMessage - JSON-RPC request, the members are decoded once
+ *
+ * Returns:
+ *   any - JSON-RPC response object, nil for a notification
+ */
+func (svr *Server) rawHandler(ctx context.Context, data json.RawMessage) any {
+	var raw map[string]json.RawMessage
+	if kind(data) != '{' || json.Unmarshal(data, &raw) != nil {
+		return E(nil, JsonRpc, InvalidRequest)
+	}
+	return svr.singleHandler(ctx, raw)
+}
+
 /*
  * SingleHandler handles a single JSON-RPC request.
  *
@@ -201,65 +318,187 @@ func (svr *Server) Handler(b []byte) []byte {
  *   jsonMap map[string]any - Parsed JSON-RPC request
  *
  * Returns:
- *   any - JSON-RPC response object
+ *   any - JSON-RPC response object, nil for a notification
  */
 func (svr *Server) SingleHandler(jsonMap map[string]any) any {
-	id, jsonRpc, method, paramsData, errCode := ParseSingleRequestBody(jsonMap)
+	return svr.SingleHandlerContext(context.Background(), jsonMap)
+}
+
+/*
+ * SingleHandlerContext handles a single JSON-RPC request bound to a context.
+ * The context passed to the service method and hooks carries the RequestInfo.
+ * A notification is processed but gets no response, even when it fails.
+ * An invalid request gets an invalid request error, see Lenient for legacy requests.
+ *
+ * Parameters:
+ *   ctx     context.Context - Context of the connection or HTTP request
+ *   jsonMap map[string]any  - Parsed JSON-RPC request
+ *
+ * Returns:
+ *   any - JSON-RPC response object, nil for a notification
+ */
+func (svr *Server) SingleHandlerContext(ctx context.Context, jsonMap map[string]any) any {
+	return svr.singleHandler(ctx, rawRequestBody(jsonMap))
+}
+
+/*
+ * singleHandler validates a single JSON-RPC request and dispatches it.
+ *
+ * Parameters:
+ *   ctx context.Context            - Context of the connection or HTTP request
+ *   raw map[string]json.RawMessage - Request members as raw JSON
+ *
+ * Returns:
+ *   any - JSON-RPC response object, nil for a notification
+ */
+func (svr *Server) singleHandler(ctx context.Context, raw map[string]json.RawMessage) any {
+	id, _, method, paramsData, errCode := parseSingleRequestBody(raw, svr.Lenient)
 	if errCode != WithoutError {
-		return E(id, jsonRpc, errCode)
+		return E(id, JsonRpc, errCode)
 	}
+	params, _ := paramsData.(json.RawMessage)
+	ctx, settle := withRequestNotifier(ctx)
+	res := svr.dispatch(WithRequestInfo(ctx, &RequestInfo{Id: id, Method: method}), id, JsonRpc, method, params)
+	_, failed := res.(ErrorResponse)
+	settle(id != nil && !failed)
+	if id == nil {
+		return nil
+	}
+	return res
+}
 
+/*
+ * dispatch calls the method of a valid request through the middleware chain.
+ *
+ * Parameters:
+ *   ctx        context.Context - Context carrying the RequestInfo
+ *   id         any             - Request ID
+ *   jsonRpc    string          - JSON-RPC version
+ *   method     string          - Method name as sent by the client
+ *   paramsData json.RawMessage - Params as sent by the client, nil when omitted
+ *
+ * Returns:
+ *   any - JSON-RPC response object
+ */
+func (svr *Server) dispatch(ctx context.Context, id any, jsonRpc string, method string, paramsData json.RawMessage) any {
 	if svr.RateLimiter != nil && !svr.RateLimiter.Allow() {
 		return CE(id, jsonRpc, "Too many requests")
 	}
 
-	sName, mName, err := ParseRequestMethod(method)
-	if err != nil {
-		return E(id, jsonRpc, MethodNotFound)
-	}
-	s, ok := svr.Sm.Load(sName)
-	if !ok {
-		sName = lineToHump(sName) // support HelloWorld and hello_world
-		s, ok = svr.Sm.Load(sName)
-		if !ok {
-			return E(id, jsonRpc, MethodNotFound)
-		}
-	}
-	m, ok := s.(*Service).Mm[mName]
+	r, ok := svr.route(method)
 	if !ok {
 		return E(id, jsonRpc, MethodNotFound)
 	}
+	m := r.Method
 	params := reflect.New(m.ParamsType.Elem())
 	pv := params.Interface()
-	err = GetStruct(paramsData, pv)
+	err := Bind(paramsData, pv, svr.BindOptions)
+	if err != nil {
+		Debug(err)
+		return EE(id, jsonRpc, Error{InvalidParams, CodeMap[InvalidParams], err.Error()})
+	}
+	// Validate before the middleware and the hooks, the failing params are sent as the error data.
+	if err = Validate(pv); err != nil {
+		Debug(err)
+		return EE(id, jsonRpc, Error{InvalidParams, CodeMap[InvalidParams], err})
+	}
+
+	inv := &Invocation{
+		Context: ctx,
+		Id:      id,
+		Method:  method,
+		Service: r.Service.Name,
+		Name:    r.Name,
+		Params:  pv,
+	}
+	err = svr.recoverPanic(inv, svr.Chain(func(inv *Invocation) error {
+		return svr.invoke(r.Service, m, inv, params)
+	}))
 	if err != nil {
-		return E(id, jsonRpc, InvalidParams)
+		return errorResponse(id, jsonRpc, err)
 	}
+	return S(id, jsonRpc, inv.Result)
+}
+
+/*
+ * recoverPanic runs a handler and converts a panic of the middleware, the hooks or the service method
+ * into an internal error, so that it takes down neither the connection nor the other requests of a batch.
+ *
+ * Parameters:
+ *   inv *Invocation - Invocation to process
+ *   h   Handler     - Handler to run
+ *
+ * Returns:
+ *   error - Error returned by the handler, or an internal error when it panics
+ */
+func (svr *Server) recoverPanic(inv *Invocation, h Handler) (err error) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		if svr.PanicHandler != nil {
+			svr.PanicHandler(inv.Context, p, debug.Stack())
+		} else {
+			Debug(fmt.Sprintf("panic: %v", p))
+		}
+		err = &Error{InternalError, CodeMap[InternalError], nil}
+	}()
+	return h(inv)
+}
+
+/*
+ * invoke runs the hooks and the service method of an invocation, it is the innermost handler of the chain.
+ *
+ * Parameters:
+ *   s      *Service      - Service of the method
+ *   m      *Method       - Method to call
+ *   inv    *Invocation   - Invocation receiving the result
+ *   params reflect.Value - Pointer to the decoded params
+ *
+ * Returns:
+ *   error - Hook error, the *Error returned by the method, or an internal error when the method fails otherwise
+ */
+func (svr *Server) invoke(s *Service, m *Method, inv *Invocation, params reflect.Value) error {
 	result := reflect.New(m.ResultType.Elem())
 
 	// before
-	err = svr.Before(id, mName, params.Elem().Interface())
+	err := svr.BeforeContext(inv.Context, inv.Id, inv.Name, params.Elem().Interface())
 	if err != nil {
-		return CE(id, jsonRpc, err.Error())
+		return err
 	}
 
-	r := m.Method.Func.Call([]reflect.Value{s.(*Service).V, params, result})
+	// A function registered with RegisterFunc has no receiver.
+	args := make([]reflect.Value, 0, 4)
+	if s.V.IsValid() {
+		args = append(args, s.V)
+	}
+	if m.WithContext {
+		args = append(args, reflect.ValueOf(inv.Context))
+	}
+	r := m.Method.Func.Call(append(args, params, result))
 
 	if i := r[0].Interface(); i != nil {
 		Debug(i.(error))
-		return E(id, jsonRpc, InternalError)
+		// An *Error keeps its code, message and data, other errors are not exposed to the client.
+		var e *Error
+		if errors.As(i.(error), &e) {
+			return e
+		}
+		return &Error{InternalError, CodeMap[InternalError], nil}
 	}
 	// after
-	err = svr.After(id, mName, result.Elem().Interface())
+	err = svr.AfterContext(inv.Context, inv.Id, inv.Name, result.Elem().Interface())
 	if err != nil {
-		return CE(id, jsonRpc, err.Error())
+		return err
 	}
 
-	return S(id, jsonRpc, result.Elem().Interface())
+	inv.Result = result.Elem().Interface()
+	return nil
 }
 
 /*
- * Before executes the before hook function if it exists.
+ * Before executes the before hook functions if they exist, with a background context.
  *
  * Parameters:
  *   id     any    - Request ID
@@ -270,17 +509,39 @@ func (svr *Server) SingleHandler(jsonMap map[string]any) any {
  *   error - Error from the hook function if it fails
  */
 func (svr *Server) Before(id any, method string, params any) error {
+	return svr.BeforeContext(context.Background(), id, method, params)
+}
+
+/*
+ * BeforeContext executes the before hook functions if they exist.
+ *
+ * Parameters:
+ *   ctx    context.Context - Context of the request
+ *   id     any             - Request ID
+ *   method string          - Method name
+ *   params any             - Request parameters
+ *
+ * Returns:
+ *   error - Error from the hook function if it fails
+ */
+func (svr *Server) BeforeContext(ctx context.Context, id any, method string, params any) error {
 	if svr.Hooks.BeforeFunc != nil {
 		err := svr.Hooks.BeforeFunc(id, method, params)
 		if err != nil {
 			return err
 		}
 	}
+	if svr.Hooks.BeforeContextFunc != nil {
+		err := svr.Hooks.BeforeContextFunc(ctx, id, method, params)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 /*
- * After executes the after hook function if it exists.
+ * After executes the after hook functions if they exist, with a background context.
  *
  * Parameters:
  *   id     any    - Request ID
@@ -291,12 +552,34 @@ func (svr *Server) Before(id any, method string, params any) error {
  *   error - Error from the hook function if it fails
  */
 func (svr *Server) After(id any, method string, result any) error {
+	return svr.AfterContext(context.Background(), id, method, result)
+}
+
+/*
+ * AfterContext executes the after hook functions if they exist.
+ *
+ * Parameters:
+ *   ctx    context.Context - Context of the request
+ *   id     any             - Request ID
+ *   method string          - Method name
+ *   result any             - Request result
+ *
+ * Returns:
+ *   error - Error from the hook function if it fails
+ */
+func (svr *Server) AfterContext(ctx context.Context, id any, method string, result any) error {
 	if svr.Hooks.AfterFunc != nil {
 		err := svr.Hooks.AfterFunc(id, method, result)
 		if err != nil {
 			return err
 		}
 	}
+	if svr.Hooks.AfterContextFunc != nil {
+		err := svr.Hooks.AfterContextFunc(ctx, id, method, result)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,371 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+/*
+ * FieldError describes a param which failed a validation rule.
+ *
+ * Fields:
+ *   Field string - Path of the param, e.g. items[0].name
+ *   Rule  string - Rule which failed
+ *   Param string - Argument of the rule, empty for rules without argument
+ */
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+/*
+ * ValidationErrors lists the params which failed validation, it is sent as the data of the invalid params error.
+ */
+type ValidationErrors []FieldError
+
+/*
+ * Error gets the error message.
+ *
+ * Returns:
+ *   string - Message listing the failing params and rules
+ */
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, f := range e {
+		messages[i] = fmt.Sprintf("%s: %s", f.Field, f.Rule)
+		if f.Param != "" {
+			messages[i] += "=" + f.Param
+		}
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+/*
+ * regexps caches the compiled regex rules.
+ */
+var regexps sync.Map
+
+/*
+ * Validate checks params against the validate tags of their struct fields, nested structs,
+ * pointers to structs and slices of structs included, e.g. `validate:"required,min=1,max=10"`.
+ *
+ * Rules:
+ *   required     - The value is not the zero value, a pointer is not nil
+ *   min=n, max=n - Bounds of a number, or of the length of a string, slice or map
+ *   len=n        - Length of a string, slice or map
+ *   oneof=a b c  - The value is one of the space-separated values
+ *   regex=expr   - A string matches the expression, it must be the last rule as it may hold commas
+ *
+ * An optional param, a pointer or a field with the omitempty json option or a default tag, is only
+ * checked by the required rule when it holds the zero value. An unknown rule fails with its own name,
+ * the servers refuse to register a method whose params hold one, see CheckValidateTags.
+ *
+ * Parameters:
+ *   v any - Params, usually a pointer to a struct
+ *
+ * Returns:
+ *   error - ValidationErrors when a param fails a rule, nil otherwise
+ */
+func Validate(v any) error {
+	var errs ValidationErrors
+	validateValue(reflect.ValueOf(v), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+/*
+ * validateValue validates the fields of a struct and walks into nested values.
+ *
+ * Parameters:
+ *   v    reflect.Value     - Value to walk
+ *   path string            - Path of the value
+ *   errs *ValidationErrors - Errors found so far
+ */
+func validateValue(v reflect.Value, path string, errs *ValidationErrors) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range structFields(v.Type()) {
+			fv := v.FieldByIndex(f.Index)
+			fieldPath := f.Name
+			if path != "" {
+				fieldPath = path + "." + f.Name
+			}
+			tag := v.Type().FieldByIndex(f.Index).Tag.Get("validate")
+			if tag != "" && tag != "-" {
+				validateField(fv, fieldPath, tag, f.Optional || f.HasDefault, errs)
+			}
+			validateValue(fv, fieldPath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Array:
+		default:
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+/*
+ * validateField checks a value against the rules of a validate tag.
+ *
+ * Parameters:
+ *   v        reflect.Value     - Value of the field
+ *   path     string            - Path of the field
+ *   tag      string            - Validate tag
+ *   optional bool              - Whether the other rules skip the zero value
+ *   errs     *ValidationErrors - Errors found so far
+ */
+func validateField(v reflect.Value, path string, tag string, optional bool, errs *ValidationErrors) {
+	for tag != "" {
+		var name, param string
+		name, param, tag = nextRule(tag)
+		if name == "" {
+			continue
+		}
+		if name == "required" {
+			if v.IsZero() {
+				*errs = append(*errs, FieldError{path, name, param})
+				// The other rules say nothing more about a missing value.
+				return
+			}
+			continue
+		}
+		if optional && v.IsZero() {
+			continue
+		}
+		value := v
+		for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return
+			}
+			value = value.Elem()
+		}
+		if !checkRule(value, name, param) {
+			*errs = append(*errs, FieldError{path, name, param})
+		}
+	}
+}
+
+/*
+ * nextRule splits the first rule off a validate tag.
+ *
+ * Parameters:
+ *   tag string - Validate tag
+ *
+ * Returns:
+ *   string - Rule name, empty for an empty rule
+ *   string - Argument of the rule
+ *   string - Rest of the tag
+ */
+func nextRule(tag string) (string, string, string) {
+	var rule string
+	if strings.HasPrefix(strings.TrimSpace(tag), "regex=") {
+		rule, tag = tag, ""
+	} else {
+		rule, tag, _ = strings.Cut(tag, ",")
+	}
+	name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+	return name, param, tag
+}
+
+/*
+ * CheckValidateTags checks the validate tags of a params type and of the types it holds, so that a mistyped
+ * rule fails when the method is registered instead of failing every request.
+ *
+ * Parameters:
+ *   t reflect.Type - Params type, usually a pointer to a struct
+ *
+ * Returns:
+ *   error - Error naming the field and its unknown rule, invalid bound or invalid regex expression
+ */
+func CheckValidateTags(t reflect.Type) error {
+	return checkTags(t, "", make(map[reflect.Type]bool))
+}
+
+/*
+ * checkTags checks the validate tags of the fields of a struct and walks into the types they hold.
+ *
+ * Parameters:
+ *   t    reflect.Type          - Type to walk
+ *   path string                - Path of the type
+ *   seen map[reflect.Type]bool - Struct types already checked, a recursive type is walked once
+ *
+ * Returns:
+ *   error - Error of the first invalid tag
+ */
+func checkTags(t reflect.Type, path string, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if seen[t] {
+			return nil
+		}
+		seen[t] = true
+		for _, f := range structFields(t) {
+			sf := t.FieldByIndex(f.Index)
+			fieldPath := f.Name
+			if path != "" {
+				fieldPath = path + "." + f.Name
+			}
+			if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+				if err := checkTag(tag); err != nil {
+					return fmt.Errorf("%s: %w", fieldPath, err)
+				}
+			}
+			if err := checkTags(sf.Type, fieldPath, seen); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		return checkTags(t.Elem(), path+"[]", seen)
+	}
+	return nil
+}
+
+/*
+ * checkTag checks the rules of a validate tag, compiling its regex expression.
+ *
+ * Parameters:
+ *   tag string - Validate tag
+ *
+ * Returns:
+ *   error - Error of the first invalid rule
+ */
+func checkTag(tag string) error {
+	for tag != "" {
+		var name, param string
+		name, param, tag = nextRule(tag)
+		switch name {
+		case "", "required", "oneof":
+		case "min", "max", "len":
+			if _, err := strconv.ParseFloat(param, 64); err != nil {
+				return fmt.Errorf("invalid bound of validate rule %s: %q", name, param)
+			}
+		case "regex":
+			if _, err := compileRegex(param); err != nil {
+				return fmt.Errorf("invalid expression of validate rule regex: %w", err)
+			}
+		default:
+			return fmt.Errorf("unknown validate rule: %s", name)
+		}
+	}
+	return nil
+}
+
+/*
+ * compileRegex gets a compiled regex rule from the cache, compiling it once.
+ *
+ * Parameters:
+ *   expr string - Regex expression
+ *
+ * Returns:
+ *   *regexp.Regexp - Compiled expression
+ *   error          - Error if the expression is invalid
+ */
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	re, _ := regexps.LoadOrStore(expr, compiled)
+	return re.(*regexp.Regexp), nil
+}
+
+/*
+ * checkRule checks a value against a rule other than required.
+ *
+ * Parameters:
+ *   v     reflect.Value - Value, not a pointer
+ *   name  string        - Rule name
+ *   param string        - Argument of the rule
+ *
+ * Returns:
+ *   bool - Whether the value satisfies the rule
+ */
+func checkRule(v reflect.Value, name string, param string) bool {
+	switch name {
+	case "min", "max", "len":
+		bound, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return false
+		}
+		n, ok := measure(v, name != "len")
+		if !ok {
+			return false
+		}
+		switch name {
+		case "min":
+			return n >= bound
+		case "max":
+			return n <= bound
+		}
+		return n == bound
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return true
+			}
+		}
+		return false
+	case "regex":
+		if v.Kind() != reflect.String {
+			return false
+		}
+		re, err := compileRegex(param)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(v.String())
+	}
+	return false
+}
+
+/*
+ * measure gets the number a bound applies to.
+ *
+ * Parameters:
+ *   v       reflect.Value - Value, not a pointer
+ *   numbers bool          - Whether numbers are measured by their value
+ *
+ * Returns:
+ *   float64 - Value of a number, rune count of a string, length of a slice, an array or a map
+ *   bool    - Whether the value can be measured
+ */
+func measure(v reflect.Value, numbers bool) (float64, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), numbers
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), numbers
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), numbers
+	}
+	return 0, false
+}
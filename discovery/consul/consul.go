@@ -1,12 +1,14 @@
 package consul
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/sunquakes/jsonrpc4go/discovery"
@@ -24,26 +26,64 @@ type Consul struct {
 
 /**
  * @Description: Health service structure
- * @Field AggregatedStatus: Aggregated status
+ * @Field AggregatedStatus: Aggregated status, only returned by the agent endpoints
+ * @Field Node: Node information
  * @Field Service: Service information
+ * @Field Checks: Node and service health checks
  */
 type HealthService struct {
-	AggregatedStatus string  `json:"AggregatedStatus"`
-	Service          Service `json:"Service"`
+	AggregatedStatus string        `json:"AggregatedStatus"`
+	Node             Node          `json:"Node"`
+	Service          Service       `json:"Service"`
+	Checks           []HealthCheck `json:"Checks"`
+}
+
+/**
+ * @Description: Health check status structure
+ * @Field CheckID: Check ID
+ * @Field Status: Check status, passing, warning or critical
+ */
+type HealthCheck struct {
+	CheckID string `json:"CheckID"`
+	Status  string `json:"Status"`
+}
+
+/**
+ * @Description: Node structure
+ * @Field Address: Node address
+ */
+type Node struct {
+	Address string `json:"Address"`
 }
 
 /**
  * @Description: Service structure
  * @Field ID: Service ID
  * @Field Service: Service name
+ * @Field Tags: Service tags
+ * @Field Meta: Service metadata
  * @Field Port: Port number
  * @Field Address: Service address
+ * @Field Weights: Service weights
  */
 type Service struct {
-	ID      string `json:"ID"`
-	Service string `json:"Service"`
-	Port    int    `json:"Port"`
-	Address string `json:"Address"`
+	ID      string            `json:"ID"`
+	Service string            `json:"Service"`
+	Tags    []string          `json:"Tags"`
+	Meta    map[string]string `json:"Meta"`
+	Port    int               `json:"Port"`
+	Address string            `json:"Address"`
+	Weights Weights           `json:"Weights"`
+}
+
+/**
+ * @Description: Service weights structure
+ * @Field Passing: Weight when the service is passing
+ * @Field Warning: Weight when the service is warning
+ */
+type Weights struct {
+	Passing int `json:"Passing"`
+	Warning int `json:"Warning"`
 }
 
 /**
@@ -52,12 +92,14 @@ type Service struct {
  * @Field Name: Service name
  * @Field Port: Port number
  * @Field Address: Service address
+ * @Field Meta: Service metadata
  */
 type RegisterService struct {
-	ID      string `json:"ID"`
-	Name    string `json:"Name"`
-	Port    int    `json:"Port"`
-	Address string `json:"Address"`
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Port    int               `json:"Port"`
+	Address string            `json:"Address"`
+	Meta    map[string]string `json:"Meta,omitempty"`
 }
 
 /**
@@ -109,19 +151,13 @@ func NewConsul(rawURL string) (discovery.Driver, error) {
  * @Return error: Error message
  */
 func (d *Consul) Register(name string, protocol string, hostname string, port int) error {
-	// Get the instanceId from url
-	instanceId := d.URL.Query().Get("instanceId")
-	var ID string
-	if instanceId == "" {
-		ID = fmt.Sprintf("%s:%d", name, port)
-	} else {
-		ID = fmt.Sprintf("%s-%s:%d", name, instanceId, port)
-	}
+	ID := d.ServiceID(name, port)
 	service := &RegisterService{
 		ID,
 		name,
 		port,
 		hostname,
+		map[string]string{discovery.META_PROTOCOL: protocol},
 	}
 	URL, err := GetURL(d.URL.Redacted(), "/v1/agent/service/register", d.Token)
 	if err != nil {
@@ -147,6 +183,51 @@ func (d *Consul) Register(name string, protocol string, hostname string, port in
 	return nil
 }
 
+/**
+ * @Description: Deregister service
+ * @Receiver d: Consul structure pointer
+ * @Param name: Service name
+ * @Param protocol: Protocol type
+ * @Param hostname: Hostname
+ * @Param port: Port number
+ * @Return error: Error message
+ */
+func (d *Consul) Deregister(name string, protocol string, hostname string, port int) error {
+	URL, err := GetURL(d.URL.Redacted(), "/v1/agent/service/deregister/"+d.ServiceID(name, port), d.Token)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != STATUS_CODE_PASSING {
+		return errors.New(StatusCodeMap[resp.StatusCode])
+	}
+	return nil
+}
+
+/**
+ * @Description: Build the service ID used to register the service
+ * @Receiver d: Consul structure pointer
+ * @Param name: Service name
+ * @Param port: Port number
+ * @Return string: Service ID
+ */
+func (d *Consul) ServiceID(name string, port int) string {
+	// Get the instanceId from url
+	instanceId := d.URL.Query().Get("instanceId")
+	if instanceId == "" {
+		return fmt.Sprintf("%s:%d", name, port)
+	}
+	return fmt.Sprintf("%s-%s:%d", name, instanceId, port)
+}
+
 /**
  * @Description: Check enable flag
  */
@@ -176,8 +257,17 @@ const (
 	 * @Description: Check status - passing
 	 */
 	CHECK_STATUS_PASSING = "passing"
+	/**
+	 * @Description: Maximum duration of a blocking query
+	 */
+	WATCH_WAIT = "5m"
 )
 
+/**
+ * @Description: Index of the first blocking query after the index was missing or went backwards
+ */
+const WATCH_RESET_INDEX = 1
+
 /**
  * @Description: Set service health check
  * @Receiver d: Consul structure pointer
@@ -233,26 +323,148 @@ func (d *Consul) Check(ID string, name string, protocol string, hostname string,
  * @Return error: Error message
  */
 func (d *Consul) Get(name string) (string, error) {
-	URL, err := GetURL(d.URL.Redacted(), "/v1/agent/health/service/name/"+name, d.Token)
+	instances, err := d.GetInstances(name)
 	if err != nil {
 		return "", err
 	}
-	resp, err := http.Get(URL)
+	return strings.Join(discovery.Addresses(instances), ","), nil
+}
+
+/**
+ * @Description: Get service instances from the health endpoint, the one watched by Watch
+ * @Receiver d: Consul structure pointer
+ * @Param name: Service name
+ * @Return []discovery.Instance: Instance list, healthy when all their checks are passing
+ * @Return error: Error message
+ */
+func (d *Consul) GetInstances(name string) ([]discovery.Instance, error) {
+	instances, _, err := d.HealthService(context.Background(), name, 0)
+	return instances, err
+}
+
+/**
+ * @Description: Check whether the service passes its health checks
+ * @Receiver s: HealthService structure
+ * @Return bool: Whether the aggregated status, or else every check, is passing
+ */
+func (s HealthService) Passing() bool {
+	if s.AggregatedStatus != "" {
+		return s.AggregatedStatus == CHECK_STATUS_PASSING
+	}
+	for _, check := range s.Checks {
+		if check.Status != CHECK_STATUS_PASSING {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * @Description: Convert the health service to an instance
+ * @Receiver s: HealthService structure
+ * @Param healthy: Whether the service passes its health checks
+ * @Return discovery.Instance: Service instance
+ */
+func (s HealthService) Instance(healthy bool) discovery.Instance {
+	host := s.Service.Address
+	if host == "" {
+		host = s.Node.Address
+	}
+	weight := float64(s.Service.Weights.Passing)
+	if weight <= 0 {
+		weight = discovery.DEFAULT_WEIGHT
+	}
+	return discovery.Instance{
+		Id:       s.Service.ID,
+		Name:     s.Service.Service,
+		Protocol: s.Service.Meta[discovery.META_PROTOCOL],
+		Host:     host,
+		Port:     s.Service.Port,
+		Weight:   weight,
+		Healthy:  healthy,
+		Zone:     s.Service.Meta[discovery.META_ZONE],
+		Version:  s.Service.Meta[discovery.META_VERSION],
+		Tags:     s.Service.Tags,
+		Meta:     s.Service.Meta,
+	}
+}
+
+/**
+ * @Description: Watch the instances of a service with blocking queries
+ * @Receiver d: Consul structure pointer
+ * @Param name: Service name
+ * @Return <-chan []discovery.Instance: Channel receiving the instance lists, the same as GetInstances returns
+ * @Return func(): Function stopping the watch
+ */
+func (d *Consul) Watch(name string) (<-chan []discovery.Instance, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var index uint64
+	ch := discovery.Poll(ctx, 0, func(ctx context.Context) ([]discovery.Instance, error) {
+		instances, next, err := d.HealthService(ctx, name, index)
+		if err != nil {
+			return nil, err
+		}
+		// A missing index would not block and one going backwards, e.g. after a Consul restart, would miss changes.
+		if next < WATCH_RESET_INDEX || next < index {
+			next = WATCH_RESET_INDEX
+		}
+		index = next
+		return instances, nil
+	})
+	return ch, cancel
+}
+
+/**
+ * @Description: Get the instances of a service, blocking until the index changes
+ * @Receiver d: Consul structure pointer
+ * @Param ctx: Context of the request
+ * @Param name: Service name
+ * @Param index: Index of the previous result, 0 to return immediately
+ * @Return []discovery.Instance: Instance list, healthy when all their checks are passing
+ * @Return uint64: Index of the result, 0 when Consul did not send one
+ * @Return error: Error message
+ */
+func (d *Consul) HealthService(ctx context.Context, name string, index uint64) ([]discovery.Instance, uint64, error) {
+	rawURL, err := GetURL(d.URL.Redacted(), "/v1/health/service/"+name, d.Token)
 	if err != nil {
-		return "", err
+		return nil, 0, err
+	}
+	URL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := URL.Query()
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", WATCH_WAIT)
+	}
+	URL.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", URL.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != STATUS_CODE_PASSING {
-		return "", errors.New(StatusCodeMap[resp.StatusCode])
+		return nil, 0, errors.New(StatusCodeMap[resp.StatusCode])
 	}
 	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
 	var hss []HealthService
-	json.Unmarshal(body, &hss)
-	ua := make([]string, 0)
+	if err = json.Unmarshal(body, &hss); err != nil {
+		return nil, 0, err
+	}
+	instances := make([]discovery.Instance, 0, len(hss))
 	for _, v := range hss {
-		ua = append(ua, fmt.Sprintf("%s:%d", v.Service.Address, v.Service.Port))
+		instances = append(instances, v.Instance(v.Passing()))
 	}
-	return strings.Join(ua, ","), err
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return instances, next, nil
 }
 
 /**
@@ -14,10 +14,26 @@ type Driver interface {
 	 */
 	Register(name string, protocol string, hostname string, port int) error
 	/**
-	 * @Description: Get service address
+	 * @Description: Deregister service
 	 * @Param name: Service name
-	 * @Return string: Service address
+	 * @Param protocol: Protocol type
+	 * @Param hostname: Hostname
+	 * @Param port: Port number
+	 * @Return error: Error message
+	 */
+	Deregister(name string, protocol string, hostname string, port int) error
+	/**
+	 * @Description: Get service address, kept for compatibility, use GetInstances instead
+	 * @Param name: Service name
+	 * @Return string: Service address list (comma separated)
 	 * @Return error: Error message
 	 */
 	Get(name string) (string, error)
+	/**
+	 * @Description: Get service instances
+	 * @Param name: Service name
+	 * @Return []Instance: Service instance list, including the unhealthy instances
+	 * @Return error: Error message
+	 */
+	GetInstances(name string) ([]Instance, error)
 }
@@ -3,10 +3,13 @@ package etcd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sunquakes/jsonrpc4go/discovery"
@@ -40,11 +43,23 @@ var PROTOCOL_HTTPS string = "https"
  * @Field URL: Etcd server URL address
  * @Field Conn: gRPC connection
  * @Field Heartbeat: Heartbeat channel
+ * @Field Leases: Leases of the registered services, keyed by service name and address
  */
 type Etcd struct {
 	URL       *url.URL
 	Conn      *grpc.ClientConn
 	Heartbeat chan bool
+	Leases    sync.Map
+}
+
+/**
+ * @Description: Lease of a registered service
+ * @Field ID: Lease ID
+ * @Field Cancel: Function stopping the lease heartbeat
+ */
+type Lease struct {
+	ID     int64
+	Cancel context.CancelFunc
 }
 
 /**
@@ -76,7 +91,7 @@ func NewEtcd(rawURL string) (discovery.Driver, error) {
 		return nil, err
 	}
 	heartbeat := make(chan bool)
-	etcd := &Etcd{URL, conn, heartbeat}
+	etcd := &Etcd{URL: URL, Conn: conn, Heartbeat: heartbeat}
 	return etcd, nil
 }
 
@@ -90,12 +105,7 @@ func NewEtcd(rawURL string) (discovery.Driver, error) {
  * @Return error: Error message
  */
 func (d *Etcd) Register(name string, protocol string, hostname string, port int) error {
-	var addr string
-	if protocol == PROTOCOL_HTTP || protocol == PROTOCOL_HTTPS {
-		addr = fmt.Sprintf("%s://%s:%d", protocol, hostname, port)
-	} else {
-		addr = fmt.Sprintf("%s:%d", hostname, port)
-	}
+	addr := Address(protocol, hostname, port)
 
 	// Create a Lease client
 	leaseClient := etcdserverpb.NewLeaseClient(d.Conn)
@@ -118,16 +128,94 @@ func (d *Etcd) Register(name string, protocol string, hostname string, port int)
 	if err != nil {
 		return err
 	}
-	_, err = kvClient.Put(context.Background(), &etcdserverpb.PutRequest{Key: name, Value: data, Lease: leaseID})
+	// Every instance has its own key, so the instances of a service do not overwrite each other.
+	key := Key(name, addr)
+	_, err = kvClient.Put(context.Background(), &etcdserverpb.PutRequest{Key: key, Value: data, Lease: leaseID})
 	if err != nil {
 		return err
 	}
-	d.SendHeartbeat(func() {
-		leaseClient.LeaseKeepAlive(context.Background(), &etcdserverpb.LeaseKeepAliveRequest{ID: leaseID})
+	ctx, cancel := context.WithCancel(context.Background())
+	if old, loaded := d.Leases.Swap(key, &Lease{leaseID, cancel}); loaded {
+		old.(*Lease).Cancel()
+	}
+	d.SendHeartbeat(ctx, func() {
+		leaseClient.LeaseKeepAlive(ctx, &etcdserverpb.LeaseKeepAliveRequest{ID: leaseID})
 	})
 	return nil
 }
 
+/**
+ * @Description: Deregister service by revoking its lease
+ * @Receiver d: Etcd structure pointer
+ * @Param name: Service name
+ * @Param protocol: Protocol type
+ * @Param hostname: Hostname
+ * @Param port: Port number
+ * @Return error: Error message
+ */
+func (d *Etcd) Deregister(name string, protocol string, hostname string, port int) error {
+	value, ok := d.Leases.LoadAndDelete(Key(name, Address(protocol, hostname, port)))
+	if !ok {
+		return nil
+	}
+	lease := value.(*Lease)
+	lease.Cancel()
+	// Revoking the lease deletes the key of this instance only.
+	leaseClient := etcdserverpb.NewLeaseClient(d.Conn)
+	_, err := leaseClient.LeaseRevoke(context.Background(), &etcdserverpb.LeaseRevokeRequest{ID: lease.ID})
+	return err
+}
+
+/**
+ * @Description: Build the address stored for a service
+ * @Param protocol: Protocol type
+ * @Param hostname: Hostname
+ * @Param port: Port number
+ * @Return string: Service address
+ */
+func Address(protocol string, hostname string, port int) string {
+	if protocol == PROTOCOL_HTTP || protocol == PROTOCOL_HTTPS {
+		return fmt.Sprintf("%s://%s:%d", protocol, hostname, port)
+	}
+	return fmt.Sprintf("%s:%d", hostname, port)
+}
+
+/**
+ * @Description: Build the key stored for a service instance
+ * @Param name: Service name
+ * @Param addr: Service address
+ * @Return string: Key under the prefix of the service
+ */
+func Key(name string, addr string) string {
+	return Prefix(name) + addr
+}
+
+/**
+ * @Description: Build the prefix of the keys stored for the instances of a service
+ * @Param name: Service name
+ * @Return string: Key prefix
+ */
+func Prefix(name string) string {
+	return name + "/"
+}
+
+/**
+ * @Description: Get the end of the key range covering a prefix
+ * @Param prefix: Key prefix
+ * @Return string: Range end, the prefix with its last byte incremented
+ */
+func rangeEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	// Every byte is 0xff, the range ends with the keyspace.
+	return "\x00"
+}
+
 /**
  * @Description: Get service address list
  * @Receiver d: Etcd structure pointer
@@ -136,33 +224,191 @@ func (d *Etcd) Register(name string, protocol string, hostname string, port int)
  * @Return error: Error message
  */
 func (d *Etcd) Get(name string) (string, error) {
-	// Create a KV client
-	kvClient := etcdserverpb.NewKVClient(d.Conn)
-	resp, err := kvClient.Range(context.Background(), &etcdserverpb.RangeRequest{Key: name})
+	services, err := d.Services(context.Background(), name)
 	if err != nil {
 		return "", err
 	}
 	var servers []string
+	for _, service := range services {
+		servers = append(servers, service.Addr)
+	}
+	return strings.Join(servers, ","), nil
+}
+
+/**
+ * @Description: Get the services stored under the prefix of a service name
+ * @Receiver d: Etcd structure pointer
+ * @Param ctx: Context of the request
+ * @Param name: Service name
+ * @Return []Service: Service list
+ * @Return error: Error message
+ */
+func (d *Etcd) Services(ctx context.Context, name string) ([]Service, error) {
+	// Create a KV client
+	kvClient := etcdserverpb.NewKVClient(d.Conn)
+	prefix := Prefix(name)
+	resp, err := kvClient.Range(ctx, &etcdserverpb.RangeRequest{Key: prefix, RangeEnd: rangeEnd(prefix)})
+	if err != nil {
+		return nil, err
+	}
+	services := make([]Service, 0, len(resp.Kvs))
 	for _, item := range resp.Kvs {
-		service := &Service{}
-		err := json.Unmarshal(item.Value, service)
+		service := Service{}
+		err := json.Unmarshal(item.Value, &service)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		servers = append(servers, service.Addr)
+		services = append(services, service)
 	}
-	return strings.Join(servers, ","), nil
+	return services, nil
 }
 
 /**
- * @Description: Send heartbeat
+ * @Description: Get service instances
  * @Receiver d: Etcd structure pointer
+ * @Param name: Service name
+ * @Return []discovery.Instance: Instance list
+ * @Return error: Error message
+ */
+func (d *Etcd) GetInstances(name string) ([]discovery.Instance, error) {
+	return d.Instances(context.Background(), name)
+}
+
+/**
+ * @Description: Get service instances bound to a context
+ * @Receiver d: Etcd structure pointer
+ * @Param ctx: Context of the request
+ * @Param name: Service name
+ * @Return []discovery.Instance: Instance list
+ * @Return error: Error message
+ */
+func (d *Etcd) Instances(ctx context.Context, name string) ([]discovery.Instance, error) {
+	services, err := d.Services(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]discovery.Instance, 0, len(services))
+	for _, service := range services {
+		instance, err := service.Instance()
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+/**
+ * @Description: Convert the service to an instance
+ * @Receiver s: Service structure
+ * @Return discovery.Instance: Service instance, healthy while its lease is alive
+ * @Return error: Error message
+ */
+func (s Service) Instance() (discovery.Instance, error) {
+	addr := s.Addr
+	protocol := ""
+	// Http services are stored with their scheme.
+	if i := strings.Index(addr, "://"); i >= 0 {
+		protocol, addr = addr[:i], addr[i+3:]
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return discovery.Instance{}, err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return discovery.Instance{}, err
+	}
+	return discovery.Instance{
+		Id:       s.UniqueId,
+		Name:     s.Name,
+		Protocol: protocol,
+		Host:     host,
+		Port:     p,
+		Weight:   discovery.DEFAULT_WEIGHT,
+		Healthy:  true,
+	}, nil
+}
+
+/**
+ * @Description: Watch the instances of a service over the gRPC connection
+ * @Receiver d: Etcd structure pointer
+ * @Param name: Service name
+ * @Return <-chan []discovery.Instance: Channel receiving the instance lists
+ * @Return func(): Function stopping the watch
+ */
+func (d *Etcd) Watch(name string) (<-chan []discovery.Instance, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	watchClient := etcdserverpb.NewWatchClient(d.Conn)
+	var (
+		stream etcdserverpb.Watch_WatchClient
+		synced bool
+	)
+	ch := discovery.Poll(ctx, 0, func(ctx context.Context) ([]discovery.Instance, error) {
+		if stream == nil {
+			// Create the watcher before reading the instances so no change is missed.
+			s, err := watchClient.Watch(ctx)
+			if err != nil {
+				return nil, err
+			}
+			err = s.Send(&etcdserverpb.WatchRequest{
+				RequestUnion: &etcdserverpb.WatchRequest_CreateRequest{
+					CreateRequest: &etcdserverpb.WatchCreateRequest{Key: Prefix(name), RangeEnd: rangeEnd(Prefix(name))},
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			stream = s
+		} else if synced {
+			// Block until a key of the service changes.
+			if err := d.waitEvents(stream); err != nil {
+				stream = nil
+				return nil, err
+			}
+		}
+		instances, err := d.Instances(ctx, name)
+		synced = err == nil
+		return instances, err
+	})
+	return ch, cancel
+}
+
+/**
+ * @Description: Wait for the next events of a watch stream
+ * @Receiver d: Etcd structure pointer
+ * @Param stream: Watch stream
+ * @Return error: Error message
+ */
+func (d *Etcd) waitEvents(stream etcdserverpb.Watch_WatchClient) error {
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if resp.Canceled {
+			return errors.New("etcd watch canceled")
+		}
+		if len(resp.Events) > 0 {
+			return nil
+		}
+	}
+}
+
+/**
+ * @Description: Send heartbeat until the context is canceled
+ * @Receiver d: Etcd structure pointer
+ * @Param ctx: Context stopping the heartbeat
  * @Param f: Heartbeat callback function
  */
-func (d *Etcd) SendHeartbeat(f func()) {
+func (d *Etcd) SendHeartbeat(ctx context.Context, f func()) {
 	go func() {
 		for {
-			d.Heartbeat <- true
+			select {
+			case d.Heartbeat <- true:
+			case <-ctx.Done():
+				return
+			}
 			time.Sleep(INTERVAL)
 		}
 	}()
@@ -171,7 +417,7 @@ func (d *Etcd) SendHeartbeat(f func()) {
 			select {
 			case <-d.Heartbeat:
 				f()
-			case <-context.Background().Done():
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -24,6 +24,7 @@ const _ = grpc.SupportPackageIsVersion7
 type LeaseClient interface {
 	LeaseGrant(ctx context.Context, in *LeaseGrantRequest, opts ...grpc.CallOption) (*LeaseGrantResponse, error)
 	LeaseKeepAlive(ctx context.Context, in *LeaseKeepAliveRequest, opts ...grpc.CallOption) (*LeaseKeepAliveResponse, error)
+	LeaseRevoke(ctx context.Context, in *LeaseRevokeRequest, opts ...grpc.CallOption) (*LeaseRevokeResponse, error)
 }
 
 type leaseClient struct {
@@ -52,12 +53,22 @@ func (c *leaseClient) LeaseKeepAlive(ctx context.Context, in *LeaseKeepAliveRequ
 	return out, nil
 }
 
+func (c *leaseClient) LeaseRevoke(ctx context.Context, in *LeaseRevokeRequest, opts ...grpc.CallOption) (*LeaseRevokeResponse, error) {
+	out := new(LeaseRevokeResponse)
+	err := c.cc.Invoke(ctx, "/etcdserverpb.Lease/LeaseRevoke", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LeaseServer is the server API for Lease service.
 // All implementations must embed UnimplementedLeaseServer
 // for forward compatibility
 type LeaseServer interface {
 	LeaseGrant(context.Context, *LeaseGrantRequest) (*LeaseGrantResponse, error)
 	LeaseKeepAlive(context.Context, *LeaseKeepAliveRequest) (*LeaseKeepAliveResponse, error)
+	LeaseRevoke(context.Context, *LeaseRevokeRequest) (*LeaseRevokeResponse, error)
 	mustEmbedUnimplementedLeaseServer()
 }
 
@@ -71,6 +82,9 @@ func (UnimplementedLeaseServer) LeaseGrant(context.Context, *LeaseGrantRequest)
 func (UnimplementedLeaseServer) LeaseKeepAlive(context.Context, *LeaseKeepAliveRequest) (*LeaseKeepAliveResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LeaseKeepAlive not implemented")
 }
+func (UnimplementedLeaseServer) LeaseRevoke(context.Context, *LeaseRevokeRequest) (*LeaseRevokeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LeaseRevoke not implemented")
+}
 func (UnimplementedLeaseServer) mustEmbedUnimplementedLeaseServer() {}
 
 // UnsafeLeaseServer may be embedded to opt out of forward compatibility for this service.
@@ -120,6 +134,24 @@ func _Lease_LeaseKeepAlive_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lease_LeaseRevoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaseRevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LeaseServer).LeaseRevoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/etcdserverpb.Lease/LeaseRevoke",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LeaseServer).LeaseRevoke(ctx, req.(*LeaseRevokeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Lease_ServiceDesc is the grpc.ServiceDesc for Lease service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -135,6 +167,10 @@ var Lease_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "LeaseKeepAlive",
 			Handler:    _Lease_LeaseKeepAlive_Handler,
 		},
+		{
+			MethodName: "LeaseRevoke",
+			Handler:    _Lease_LeaseRevoke_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "discovery/etcd/etcdserverpb/lease.proto",
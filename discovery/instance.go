@@ -0,0 +1,151 @@
+package discovery
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	/**
+	 * @Description: Default instance weight when the discovery service has none
+	 */
+	DEFAULT_WEIGHT = 1.0
+	/**
+	 * @Description: Metadata key of the protocol
+	 */
+	META_PROTOCOL = "protocol"
+	/**
+	 * @Description: Metadata key of the zone
+	 */
+	META_ZONE = "zone"
+	/**
+	 * @Description: Metadata key of the version
+	 */
+	META_VERSION = "version"
+)
+
+/**
+ * @Description: Service instance
+ * @Field Id: Instance ID
+ * @Field Name: Service name
+ * @Field Protocol: Protocol type, empty when the discovery service does not know it
+ * @Field Host: Hostname or IP
+ * @Field Port: Port number
+ * @Field Weight: Load balancing weight
+ * @Field Healthy: Whether the instance passes its health checks
+ * @Field Zone: Zone or cluster of the instance
+ * @Field Version: Version of the instance
+ * @Field Tags: Tags of the instance
+ * @Field Meta: Metadata of the instance
+ */
+type Instance struct {
+	Id       string
+	Name     string
+	Protocol string
+	Host     string
+	Port     int
+	Weight   float64
+	Healthy  bool
+	Zone     string
+	Version  string
+	Tags     []string
+	Meta     map[string]string
+}
+
+/**
+ * @Description: Get the address of the instance
+ * @Receiver i: Instance structure
+ * @Return string: Address in host:port form, the socket path for unix instances
+ */
+func (i Instance) Address() string {
+	if i.Protocol == "unix" {
+		return i.Host
+	}
+	return net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+}
+
+/**
+ * @Description: Get the addresses of the instances
+ * @Param instances: Instance list
+ * @Return []string: Address list
+ */
+func Addresses(instances []Instance) []string {
+	addresses := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		addresses = append(addresses, instance.Address())
+	}
+	return addresses
+}
+
+/**
+ * @Description: Get the healthy instances
+ * @Param instances: Instance list
+ * @Return []Instance: Healthy instance list
+ */
+func Healthy(instances []Instance) []Instance {
+	healthy := make([]Instance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Healthy {
+			healthy = append(healthy, instance)
+		}
+	}
+	return healthy
+}
+
+/**
+ * @Description: Parse comma separated host:port addresses into instances
+ * @Param name: Service name
+ * @Param address: Comma separated addresses
+ * @Return []Instance: Healthy instances with the default weight
+ * @Return error: Error message
+ */
+func ParseAddresses(name string, address string) ([]Instance, error) {
+	instances := make([]Instance, 0)
+	for _, v := range strings.Split(address, ",") {
+		if v == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(v)
+		if err != nil {
+			return nil, err
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, Instance{
+			Id:      v,
+			Name:    name,
+			Host:    host,
+			Port:    p,
+			Weight:  DEFAULT_WEIGHT,
+			Healthy: true,
+		})
+	}
+	return instances, nil
+}
+
+/**
+ * @Description: Parse comma separated unix socket paths into instances, a path starting with @ is an abstract socket
+ * @Param name: Service name
+ * @Param address: Comma separated socket paths
+ * @Return []Instance: Healthy unix instances with the default weight, the path is their host
+ */
+func ParseUnixAddresses(name string, address string) []Instance {
+	instances := make([]Instance, 0)
+	for _, v := range strings.Split(address, ",") {
+		if v == "" {
+			continue
+		}
+		instances = append(instances, Instance{
+			Id:       v,
+			Name:     name,
+			Protocol: "unix",
+			Host:     v,
+			Weight:   DEFAULT_WEIGHT,
+			Healthy:  true,
+		})
+	}
+	return instances
+}
@@ -1,14 +1,17 @@
 package nacos
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sunquakes/jsonrpc4go/discovery"
@@ -29,6 +32,11 @@ const HEARTBEAT_INTERVAL = 5
  */
 const HEARTBEAT_RETRY_MAX = 3
 
+/**
+ * @Description: Interval between two polls of a watched service
+ */
+var WATCH_INTERVAL = 5 * time.Second
+
 /**
  * @Description: Read HTTP response body
  * @Param body: HTTP response body
@@ -46,6 +54,8 @@ func ReadAll(body io.ReadCloser) ([]byte, error) {
  * @Field Ephemeral: Whether it is an ephemeral instance
  * @Field HeartbeatList: Heartbeat service list
  * @Field HeartbeatRetry: Heartbeat retry count
+ * @Field HeartbeatStop: Channel closed to stop the heartbeat
+ * @Field Lock: Mutex lock protecting the heartbeat state
  */
 type Nacos struct {
 	URL            *url.URL
@@ -53,6 +63,8 @@ type Nacos struct {
 	Ephemeral      string
 	HeartbeatList  []Service
 	HeartbeatRetry map[string]int
+	HeartbeatStop  chan struct{}
+	Lock           sync.Mutex
 }
 
 /**
@@ -69,12 +81,18 @@ type GetResp struct {
  * @Field Port: Service port number
  * @Field Healthy: Whether healthy
  * @Field InstanceId: Instance ID
+ * @Field Weight: Instance weight
+ * @Field ClusterName: Cluster name
+ * @Field Metadata: Instance metadata
  */
 type Service struct {
-	Ip         string `json:"ip"`
-	Port       int    `json:"port"`
-	Healthy    bool   `json:"healthy"`
-	InstanceId string `json:"instanceId"`
+	Ip          string            `json:"ip"`
+	Port        int               `json:"port"`
+	Healthy     bool              `json:"healthy"`
+	InstanceId  string            `json:"instanceId"`
+	Weight      float64           `json:"weight"`
+	ClusterName string            `json:"clusterName"`
+	Metadata    map[string]string `json:"metadata"`
 }
 
 /**
@@ -93,7 +111,13 @@ func NewNacos(rawURL string) (discovery.Driver, error) {
 		ephemeral = URL.Query().Get("ephemeral")
 
 	}
-	nacos := &Nacos{URL, URL.Query().Get("token"), ephemeral, make([]Service, 0), make(map[string]int)}
+	nacos := &Nacos{
+		URL:            URL,
+		Token:          URL.Query().Get("token"),
+		Ephemeral:      ephemeral,
+		HeartbeatList:  make([]Service, 0),
+		HeartbeatRetry: make(map[string]int),
+	}
 	return nacos, err
 }
 
@@ -107,18 +131,12 @@ func NewNacos(rawURL string) (discovery.Driver, error) {
  * @Return error: Error message
  */
 func (d *Nacos) Register(name string, protocol string, hostname string, port int) error {
-	query := make(map[string]string)
-	// Get the instanceId from url
-	query["serviceName"] = name
-	query["ip"] = hostname
-	query["port"] = strconv.Itoa(port)
-	queries := d.URL.Query()
-	for k, v := range queries {
-		if len(v) > 0 {
-			query[k] = v[0]
-		}
+	query := d.InstanceQuery(name, hostname, port)
+	metadata, err := json.Marshal(map[string]string{discovery.META_PROTOCOL: protocol})
+	if err != nil {
+		return err
 	}
-	query["ephemeral"] = d.Ephemeral
+	query["metadata"] = string(metadata)
 	URL, err := GetURL(d.URL.Redacted(), "/nacos/v1/ns/instance", query)
 	if err != nil {
 		return err
@@ -140,14 +158,84 @@ func (d *Nacos) Register(name string, protocol string, hostname string, port int
 		return errors.New(string(body))
 	}
 	if d.Ephemeral == IS_EPHEMERAL {
+		d.Lock.Lock()
 		if len(d.HeartbeatList) == 0 {
 			d.Heartbeat()
 		}
-		d.HeartbeatList = append(d.HeartbeatList, Service{hostname, port, true, name})
+		d.HeartbeatList = append(d.HeartbeatList, Service{Ip: hostname, Port: port, Healthy: true, InstanceId: name})
+		d.Lock.Unlock()
 	}
 	return nil
 }
 
+/**
+ * @Description: Deregister service and stop sending its heartbeat
+ * @Receiver d: Nacos structure pointer
+ * @Param name: Service name
+ * @Param protocol: Protocol type
+ * @Param hostname: Hostname
+ * @Param port: Port number
+ * @Return error: Error message
+ */
+func (d *Nacos) Deregister(name string, protocol string, hostname string, port int) error {
+	if d.Ephemeral == IS_EPHEMERAL {
+		// Stop the heartbeat first, otherwise it would register the instance again.
+		d.Lock.Lock()
+		for i, service := range d.HeartbeatList {
+			if service.InstanceId == name && service.Ip == hostname && service.Port == port {
+				d.removeHeartbeatAt(i)
+				break
+			}
+		}
+		d.Lock.Unlock()
+	}
+	URL, err := GetURL(d.URL.Redacted(), "/nacos/v1/ns/instance", d.InstanceQuery(name, hostname, port))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("DELETE", URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != STATUS_CODE_PASSING {
+		body, err := ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return errors.New(string(body))
+	}
+	return nil
+}
+
+/**
+ * @Description: Build the query identifying a service instance
+ * @Receiver d: Nacos structure pointer
+ * @Param name: Service name
+ * @Param hostname: Hostname
+ * @Param port: Port number
+ * @Return map[string]string: Query parameters
+ */
+func (d *Nacos) InstanceQuery(name string, hostname string, port int) map[string]string {
+	query := make(map[string]string)
+	// Get the instanceId from url
+	query["serviceName"] = name
+	query["ip"] = hostname
+	query["port"] = strconv.Itoa(port)
+	queries := d.URL.Query()
+	for k, v := range queries {
+		if len(v) > 0 {
+			query[k] = v[0]
+		}
+	}
+	query["ephemeral"] = d.Ephemeral
+	return query
+}
+
 /**
  * @Description: Get service address list
  * @Receiver d: Nacos structure pointer
@@ -156,37 +244,109 @@ func (d *Nacos) Register(name string, protocol string, hostname string, port int
  * @Return error: Error message
  */
 func (d *Nacos) Get(name string) (string, error) {
+	instances, err := d.GetInstances(name)
+	if err != nil {
+		return "", err
+	}
+	instances = discovery.Healthy(instances)
+	if len(instances) == 0 {
+		return "", errors.New("unable to get service url")
+	}
+	return strings.Join(discovery.Addresses(instances), ","), nil
+}
+
+/**
+ * @Description: Get service instances
+ * @Receiver d: Nacos structure pointer
+ * @Param name: Service name
+ * @Return []discovery.Instance: Instance list
+ * @Return error: Error message
+ */
+func (d *Nacos) GetInstances(name string) ([]discovery.Instance, error) {
+	return d.Instances(context.Background(), name)
+}
+
+/**
+ * @Description: Get service instances bound to a context
+ * @Receiver d: Nacos structure pointer
+ * @Param ctx: Context of the request
+ * @Param name: Service name
+ * @Return []discovery.Instance: Instance list
+ * @Return error: Error message
+ */
+func (d *Nacos) Instances(ctx context.Context, name string) ([]discovery.Instance, error) {
 	query := make(map[string]string)
 	query["serviceName"] = name
 	URL, err := GetURL(d.URL.Redacted(), "/nacos/v1/ns/instance/list", query)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	resp, err := http.Get(URL)
+	req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 	body, err := ReadAll(resp.Body)
 	if resp.StatusCode != STATUS_CODE_PASSING {
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		return "", errors.New(string(body))
+		return nil, errors.New(string(body))
+	}
+	if err != nil {
+		return nil, err
 	}
 	var gr GetResp
 	json.Unmarshal(body, &gr)
-	ua := make([]string, 0)
+	instances := make([]discovery.Instance, 0, len(gr.Hosts))
 	for _, v := range gr.Hosts {
-		if !v.Healthy {
-			continue
-		}
-		ua = append(ua, fmt.Sprintf("%s:%d", v.Ip, v.Port))
+		instances = append(instances, v.Instance(name))
 	}
-	if len(ua) == 0 {
-		return "", errors.New("unable to get service url")
+	return instances, nil
+}
+
+/**
+ * @Description: Convert the service to an instance
+ * @Receiver s: Service structure
+ * @Param name: Service name
+ * @Return discovery.Instance: Service instance
+ */
+func (s Service) Instance(name string) discovery.Instance {
+	zone := s.Metadata[discovery.META_ZONE]
+	if zone == "" {
+		zone = s.ClusterName
+	}
+	return discovery.Instance{
+		Id:       s.InstanceId,
+		Name:     name,
+		Protocol: s.Metadata[discovery.META_PROTOCOL],
+		Host:     s.Ip,
+		Port:     s.Port,
+		Weight:   s.Weight,
+		Healthy:  s.Healthy,
+		Zone:     zone,
+		Version:  s.Metadata[discovery.META_VERSION],
+		Meta:     s.Metadata,
 	}
-	return strings.Join(ua, ","), err
+}
+
+/**
+ * @Description: Watch the instances of a service by polling
+ * @Receiver d: Nacos structure pointer
+ * @Param name: Service name
+ * @Return <-chan []discovery.Instance: Channel receiving the instance lists
+ * @Return func(): Function stopping the watch
+ */
+func (d *Nacos) Watch(name string) (<-chan []discovery.Instance, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := discovery.Poll(ctx, WATCH_INTERVAL, func(ctx context.Context) ([]discovery.Instance, error) {
+		return d.Instances(ctx, name)
+	})
+	return ch, cancel
 }
 
 /**
@@ -198,19 +358,7 @@ func (d *Nacos) Get(name string) (string, error) {
  * @Return error: Error message
  */
 func (d *Nacos) Beat(name string, hostname string, port int) error {
-	query := make(map[string]string)
-	// Get the instanceId from url
-	query["serviceName"] = name
-	query["ip"] = hostname
-	query["port"] = strconv.Itoa(port)
-	queries := d.URL.Query()
-	for k, v := range queries {
-		if len(v) > 0 {
-			query[k] = v[0]
-		}
-	}
-	query["ephemeral"] = d.Ephemeral
-	URL, err := GetURL(d.URL.Redacted(), "/nacos/v1/ns/instance/beat", query)
+	URL, err := GetURL(d.URL.Redacted(), "/nacos/v1/ns/instance/beat", d.InstanceQuery(name, hostname, port))
 	if err != nil {
 		return err
 	}
@@ -234,16 +382,23 @@ func (d *Nacos) Beat(name string, hostname string, port int) error {
 }
 
 /**
- * @Description: Start heartbeat mechanism
+ * @Description: Start heartbeat mechanism, the caller must hold the lock
  * @Receiver d: Nacos structure pointer
  * @Return error: Error message
  */
 func (d *Nacos) Heartbeat() error {
+	stop := make(chan struct{})
+	d.HeartbeatStop = stop
 	go func() {
 		ticker := time.NewTicker(time.Second * HEARTBEAT_INTERVAL)
 		defer ticker.Stop()
-		for range ticker.C {
-			d.DoHeartbeat()
+		for {
+			select {
+			case <-ticker.C:
+				d.DoHeartbeat()
+			case <-stop:
+				return
+			}
 		}
 	}()
 	return nil
@@ -254,7 +409,10 @@ func (d *Nacos) Heartbeat() error {
  * @Receiver d: Nacos structure pointer
  */
 func (d *Nacos) DoHeartbeat() {
-	for _, service := range d.HeartbeatList {
+	d.Lock.Lock()
+	services := slices.Clone(d.HeartbeatList)
+	d.Lock.Unlock()
+	for _, service := range services {
 		err := d.Beat(service.InstanceId, service.Ip, service.Port)
 		if err != nil {
 			key := fmt.Sprintf("%s-%d", service.Ip, service.Port)
@@ -269,9 +427,11 @@ func (d *Nacos) DoHeartbeat() {
  * @Param key: Service instance identifier (ip-port)
  */
 func (d *Nacos) RetryHeartbeat(key string) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
 	if times, ok := d.HeartbeatRetry[key]; ok {
 		if times >= HEARTBEAT_RETRY_MAX {
-			d.RemoveHeartbeat(key)
+			d.removeHeartbeat(key)
 		} else {
 			d.HeartbeatRetry[key]++
 		}
@@ -286,10 +446,36 @@ func (d *Nacos) RetryHeartbeat(key string) {
  * @Param key: Service instance identifier (ip-port)
  */
 func (d *Nacos) RemoveHeartbeat(key string) {
+	d.Lock.Lock()
+	defer d.Lock.Unlock()
+	d.removeHeartbeat(key)
+}
+
+/**
+ * @Description: Remove heartbeat service, the caller must hold the lock
+ * @Receiver d: Nacos structure pointer
+ * @Param key: Service instance identifier (ip-port)
+ */
+func (d *Nacos) removeHeartbeat(key string) {
 	for i, service := range d.HeartbeatList {
 		if fmt.Sprintf("%s-%d", service.Ip, service.Port) == key {
-			d.HeartbeatList = append(d.HeartbeatList[:i], d.HeartbeatList[i+1:]...)
+			d.removeHeartbeatAt(i)
 			break
 		}
 	}
 }
+
+/**
+ * @Description: Remove the heartbeat service at the index and stop the heartbeat when no service is left, the caller must hold the lock
+ * @Receiver d: Nacos structure pointer
+ * @Param i: Index in the heartbeat service list
+ */
+func (d *Nacos) removeHeartbeatAt(i int) {
+	service := d.HeartbeatList[i]
+	d.HeartbeatList = append(d.HeartbeatList[:i], d.HeartbeatList[i+1:]...)
+	delete(d.HeartbeatRetry, fmt.Sprintf("%s-%d", service.Ip, service.Port))
+	if len(d.HeartbeatList) == 0 && d.HeartbeatStop != nil {
+		close(d.HeartbeatStop)
+		d.HeartbeatStop = nil
+	}
+}
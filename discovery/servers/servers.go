@@ -32,6 +32,18 @@ func (d *Servers) Register(name string, protocol string, hostname string, port i
 	return nil
 }
 
+/**
+ * @Description: Deregister service (static server list doesn't need actual deregistration)
+ * @Param name: Service name
+ * @Param protocol: Protocol type
+ * @Param hostname: Hostname
+ * @Param port: Port number
+ * @Return error: Error message
+ */
+func (d *Servers) Deregister(name string, protocol string, hostname string, port int) error {
+	return nil
+}
+
 /**
  * @Description: Get server address
  * @Param name: Service name
@@ -41,3 +53,13 @@ func (d *Servers) Register(name string, protocol string, hostname string, port i
 func (d *Servers) Get(name string) (string, error) {
 	return string(*d), nil
 }
+
+/**
+ * @Description: Get server instances
+ * @Param name: Service name
+ * @Return []discovery.Instance: Instance list parsed from the comma separated addresses
+ * @Return error: Error message
+ */
+func (d *Servers) GetInstances(name string) ([]discovery.Instance, error) {
+	return discovery.ParseAddresses(name, string(*d))
+}
@@ -0,0 +1,77 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+)
+
+/**
+ * @Description: Interval before fetching the instances again after a failed fetch
+ */
+var WATCH_RETRY_INTERVAL = 3 * time.Second
+
+/**
+ * @Description: Maximum interval before fetching the instances again, the interval doubles after each failed fetch
+ */
+var WATCH_MAX_RETRY_INTERVAL = time.Minute
+
+/**
+ * @Description: Optional service discovery driver capability pushing instance changes
+ */
+type Watcher interface {
+	/**
+	 * @Description: Watch the instances of a service
+	 * @Param name: Service name
+	 * @Return <-chan []Instance: Channel receiving the current instances first and then every changed list, closed after cancel
+	 * @Return func(): Function stopping the watch
+	 */
+	Watch(name string) (<-chan []Instance, func())
+}
+
+/**
+ * @Description: Fetch the instances repeatedly until the context is canceled, sending every changed list
+ * @Param ctx: Context stopping the polling
+ * @Param interval: Interval between two successful fetches, zero when fetch blocks until the instances change; a failed fetch
+ * is retried after WATCH_RETRY_INTERVAL, doubled after each failure up to WATCH_MAX_RETRY_INTERVAL
+ * @Param fetch: Function fetching the instances
+ * @Return <-chan []Instance: Channel receiving the instances, closed when the context is canceled
+ */
+func Poll(ctx context.Context, interval time.Duration, fetch func(ctx context.Context) ([]Instance, error)) <-chan []Instance {
+	ch := make(chan []Instance)
+	go func() {
+		defer close(ch)
+		var last []Instance
+		sent := false
+		retry := WATCH_RETRY_INTERVAL
+		for {
+			wait := interval
+			instances, err := fetch(ctx)
+			if err != nil {
+				// Back off while the discovery service fails.
+				wait = retry
+				retry = min(retry*2, WATCH_MAX_RETRY_INTERVAL)
+			} else {
+				retry = WATCH_RETRY_INTERVAL
+				sort.Slice(instances, func(i, j int) bool {
+					return instances[i].Address() < instances[j].Address()
+				})
+				if !sent || !reflect.DeepEqual(instances, last) {
+					select {
+					case ch <- instances:
+					case <-ctx.Done():
+						return
+					}
+					last, sent = instances, true
+				}
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
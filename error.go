@@ -0,0 +1,22 @@
+package jsonrpc4go
+
+import (
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/**
+ * @Description: JSON-RPC error with code, message and data. Service methods, hooks and middleware
+ * return it to send it as is, clients return it for error responses, use errors.As to get it
+ */
+type Error = common.Error
+
+/**
+ * @Description: Create a JSON-RPC error
+ * @Param code: Error code
+ * @Param message: Error message
+ * @Param data: Additional information about the error
+ * @Return *Error: Error structure pointer
+ */
+func NewError(code int, message string, data any) *Error {
+	return common.NewError(code, message, data)
+}
@@ -9,12 +9,13 @@ import (
 
 /**
  * @Description: Create a new JSON-RPC server
- * @Param protocol: Protocol type (http/https/tcp)
- * @Param port: Port number
+ * @Param protocol: Protocol type (http/https/tcp/ws/wss/unix/stdio)
+ * @Param port: Port number, ignored by unix and stdio
+ * @Param path: Socket path for unix, abstract when starting with @
  * @Return server.Server: Server interface
  * @Return error: Error message
  */
-func NewServer(protocol string, port int) (server.Server, error) {
+func NewServer(protocol string, port int, path ...string) (server.Server, error) {
 	var p server.Protocol
 	switch strings.ToLower(protocol) {
 	case "http":
@@ -23,6 +24,17 @@ func NewServer(protocol string, port int) (server.Server, error) {
 		p = &server.Http{Port: port, Secure: true}
 	case "tcp":
 		p = &server.Tcp{Port: port}
+	case "ws":
+		p = &server.WebSocket{Port: port}
+	case "wss":
+		p = &server.WebSocket{Port: port, Secure: true}
+	case "unix":
+		if len(path) == 0 {
+			return nil, errors.New("the unix socket path is required")
+		}
+		p = &server.Unix{Path: path[0]}
+	case "stdio":
+		p = &server.Stdio{}
 	default:
 		return nil, errors.New("the protocol can not be supported")
 	}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/sunquakes/jsonrpc4go/common"
 	"github.com/sunquakes/jsonrpc4go/discovery"
+	"golang.org/x/net/websocket"
 	"golang.org/x/time/rate"
 )
 
@@ -59,25 +61,43 @@ type Http struct {
  * @property Event - The event channel for server notifications
  * @property Discovery - The service discovery driver
  * @property Secure - Whether to use HTTPS
+ * @property Path - The path requests are served on, every path when empty
  */
 type HttpServer struct {
-	Hostname  string
-	Port      int
-	Server    common.Server
-	Options   HttpOptions
-	Event     chan int
-	Discovery discovery.Driver
-	Secure    bool
+	Hostname   string
+	Port       int
+	Server     common.Server
+	Options    HttpOptions
+	Event      chan int
+	Discovery  discovery.Driver
+	Secure     bool
+	Path       string
+	mu         sync.Mutex
+	httpServer *http.Server
+	closed     bool
+	webSocket  bool
+	wsConns    map[*websocket.Conn]struct{}
+	wsActive   sync.WaitGroup
 }
 
 /*
  * HttpOptions represents the options for the HTTP server
  * @property CertPath - The path to the certificate file
  * @property KeyPath - The path to the key file
+ * @property WebSocket - Whether WebSocket connections are also accepted on the same port and path
+ * @property AllowedOrigins - The origins besides the server's own whose pages may open WebSocket connections,
+ * e.g. "https://app.example.com", "*" allows every origin
+ * @property CheckOrigin - The function deciding whether a WebSocket handshake is accepted, replacing AllowedOrigins when set
+ * @property MaxConcurrency - The maximum number of requests of a WebSocket connection processed at once,
+ * DEFAULT_MAX_CONCURRENCY when 0 and unlimited when negative
  */
 type HttpOptions struct {
-	CertPath string
-	KeyPath  string
+	CertPath       string
+	KeyPath        string
+	WebSocket      bool
+	AllowedOrigins []string
+	CheckOrigin    func(r *http.Request) bool
+	MaxConcurrency int
 }
 
 /*
@@ -103,10 +123,29 @@ func (p *Http) NewServer() Server {
 
 /*
  * Start starts the HTTP server
+ * @return error - ErrServerClosed after Shutdown, otherwise the error which stopped the server
  */
-func (s *HttpServer) Start() {
+func (s *HttpServer) Start() error {
 	if s.Secure && (s.Options.CertPath == "" || s.Options.KeyPath == "") {
-		log.Panic("CertPath or KeyPath is empty.")
+		return errors.New("CertPath or KeyPath is empty")
+	}
+	mux := http.NewServeMux()
+	path := s.Path
+	if path == "" {
+		path = "/"
+	}
+	mux.HandleFunc(path, s.handleFunc)
+	var url = fmt.Sprintf("0.0.0.0:%d", s.Port)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.httpServer = &http.Server{Addr: url, Handler: mux}
+	s.mu.Unlock()
+	listener, err := net.Listen("tcp", url)
+	if err != nil {
+		return err
 	}
 	// Register services
 	if s.Discovery != nil {
@@ -116,14 +155,7 @@ func (s *HttpServer) Start() {
 		}
 		s.Server.Sm.Range(register)
 	}
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.handleFunc)
-	var url = fmt.Sprintf("0.0.0.0:%d", s.Port)
-	if s.Secure {
-		log.Printf("Listening https://0.0.0.0:%d", s.Port)
-	} else {
-		log.Printf("Listening http://0.0.0.0:%d", s.Port)
-	}
+	log.Printf("Listening %s://0.0.0.0:%d", s.protocol(), s.Port)
 	// Notify successful start: send 0 to the Event channel after 1 second to indicate the service is ready
 	go func() {
 		time.Sleep(time.Second)
@@ -133,15 +165,41 @@ func (s *HttpServer) Start() {
 			// Drop if the channel is full to avoid blocking
 		}
 	}()
-	var err error
 	if s.Secure {
-		err = http.ListenAndServeTLS(url, s.Options.CertPath, s.Options.KeyPath, mux)
+		err = s.httpServer.ServeTLS(listener, s.Options.CertPath, s.Options.KeyPath)
 	} else {
-		err = http.ListenAndServe(url, mux)
+		err = s.httpServer.Serve(listener)
 	}
-	if err != nil {
-		log.Panic(err.Error())
+	if errors.Is(err, http.ErrServerClosed) {
+		return ErrServerClosed
 	}
+	return err
+}
+
+/*
+ * Shutdown gracefully stops the HTTP server
+ * @param ctx - The context bounding the wait for in-flight requests
+ * @return error - An error if in-flight requests did not finish in time or deregistration failed
+ */
+func (s *HttpServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	httpServer := s.httpServer
+	s.mu.Unlock()
+	var err error
+	if s.Discovery != nil {
+		err = deregister(s.Discovery, &s.Server.Sm, s.protocol(), s.Hostname, s.Port)
+	}
+	if httpServer != nil {
+		if e := httpServer.Shutdown(ctx); e != nil {
+			err = errors.Join(err, e)
+		}
+	}
+	// WebSocket connections are hijacked, the HTTP server does not wait for them.
+	if e := s.shutdownWebSockets(ctx); e != nil && !errors.Is(err, e) {
+		err = errors.Join(err, e)
+	}
+	return err
 }
 
 /*
@@ -151,12 +209,17 @@ func (s *HttpServer) Start() {
  * @return bool - True if registration succeeded
  */
 func (s *HttpServer) DiscoveryRegister(key, value interface{}) bool {
-	err := s.Discovery.Register(key.(string), "http", s.Hostname, s.Port)
+	err := s.Discovery.Register(key.(string), s.protocol(), s.Hostname, s.Port)
 	if err == nil {
 		return true
 	}
 	time.Sleep(REGISTRY_RETRY_INTERVAL * time.Millisecond)
-	s.DiscoveryRegister(key, value)
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if !closed {
+		s.DiscoveryRegister(key, value)
+	}
 	return false
 }
 
@@ -171,6 +234,27 @@ func (s *HttpServer) Register(m any) {
 	}
 }
 
+/*
+ * RegisterName registers a service under a name
+ * @param name - The service name, empty for flat method names
+ * @param m - The service to register
+ * @param opts - The aliases and excluded methods
+ * @return error - The error if a name is already registered
+ */
+func (s *HttpServer) RegisterName(name string, m any, opts ...RegisterOption) error {
+	return s.Server.RegisterName(name, m, opts...)
+}
+
+/*
+ * RegisterFunc registers a function as a method
+ * @param name - The method name
+ * @param fn - The function to register
+ * @return error - The error if the function does not conform or the name is already registered
+ */
+func (s *HttpServer) RegisterFunc(name string, fn any) error {
+	return s.Server.RegisterFunc(name, fn)
+}
+
 /*
  * SetOptions sets the HTTP server options
  * @param httpOptions - The HTTP server options
@@ -221,6 +305,64 @@ func (s *HttpServer) SetAfterFunc(afterFunc func(id any, method string, result a
 	s.Server.Hooks.AfterFunc = afterFunc
 }
 
+/*
+ * SetBeforeContextFunc sets the context-aware before function
+ * @param beforeFunc - The before function
+ */
+func (s *HttpServer) SetBeforeContextFunc(beforeFunc func(ctx context.Context, id any, method string, params any) error) {
+	s.Server.Hooks.BeforeContextFunc = beforeFunc
+}
+
+/*
+ * SetAfterContextFunc sets the context-aware after function
+ * @param afterFunc - The after function
+ */
+func (s *HttpServer) SetAfterContextFunc(afterFunc func(ctx context.Context, id any, method string, result any) error) {
+	s.Server.Hooks.AfterContextFunc = afterFunc
+}
+
+/*
+ * SetLenient sets whether to accept legacy requests without or with another JSON-RPC version,
+ * a legacy request with a null id is a notification
+ * @param lenient - Whether to accept legacy requests
+ */
+func (s *HttpServer) SetLenient(lenient bool) {
+	s.Server.Lenient = lenient
+}
+
+/*
+ * SetBindOptions sets the options binding request params to the params of the service methods
+ * @param options - The binding options
+ */
+func (s *HttpServer) SetBindOptions(options BindOptions) {
+	s.Server.BindOptions = options
+}
+
+/*
+ * SetBatchParallelism sets the maximum number of batch elements processed at once
+ * @param n - The maximum number of batch elements processed at once, one after the other when 1 or less
+ */
+func (s *HttpServer) SetBatchParallelism(n int) {
+	s.Server.BatchParallelism = n
+}
+
+/*
+ * SetPanicHandler sets the function called when a service method, a hook or a middleware panics,
+ * the request still gets an internal error response
+ * @param panicHandler - The panic handler, receiving the request context, the panic value and the stack
+ */
+func (s *HttpServer) SetPanicHandler(panicHandler func(ctx context.Context, p any, stack []byte)) {
+	s.Server.PanicHandler = panicHandler
+}
+
+/*
+ * Use appends middleware to the invocation chain
+ * @param mw - The middleware, the first one added is the outermost
+ */
+func (s *HttpServer) Use(mw ...Middleware) {
+	s.Server.Use(mw...)
+}
+
 /*
  * GetEvent returns the event channel
  * @return <-chan int - The event channel
@@ -239,6 +381,15 @@ func (s *HttpServer) handleFunc(w http.ResponseWriter, r *http.Request) {
 		err  error
 		data []byte
 	)
+	if isWebSocket(r) && (s.webSocket || s.Options.WebSocket) {
+		s.serveWebSocket(w, r)
+		return
+	}
+	if s.webSocket {
+		w.Header().Set("Upgrade", "websocket")
+		w.WriteHeader(http.StatusUpgradeRequired)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	if data, err = io.ReadAll(r.Body); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -248,9 +399,36 @@ func (s *HttpServer) handleFunc(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	res := s.Server.Handler(data)
+	transport := "http"
+	if s.Secure {
+		transport = "https"
+	}
+	ctx := common.WithPeer(r.Context(), &common.Peer{Transport: transport, RemoteAddr: r.RemoteAddr, Header: r.Header})
+	res := s.Server.HandlerContext(ctx, data)
+	if len(res) == 0 {
+		// Notifications get no response.
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	_, err = w.Write(res)
 	if err != nil {
 		log.Panic(err.Error())
 	}
 }
+
+/*
+ * protocol gets the protocol the server is registered with
+ * @return string - http, https, ws or wss
+ */
+func (s *HttpServer) protocol() string {
+	switch {
+	case s.webSocket && s.Secure:
+		return "wss"
+	case s.webSocket:
+		return "ws"
+	case s.Secure:
+		return "https"
+	}
+	return "http"
+}
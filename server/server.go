@@ -1,12 +1,93 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go/common"
 	"github.com/sunquakes/jsonrpc4go/discovery"
 	"golang.org/x/time/rate"
 )
 
 const REGISTRY_RETRY_INTERVAL = 3000
 
+/*
+ * SHUTDOWN_POLL_INTERVAL is the interval used to check whether in-flight requests are drained.
+ */
+const SHUTDOWN_POLL_INTERVAL = 10 * time.Millisecond
+
+/*
+ * DEFAULT_MAX_CONCURRENCY is the number of requests of a connection processed at once when MaxConcurrency is 0.
+ */
+const DEFAULT_MAX_CONCURRENCY = 64
+
+/*
+ * ErrServerClosed is returned by Start after Shutdown has been called.
+ */
+var ErrServerClosed = errors.New("jsonrpc4go: server closed")
+
+/*
+ * Invocation describes a resolved JSON-RPC call passed through the middleware chain.
+ */
+type Invocation = common.Invocation
+
+/*
+ * Handler processes an invocation.
+ */
+type Handler = common.Handler
+
+/*
+ * Middleware wraps a handler, see Server.Use.
+ */
+type Middleware = common.Middleware
+
+/*
+ * BindOptions configures how request params are bound to the params of the service methods.
+ */
+type BindOptions = common.BindOptions
+
+/*
+ * RegisterOption sets a registration option, see Server.RegisterName.
+ */
+type RegisterOption = common.RegisterOption
+
+/*
+ * WithAlias exposes a method under another name, e.g. WithAlias("BlockNumber", "eth_blockNumber").
+ */
+var WithAlias = common.WithAlias
+
+/*
+ * WithExclude keeps methods matching the signature from being exposed.
+ */
+var WithExclude = common.WithExclude
+
+/*
+ * Framer splits a tcp stream into messages, see TcpOptions.Framer.
+ */
+type Framer = common.Framer
+
+/*
+ * NewDelimiterFramer creates a framer ending each message with a delimiter, the default framer.
+ */
+var NewDelimiterFramer = common.NewDelimiterFramer
+
+/*
+ * NewLengthPrefixFramer creates a framer preceding each message with its size as a 4-byte big-endian integer.
+ */
+var NewLengthPrefixFramer = common.NewLengthPrefixFramer
+
+/*
+ * NewContentLengthFramer creates a framer preceding each message with a Content-Length header.
+ */
+var NewContentLengthFramer = common.NewContentLengthFramer
+
+/*
+ * NewNDJSONFramer creates a framer sending each message on its own line.
+ */
+var NewNDJSONFramer = common.NewNDJSONFramer
+
 /*
  * Protocol defines the interface for server protocol implementations.
  */
@@ -40,6 +121,67 @@ type Server interface {
 	 */
 	SetAfterFunc(func(id any, method string, result any) error)
 
+	/*
+	 * SetBeforeContextFunc sets a context-aware callback function to be executed before processing a request.
+	 *
+	 * Parameters:
+	 *   func(ctx context.Context, id any, method string, params any) error - Callback function to execute before processing
+	 */
+	SetBeforeContextFunc(func(ctx context.Context, id any, method string, params any) error)
+
+	/*
+	 * SetAfterContextFunc sets a context-aware callback function to be executed after processing a request.
+	 *
+	 * Parameters:
+	 *   func(ctx context.Context, id any, method string, result any) error - Callback function to execute after processing
+	 */
+	SetAfterContextFunc(func(ctx context.Context, id any, method string, result any) error)
+
+	/*
+	 * Use appends middleware wrapping every invocation after the method is resolved and its params decoded.
+	 * The first middleware added is the outermost; the hooks and the service method run innermost.
+	 *
+	 * Parameters:
+	 *   mw ...Middleware - Middleware to append
+	 */
+	Use(mw ...Middleware)
+
+	/*
+	 * SetLenient sets whether to accept legacy requests without a "jsonrpc" member or with another version.
+	 * By default a request which does not follow JSON-RPC 2.0 gets an invalid request error (-32600).
+	 * In lenient mode a legacy request with a null id is a notification, as in JSON-RPC 1.0.
+	 *
+	 * Parameters:
+	 *   bool - Whether to accept legacy requests
+	 */
+	SetLenient(bool)
+
+	/*
+	 * SetBindOptions configures how request params are bound to the params of the service methods.
+	 *
+	 * Parameters:
+	 *   options BindOptions - Binding options
+	 */
+	SetBindOptions(options BindOptions)
+
+	/*
+	 * SetBatchParallelism sets how many elements of a batch are processed at once.
+	 * By default they are processed one after the other; the responses keep the order of the requests in every case.
+	 *
+	 * Parameters:
+	 *   n int - Maximum number of batch elements processed at once
+	 */
+	SetBatchParallelism(n int)
+
+	/*
+	 * SetPanicHandler sets a callback function executed when a service method, a hook or a middleware panics.
+	 * The panic is recovered and answered with an internal error (-32603) in every case.
+	 *
+	 * Parameters:
+	 *   func(ctx context.Context, p any, stack []byte) - Callback receiving the request context, the panic value and the stack
+	 */
+	SetPanicHandler(func(ctx context.Context, p any, stack []byte))
+
 	/*
 	 * SetOptions configures protocol-specific options for the server.
 	 *
@@ -68,8 +210,24 @@ type Server interface {
 
 	/*
 	 * Start starts the server and begins listening for requests.
+	 * It blocks until the server stops.
+	 *
+	 * Returns:
+	 *   error - ErrServerClosed after Shutdown, otherwise the error which stopped the server
+	 */
+	Start() error
+
+	/*
+	 * Shutdown gracefully stops the server: it deregisters the services from the discovery
+	 * service, stops accepting connections, waits for in-flight requests and closes idle connections.
+	 *
+	 * Parameters:
+	 *   ctx context.Context - Context bounding the wait for in-flight requests
+	 *
+	 * Returns:
+	 *   error - The context error if in-flight requests did not finish in time, or a deregistration error
 	 */
-	Start()
+	Shutdown(ctx context.Context) error
 
 	/*
 	 * Register registers a service with the server.
@@ -79,6 +237,33 @@ type Server interface {
 	 */
 	Register(s any)
 
+	/*
+	 * RegisterName registers a service under a name, its methods are exposed as name.Method.
+	 * The name may hold dots for nested namespaces, e.g. v1.users, or be empty to expose the methods
+	 * without a service prefix.
+	 *
+	 * Parameters:
+	 *   name string            - Service name, empty for flat method names
+	 *   s    any               - Service object to register
+	 *   opts ...RegisterOption - Aliases and excluded methods, see WithAlias and WithExclude
+	 *
+	 * Returns:
+	 *   error - Error if a name is already registered or an option names an unknown method
+	 */
+	RegisterName(name string, s any, opts ...RegisterOption) error
+
+	/*
+	 * RegisterFunc registers a function as a method, e.g. RegisterFunc("eth_blockNumber", blockNumber).
+	 *
+	 * Parameters:
+	 *   name string - Method name
+	 *   fn   any    - Function with the signature func([ctx context.Context, ]params *P, result *R) error
+	 *
+	 * Returns:
+	 *   error - Error if the function does not conform or the name is already registered
+	 */
+	RegisterFunc(name string, fn any) error
+
 	/*
 	 * DiscoveryRegister registers the server with the discovery service.
 	 *
@@ -112,3 +297,46 @@ type Server interface {
 func NewServer[T Protocol](p T) Server {
 	return p.NewServer()
 }
+
+/*
+ * deregister removes every registered service from the discovery service.
+ *
+ * Parameters:
+ *   d        discovery.Driver - Service discovery driver
+ *   sm       *sync.Map        - Map of registered services
+ *   protocol string           - Protocol the services were registered with
+ *   hostname string           - Hostname the services were registered with
+ *   port     int              - Port the services were registered with
+ *
+ * Returns:
+ *   error - The first deregistration error
+ */
+func deregister(d discovery.Driver, sm *sync.Map, protocol string, hostname string, port int) error {
+	var err error
+	sm.Range(func(key, value interface{}) bool {
+		if e := d.Deregister(key.(string), protocol, hostname, port); e != nil && err == nil {
+			err = e
+		}
+		return true
+	})
+	return err
+}
+
+/*
+ * concurrencySlots creates the semaphore bounding the requests of a connection processed at once.
+ *
+ * Parameters:
+ *   n int - Maximum number of requests processed at once, DEFAULT_MAX_CONCURRENCY when 0, unlimited when negative
+ *
+ * Returns:
+ *   chan struct{} - Semaphore holding a slot per request in process, nil when unlimited
+ */
+func concurrencySlots(n int) chan struct{} {
+	if n < 0 {
+		return nil
+	}
+	if n == 0 {
+		n = DEFAULT_MAX_CONCURRENCY
+	}
+	return make(chan struct{}, n)
+}
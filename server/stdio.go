@@ -0,0 +1,120 @@
+package server
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/*
+ * Stdio represents the stdio protocol implementation, served by a TcpServer over a single connection
+ * @property Reader - The reader receiving the requests, os.Stdin when nil
+ * @property Writer - The writer sending the responses, os.Stdout when nil
+ */
+type Stdio struct {
+	Reader io.Reader
+	Writer io.Writer
+}
+
+/*
+ * NewServer creates a new TCP server serving stdin and stdout, Start returns io.EOF once the input ends
+ * @return Server - The new TCP server
+ */
+func (p *Stdio) NewServer() Server {
+	var (
+		r io.Reader = os.Stdin
+		w io.Writer = os.Stdout
+	)
+	if p.Reader != nil {
+		r = p.Reader
+	}
+	if p.Writer != nil {
+		w = p.Writer
+	}
+	s := (&Tcp{}).NewServer().(*TcpServer)
+	s.Network = "stdio"
+	s.pipe = common.NewPipeConn(r, w)
+	return s
+}
+
+/*
+ * stdioListener accepts its connection once, then waits for it to be closed
+ * @property conn - The connection, nil once accepted
+ * @property done - The channel closed when the connection or the listener is closed
+ * @property once - The once closing done
+ * @property mu - The mutex guarding conn
+ */
+type stdioListener struct {
+	conn net.Conn
+	done chan struct{}
+	once sync.Once
+	mu   sync.Mutex
+}
+
+/*
+ * stdioConn is the connection of a stdioListener
+ * @property Conn - The pipe connection
+ * @property l - The listener notified when the connection is closed
+ */
+type stdioConn struct {
+	net.Conn
+	l *stdioListener
+}
+
+/*
+ * newStdioListener creates a listener accepting a single connection
+ * @param conn - The connection
+ * @return net.Listener - The listener
+ */
+func newStdioListener(conn net.Conn) net.Listener {
+	return &stdioListener{conn: conn, done: make(chan struct{})}
+}
+
+/*
+ * Accept returns the connection the first time, later calls block until it is closed
+ * @return net.Conn - The connection
+ * @return error - io.EOF once the connection or the listener is closed
+ */
+func (l *stdioListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	conn := l.conn
+	l.conn = nil
+	l.mu.Unlock()
+	if conn != nil {
+		return &stdioConn{Conn: conn, l: l}, nil
+	}
+	<-l.done
+	return nil, io.EOF
+}
+
+/*
+ * Close closes the listener, the accepted connection is left to the server
+ * @return error - Always nil
+ */
+func (l *stdioListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+/*
+ * Addr returns the stdio address
+ * @return net.Addr - The address
+ */
+func (l *stdioListener) Addr() net.Addr {
+	return common.NewPipeConn(nil, nil).LocalAddr()
+}
+
+/*
+ * Close closes the connection and ends the listener
+ * @return error - The error closing the connection
+ */
+func (c *stdioConn) Close() error {
+	err := c.Conn.Close()
+	c.l.Close()
+	return err
+}
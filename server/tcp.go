@@ -1,8 +1,10 @@
 package server
 
 import (
-	"bytes"
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -30,6 +32,8 @@ type Tcp struct {
  * @property Options - The TCP server options
  * @property Event - The event channel for server notifications
  * @property Discovery - The service discovery driver
+ * @property Network - The network to listen on, tcp, unix or stdio
+ * @property Path - The socket path for unix, abstract when starting with @
  */
 type TcpServer struct {
 	Hostname  string
@@ -38,16 +42,30 @@ type TcpServer struct {
 	Options   TcpOptions
 	Event     chan int
 	Discovery discovery.Driver
+	Network   string
+	Path      string
+	pipe      net.Conn
+	mu        sync.Mutex
+	listener  net.Listener
+	cancel    context.CancelFunc
+	conns     map[net.Conn]int
+	closed    bool
 }
 
 /*
  * TcpOptions represents the options for the TCP server
- * @property PackageEof - The end-of-file marker for packages
- * @property PackageMaxLength - The maximum length of a package
+ * @property PackageEof - The end-of-file marker for packages, used by the default framer
+ * @property PackageMaxLength - The maximum length of a package, a larger one gets a parse error and the connection is closed
+ * @property MaxConcurrency - The maximum number of requests of a connection processed at once, DEFAULT_MAX_CONCURRENCY when 0
+ * and unlimited when negative;
+ * with 1 the requests are processed and answered in order
+ * @property Framer - The framer splitting the stream into packages, a delimiter framer with PackageEof when nil
  */
 type TcpOptions struct {
 	PackageEof       string
 	PackageMaxLength int64
+	MaxConcurrency   int
+	Framer           Framer
 }
 
 /*
@@ -60,41 +78,51 @@ func (p *Tcp) NewServer() Server {
 		PackageMaxLength: 1024 * 1024 * 2,
 	}
 	return &TcpServer{
-		"",
-		p.Port,
-		common.Server{
+		Hostname: "",
+		Port:     p.Port,
+		Server: common.Server{
 			Sm:          sync.Map{},
 			Hooks:       common.Hooks{},
 			RateLimiter: nil,
 		},
-		options,
-		make(chan int, 1),
-		nil,
+		Options:   options,
+		Event:     make(chan int, 1),
+		Discovery: nil,
+		Network:   "tcp",
+		conns:     make(map[net.Conn]int),
 	}
 }
 
 /*
  * Start starts the TCP server
+ * @return error - ErrServerClosed after Shutdown, otherwise the error which stopped the server
  */
-func (s *TcpServer) Start() {
-	// Register services
-	if s.Discovery != nil {
+func (s *TcpServer) Start() error {
+	// Start the server
+	listener, url, err := s.listen()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		cancel()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.cancel = cancel
+	s.mu.Unlock()
+	// Register services, unix sockets and stdio cannot be reached from other hosts
+	if s.Discovery != nil && s.network() == "tcp" {
 		register := func(key, value interface{}) bool {
 			go s.DiscoveryRegister(key, value)
 			return true
 		}
 		s.Server.Sm.Range(register)
 	}
-	// Start the server
-	var addr = fmt.Sprintf("0.0.0.0:%d", s.Port)
-	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
-	if err != nil {
-		log.Panic(err.Error())
-	}
-	listener, _ := net.ListenTCP("tcp", tcpAddr)
-	log.Printf("Listening tcp://0.0.0.0:%d", s.Port)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	log.Printf("Listening %s", url)
 	// Notify successful start: send 0 to the Event channel after 1 second to indicate the service is ready
 	go func() {
 		time.Sleep(time.Second)
@@ -105,14 +133,185 @@ func (s *TcpServer) Start() {
 		}
 	}()
 	for {
-		conn, err := listener.AcceptTCP()
+		conn, err := listener.Accept()
 		if err != nil {
-			log.Panic(err.Error())
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			cancel()
+			return err
+		}
+		if !s.trackConn(conn) {
+			conn.Close()
+			continue
 		}
 		go s.handleFunc(ctx, conn)
 	}
 }
 
+/*
+ * listen listens on the network of the server
+ * @return net.Listener - The listener
+ * @return string - The URL listened on
+ * @return error - The error if the server cannot listen
+ */
+func (s *TcpServer) listen() (net.Listener, string, error) {
+	switch s.network() {
+	case "unix":
+		listener, err := listenUnix(s.Path)
+		return listener, "unix://" + s.Path, err
+	case "stdio":
+		return newStdioListener(s.pipe), "stdio://", nil
+	}
+	tcpAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("0.0.0.0:%d", s.Port))
+	if err != nil {
+		return nil, "", err
+	}
+	listener, err := net.ListenTCP("tcp", tcpAddr)
+	return listener, fmt.Sprintf("tcp://0.0.0.0:%d", s.Port), err
+}
+
+/*
+ * network returns the network the server listens on
+ * @return string - tcp, unix or stdio
+ */
+func (s *TcpServer) network() string {
+	if s.Network == "" {
+		return "tcp"
+	}
+	return s.Network
+}
+
+/*
+ * Shutdown gracefully stops the TCP server
+ * @param ctx - The context bounding the wait for in-flight requests
+ * @return error - An error if in-flight requests did not finish in time or deregistration failed
+ */
+func (s *TcpServer) Shutdown(ctx context.Context) error {
+	var err error
+	if s.Discovery != nil && s.network() == "tcp" {
+		err = deregister(s.Discovery, &s.Server.Sm, "tcp", s.Hostname, s.Port)
+	}
+	s.mu.Lock()
+	s.closed = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.mu.Unlock()
+	ticker := time.NewTicker(SHUTDOWN_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		if s.closeIdleConns() {
+			s.cancelConns()
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			s.closeConns()
+			s.cancelConns()
+			return errors.Join(err, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+ * trackConn starts tracking a connection accepted by the server
+ * @param conn - The TCP connection
+ * @return bool - False if the server is shutting down
+ */
+func (s *TcpServer) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = 0
+	return true
+}
+
+/*
+ * untrackConn stops tracking a connection
+ * @param conn - The TCP connection
+ */
+func (s *TcpServer) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+/*
+ * acquireConn marks a request as in-flight on the connection
+ * @param conn - The TCP connection
+ * @return bool - False if the server is shutting down and the request must not be processed
+ */
+func (s *TcpServer) acquireConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conns[conn]; !ok || s.closed {
+		return false
+	}
+	s.conns[conn]++
+	return true
+}
+
+/*
+ * releaseConn marks an in-flight request on the connection as done
+ * @param conn - The TCP connection
+ * @return bool - True if the connection should be closed because the server is shutting down
+ */
+func (s *TcpServer) releaseConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conns[conn]; !ok {
+		return true
+	}
+	s.conns[conn]--
+	return s.closed && s.conns[conn] == 0
+}
+
+/*
+ * closeIdleConns closes the connections without in-flight requests
+ * @return bool - True if no connection is left
+ */
+func (s *TcpServer) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, active := range s.conns {
+		if active == 0 {
+			conn.Close()
+			delete(s.conns, conn)
+		}
+	}
+	return len(s.conns) == 0
+}
+
+/*
+ * closeConns closes all connections
+ */
+func (s *TcpServer) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+/*
+ * cancelConns cancels the context of all connections
+ */
+func (s *TcpServer) cancelConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
 /*
  * DiscoveryRegister registers a service to the discovery service
  * @param key - The service key
@@ -125,7 +324,12 @@ func (s *TcpServer) DiscoveryRegister(key, value interface{}) bool {
 		return true
 	}
 	time.Sleep(REGISTRY_RETRY_INTERVAL * time.Millisecond)
-	s.DiscoveryRegister(key, value)
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if !closed {
+		s.DiscoveryRegister(key, value)
+	}
 	return false
 }
 
@@ -137,6 +341,27 @@ func (s *TcpServer) Register(m any) {
 	s.Server.Register(m)
 }
 
+/*
+ * RegisterName registers a service under a name
+ * @param name - The service name, empty for flat method names
+ * @param m - The service to register
+ * @param opts - The aliases and excluded methods
+ * @return error - The error if a name is already registered
+ */
+func (s *TcpServer) RegisterName(name string, m any, opts ...RegisterOption) error {
+	return s.Server.RegisterName(name, m, opts...)
+}
+
+/*
+ * RegisterFunc registers a function as a method
+ * @param name - The method name
+ * @param fn - The function to register
+ * @return error - The error if the function does not conform or the name is already registered
+ */
+func (s *TcpServer) RegisterFunc(name string, fn any) error {
+	return s.Server.RegisterFunc(name, fn)
+}
+
 /*
  * SetOptions sets the TCP server options
  * @param tcpOptions - The TCP server options
@@ -187,6 +412,64 @@ func (s *TcpServer) SetAfterFunc(afterFunc func(id any, method string, result an
 	s.Server.Hooks.AfterFunc = afterFunc
 }
 
+/*
+ * SetBeforeContextFunc sets the context-aware before function
+ * @param beforeFunc - The before function
+ */
+func (s *TcpServer) SetBeforeContextFunc(beforeFunc func(ctx context.Context, id any, method string, params any) error) {
+	s.Server.Hooks.BeforeContextFunc = beforeFunc
+}
+
+/*
+ * SetAfterContextFunc sets the context-aware after function
+ * @param afterFunc - The after function
+ */
+func (s *TcpServer) SetAfterContextFunc(afterFunc func(ctx context.Context, id any, method string, result any) error) {
+	s.Server.Hooks.AfterContextFunc = afterFunc
+}
+
+/*
+ * SetLenient sets whether to accept legacy requests without or with another JSON-RPC version,
+ * a legacy request with a null id is a notification
+ * @param lenient - Whether to accept legacy requests
+ */
+func (s *TcpServer) SetLenient(lenient bool) {
+	s.Server.Lenient = lenient
+}
+
+/*
+ * SetBindOptions sets the options binding request params to the params of the service methods
+ * @param options - The binding options
+ */
+func (s *TcpServer) SetBindOptions(options BindOptions) {
+	s.Server.BindOptions = options
+}
+
+/*
+ * SetBatchParallelism sets the maximum number of batch elements processed at once
+ * @param n - The maximum number of batch elements processed at once, one after the other when 1 or less
+ */
+func (s *TcpServer) SetBatchParallelism(n int) {
+	s.Server.BatchParallelism = n
+}
+
+/*
+ * SetPanicHandler sets the function called when a service method, a hook or a middleware panics,
+ * the request still gets an internal error response
+ * @param panicHandler - The panic handler, receiving the request context, the panic value and the stack
+ */
+func (s *TcpServer) SetPanicHandler(panicHandler func(ctx context.Context, p any, stack []byte)) {
+	s.Server.PanicHandler = panicHandler
+}
+
+/*
+ * Use appends middleware to the invocation chain
+ * @param mw - The middleware, the first one added is the outermost
+ */
+func (s *TcpServer) Use(mw ...Middleware) {
+	s.Server.Use(mw...)
+}
+
 /*
  * GetEvent returns the event channel
  * @return <-chan int - The event channel
@@ -201,6 +484,7 @@ func (s *TcpServer) GetEvent() <-chan int {
  * @param conn - The TCP connection
  */
 func (s *TcpServer) handleFunc(ctx context.Context, conn net.Conn) {
+	defer s.untrackConn(conn)
 	defer conn.Close()
 	select {
 	case <-ctx.Done():
@@ -208,30 +492,78 @@ func (s *TcpServer) handleFunc(ctx context.Context, conn net.Conn) {
 	default:
 		//	do nothing
 	}
-	eofb := []byte(s.Options.PackageEof)
-	eofl := len(eofb)
+	// The context is canceled when the connection goes away.
+	ctx, cancel := context.WithCancel(common.WithPeer(ctx, &common.Peer{Transport: s.network(), RemoteAddr: conn.RemoteAddr().String()}))
+	defer cancel()
+	framer := s.Options.Framer
+	if framer == nil {
+		framer = NewDelimiterFramer(s.Options.PackageEof)
+	}
+	// Responses and subscription notifications share the connection.
+	var writeMu sync.Mutex
+	write := func(b []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, err := conn.Write(framer.Frame(b))
+		return err
+	}
+	subscriptions := common.NewSubscriptions(write)
+	defer subscriptions.Close()
+	// Requests are processed concurrently, up to MaxConcurrency at once, and answered as they complete;
+	// the client matches the responses by id.
+	var active sync.WaitGroup
+	defer active.Wait()
+	// Cancel the requests in flight once the connection goes away, before waiting for them.
+	defer cancel()
+	// A full connection is not read until one of its requests completes.
+	slots := concurrencySlots(s.Options.MaxConcurrency)
+	reader := bufio.NewReader(conn)
 	for {
-		var (
-			data []byte
-		)
-		l := 0
-		for {
-			var buf = make([]byte, s.Options.PackageMaxLength)
-			n, err := conn.Read(buf)
-			if err != nil {
-				if n == 0 {
-					return
-				}
-				common.Debug(err.Error())
-			}
-			l += n
-			data = append(data, buf[:n]...)
-			if bytes.Equal(data[l-eofl:], eofb) {
-				break
+		// Count the request as in-flight once its first byte arrives, before the frame is consumed,
+		// so that Shutdown never closes the connection under a request it has read.
+		if _, err := reader.Peek(1); err != nil {
+			return
+		}
+		if !s.acquireConn(conn) {
+			return
+		}
+		data, err := framer.ReadFrame(reader, s.Options.PackageMaxLength)
+		if errors.Is(err, common.ErrFrameTooLarge) || errors.Is(err, common.ErrMalformedFrame) {
+			// The next package cannot be found, answer with a parse error and close the connection.
+			res, _ := json.Marshal(common.EE(nil, common.JsonRpc, common.Error{Code: common.ParseError, Message: common.CodeMap[common.ParseError], Data: err.Error()}))
+			write(res)
+			s.releaseConn(conn)
+			return
+		}
+		if err != nil {
+			s.releaseConn(conn)
+			return
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				s.releaseConn(conn)
+				return
 			}
 		}
-		res := s.Server.Handler(data[:l-eofl])
-		res = append(res, eofb...)
-		conn.Write(res)
+		active.Add(1)
+		go func() {
+			defer active.Done()
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			reqCtx, activate := subscriptions.Context(ctx)
+			res := s.Server.HandlerContext(reqCtx, data)
+			// Notifications get no response.
+			if len(res) > 0 {
+				write(res)
+			}
+			activate()
+			if s.releaseConn(conn) {
+				// The server is shutting down and the connection became idle, stop reading.
+				conn.Close()
+			}
+		}()
 	}
 }
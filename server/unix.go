@@ -0,0 +1,50 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+)
+
+/*
+ * Unix represents the unix domain socket protocol implementation, served by a TcpServer
+ * @property Path - The socket path, an abstract socket (Linux only) when starting with @
+ */
+type Unix struct {
+	Path string
+}
+
+/*
+ * NewServer creates a new TCP server listening on a unix domain socket
+ * @return Server - The new TCP server
+ */
+func (p *Unix) NewServer() Server {
+	s := (&Tcp{}).NewServer().(*TcpServer)
+	s.Network = "unix"
+	s.Path = p.Path
+	return s
+}
+
+/*
+ * listenUnix listens on a unix domain socket, removing the socket file left by a server that is gone
+ * @param path - The socket path, an abstract socket when starting with @
+ * @return net.Listener - The listener, removing the socket file when closed
+ * @return error - The error if the socket is in use or cannot be created
+ */
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("jsonrpc4go: unix socket path is empty")
+	}
+	if !strings.HasPrefix(path, "@") {
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			// Only a socket nobody answers on is stale.
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+			} else {
+				os.Remove(path)
+			}
+		}
+	}
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+	"golang.org/x/net/websocket"
+)
+
+/*
+ * WebSocket represents the WebSocket protocol implementation, an HTTP server accepting only WebSocket connections
+ * @property Port - The port to listen on
+ * @property Path - The path of the WebSocket endpoint, every path when empty
+ * @property Secure - Whether to use WSS, the certificate is set with HttpOptions
+ */
+type WebSocket struct {
+	Port   int
+	Path   string
+	Secure bool
+}
+
+/*
+ * NewServer creates a new WebSocket server
+ * @return Server - The new WebSocket server
+ */
+func (p *WebSocket) NewServer() Server {
+	s := (&Http{Port: p.Port, Secure: p.Secure}).NewServer().(*HttpServer)
+	s.Path = p.Path
+	s.webSocket = true
+	return s
+}
+
+/*
+ * isWebSocket reports whether a request asks to upgrade to WebSocket
+ * @param r - The request
+ * @return bool - True for a WebSocket handshake
+ */
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+/*
+ * ErrOriginNotAllowed rejects a WebSocket handshake from a page of another origin with 403 Forbidden.
+ */
+var ErrOriginNotAllowed = errors.New("jsonrpc4go: origin not allowed")
+
+/*
+ * serveWebSocket upgrades a request to a WebSocket connection and serves it
+ * @param w - The response writer
+ * @param r - The request
+ */
+func (s *HttpServer) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	websocket.Server{Handshake: s.checkOrigin, Handler: s.handleWebSocket}.ServeHTTP(w, r)
+}
+
+/*
+ * checkOrigin keeps pages of other origins from opening WebSocket connections (cross-site WebSocket hijacking)
+ * @param config - The WebSocket configuration
+ * @param r - The handshake request
+ * @return error - ErrOriginNotAllowed if the origin is neither the server's own nor allowed by the options
+ */
+func (s *HttpServer) checkOrigin(config *websocket.Config, r *http.Request) error {
+	if s.Options.CheckOrigin != nil {
+		if s.Options.CheckOrigin(r) {
+			return nil
+		}
+		return ErrOriginNotAllowed
+	}
+	origin := r.Header.Get("Origin")
+	// Browsers always send the origin, other clients may leave it out.
+	if origin == "" {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	if slices.ContainsFunc(s.Options.AllowedOrigins, func(allowed string) bool {
+		return allowed == "*" || strings.EqualFold(allowed, origin)
+	}) {
+		return nil
+	}
+	return ErrOriginNotAllowed
+}
+
+/*
+ * handleWebSocket serves a WebSocket connection, each message is a request or a batch answered in its own message.
+ * The requests of a connection are processed concurrently, up to MaxConcurrency at once, the client matches the responses by id.
+ * @param conn - The WebSocket connection
+ */
+func (s *HttpServer) handleWebSocket(conn *websocket.Conn) {
+	if !s.trackWebSocket(conn) {
+		conn.Close()
+		return
+	}
+	defer s.untrackWebSocket(conn)
+	defer conn.Close()
+	r := conn.Request()
+	transport := "ws"
+	if s.Secure {
+		transport = "wss"
+	}
+	// The context is canceled when the connection goes away.
+	ctx, cancel := context.WithCancel(common.WithPeer(r.Context(), &common.Peer{Transport: transport, RemoteAddr: r.RemoteAddr, Header: r.Header}))
+	defer cancel()
+	// Responses and subscription notifications share the connection.
+	var writeMu sync.Mutex
+	write := func(b []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return websocket.Message.Send(conn, string(b))
+	}
+	subscriptions := common.NewSubscriptions(write)
+	defer subscriptions.Close()
+	// The requests in flight finish before the subscriptions end and the connection is closed.
+	var active sync.WaitGroup
+	defer active.Wait()
+	// Cancel the requests in flight once the connection goes away, before waiting for them.
+	defer cancel()
+	// A full connection is not read until one of its requests completes.
+	slots := concurrencySlots(s.Options.MaxConcurrency)
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			return
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if !s.acquireWebSocket() {
+			return
+		}
+		active.Add(1)
+		go func() {
+			defer active.Done()
+			defer s.wsActive.Done()
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			reqCtx, activate := subscriptions.Context(ctx)
+			defer activate()
+			res := s.Server.HandlerContext(reqCtx, data)
+			// Notifications get no response.
+			if len(res) == 0 {
+				return
+			}
+			if err := write(res); err != nil {
+				common.Debug(err.Error())
+			}
+		}()
+	}
+}
+
+/*
+ * trackWebSocket starts tracking a WebSocket connection
+ * @param conn - The WebSocket connection
+ * @return bool - False if the server is shutting down
+ */
+func (s *HttpServer) trackWebSocket(conn *websocket.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.wsConns == nil {
+		s.wsConns = make(map[*websocket.Conn]struct{})
+	}
+	s.wsConns[conn] = struct{}{}
+	return true
+}
+
+/*
+ * untrackWebSocket stops tracking a WebSocket connection
+ * @param conn - The WebSocket connection
+ */
+func (s *HttpServer) untrackWebSocket(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.wsConns, conn)
+}
+
+/*
+ * acquireWebSocket marks a WebSocket request as in-flight
+ * @return bool - False if the server is shutting down and the request must not be processed
+ */
+func (s *HttpServer) acquireWebSocket() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.wsActive.Add(1)
+	return true
+}
+
+/*
+ * shutdownWebSockets waits for the in-flight WebSocket requests and closes the WebSocket connections
+ * @param ctx - The context bounding the wait for in-flight requests
+ * @return error - The context error if in-flight requests did not finish in time
+ */
+func (s *HttpServer) shutdownWebSockets(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wsActive.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.wsConns {
+		conn.Close()
+		delete(s.wsConns, conn)
+	}
+	return err
+}
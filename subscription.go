@@ -0,0 +1,133 @@
+package jsonrpc4go
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/sunquakes/jsonrpc4go/client"
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/**
+ * @Description: Notifier creating the subscriptions of a request, see NotifierFromContext
+ */
+type Notifier = common.Notifier
+
+/**
+ * @Description: Get the Notifier of the request, only tcp, ws, wss, unix and stdio servers carry one
+ * @Param ctx: Context passed to a service method
+ * @Return *Notifier: Notifier of the request
+ * @Return bool: Whether the transport supports notifications
+ */
+func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
+	return common.NotifierFromContext(ctx)
+}
+
+/**
+ * @Description: Subscription delivering typed notifications
+ * @Field ID: Subscription id returned by the server
+ * @Field C: Channel receiving the notifications, closed when the subscription ends
+ * @Field sub: Untyped subscription
+ * @Field stop: Channel closed by Unsubscribe
+ * @Field once: Once closing stop
+ * @Field done: Channel closed when C is closed
+ * @Field err: Error decoding a notification
+ */
+type Subscription[T any] struct {
+	ID   string
+	C    <-chan T
+	sub  *client.Subscription
+	stop chan struct{}
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+/**
+ * @Description: Call a method returning a subscription id and receive its notifications decoded as T
+ * @Param ctx: Context controlling the subscribing call
+ * @Param c: Client, a tcp, ws, wss, unix or stdio client
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Return *Subscription[T]: Subscription
+ * @Return error: client.ErrSubscriptionsUnsupported for other clients, an *Error for error responses
+ */
+func Subscribe[P, T any](ctx context.Context, c client.Client, method string, params P) (*Subscription[T], error) {
+	subscriber, ok := c.(client.Subscriber)
+	if !ok {
+		return nil, client.ErrSubscriptionsUnsupported
+	}
+	sub, err := subscriber.Subscribe(ctx, method, params)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan T)
+	s := &Subscription[T]{ID: sub.ID, C: ch, sub: sub, stop: make(chan struct{}), done: make(chan struct{})}
+	go s.decode(ch)
+	return s, nil
+}
+
+/**
+ * @Description: Decode the notifications until the subscription ends
+ * @Receiver s: Subscription structure pointer
+ * @Param ch: Channel receiving the notifications
+ */
+func (s *Subscription[T]) decode(ch chan<- T) {
+	defer close(s.done)
+	defer close(ch)
+	for raw := range s.sub.C {
+		var v T
+		if err := json.Unmarshal(raw, &v); err != nil {
+			s.err = err
+			ctx, cancel := context.WithTimeout(context.Background(), client.UNSUBSCRIBE_TIMEOUT)
+			s.sub.Unsubscribe(ctx)
+			cancel()
+			return
+		}
+		select {
+		case ch <- v:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+/**
+ * @Description: End the subscription and tell the server to stop sending notifications
+ * @Receiver s: Subscription structure pointer
+ * @Param ctx: Context controlling the unsubscribe call
+ * @Return error: Error message
+ */
+func (s *Subscription[T]) Unsubscribe(ctx context.Context) error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	return s.sub.Unsubscribe(ctx)
+}
+
+/**
+ * @Description: Get a channel closed when the subscription ends and C is closed
+ * @Receiver s: Subscription structure pointer
+ * @Return <-chan struct{}: Channel closed when the subscription ends
+ */
+func (s *Subscription[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+/**
+ * @Description: Get the reason the subscription ended
+ * @Receiver s: Subscription structure pointer
+ * @Return error: nil while active or after Unsubscribe, the decoding error, ErrConnectionLost or ErrSubscriptionOverflow otherwise
+ */
+func (s *Subscription[T]) Err() error {
+	select {
+	case <-s.done:
+	default:
+		return nil
+	}
+	if s.err != nil {
+		return s.err
+	}
+	return s.sub.Err()
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/sunquakes/jsonrpc4go/client"
+	"github.com/sunquakes/jsonrpc4go/discovery"
+)
+
+var balancerInstances = []discovery.Instance{
+	{Host: "127.0.0.1", Port: 1, Weight: 3, Healthy: true},
+	{Host: "127.0.0.1", Port: 2, Weight: 1, Healthy: true},
+	{Host: "127.0.0.1", Port: 3, Weight: 0, Healthy: true},
+}
+
+func TestRoundRobinBalancer(t *testing.T) {
+	b := client.NewRoundRobinBalancer()
+	if _, err := b.Pick(client.PickInfo{}); err != client.ErrNoInstance {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrNoInstance, err)
+	}
+	b.Update(balancerInstances)
+	for i := 0; i < 6; i++ {
+		instance, _ := b.Pick(client.PickInfo{})
+		if instance.Port != i%3+1 {
+			t.Errorf("Port expected be %d, but %d got", i%3+1, instance.Port)
+		}
+	}
+}
+
+func TestWeightedRoundRobinBalancer(t *testing.T) {
+	b := client.NewWeightedRoundRobinBalancer()
+	b.Update(balancerInstances)
+	counts := make(map[int]int)
+	for i := 0; i < 8; i++ {
+		instance, _ := b.Pick(client.PickInfo{})
+		counts[instance.Port]++
+	}
+	expected := map[int]int{1: 6, 2: 2}
+	if counts[1] != expected[1] || counts[2] != expected[2] || counts[3] != 0 {
+		t.Errorf("Counts expected be %v, but %v got", expected, counts)
+	}
+}
+
+func TestLeastRequestBalancer(t *testing.T) {
+	b := client.NewLeastRequestBalancer()
+	b.Update(balancerInstances[:2])
+	first, _ := b.Pick(client.PickInfo{})
+	second, _ := b.Pick(client.PickInfo{})
+	if first.Port == second.Port {
+		t.Errorf("Port expected be different, but %d got twice", first.Port)
+	}
+	// The first instance finishes its call, so it has the fewest outstanding requests.
+	b.Done(first, nil)
+	for i := 0; i < 2; i++ {
+		instance, _ := b.Pick(client.PickInfo{})
+		if instance.Port != first.Port {
+			t.Errorf("Port expected be %d, but %d got", first.Port, instance.Port)
+		}
+		b.Done(instance, nil)
+	}
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	b := client.NewConsistentHashBalancer("a")
+	b.Update(balancerInstances)
+	counts := make(map[int]int)
+	for key := 0; key < 100; key++ {
+		instance, _ := b.Pick(client.PickInfo{Params: Params{A: key, B: 1}})
+		counts[instance.Port]++
+		for i := 0; i < 3; i++ {
+			// Only the key field is hashed.
+			other, _ := b.Pick(client.PickInfo{Params: Params{A: key, B: i}})
+			if other.Port != instance.Port {
+				t.Fatalf("Port expected be %d, but %d got", instance.Port, other.Port)
+			}
+		}
+	}
+	if len(counts) != 3 {
+		t.Errorf("Instances expected be %d, but %d got", 3, len(counts))
+	}
+}
+
+func TestRandomBalancer(t *testing.T) {
+	b := client.NewRandomBalancer()
+	b.Update(balancerInstances)
+	for i := 0; i < 10; i++ {
+		instance, err := b.Pick(client.PickInfo{})
+		if err != nil || instance.Port < 1 || instance.Port > 3 {
+			t.Errorf("Port expected be in [1, 3], but %d got (%v)", instance.Port, err)
+		}
+	}
+}
+
+func TestBalancerExclude(t *testing.T) {
+	balancers := []client.Balancer{
+		client.NewRoundRobinBalancer(),
+		client.NewRandomBalancer(),
+		client.NewWeightedRoundRobinBalancer(),
+		client.NewLeastRequestBalancer(),
+		client.NewConsistentHashBalancer(""),
+	}
+	info := client.PickInfo{Params: Params{A: 1}, Exclude: []string{"127.0.0.1:1", "127.0.0.1:3"}}
+	for _, b := range balancers {
+		b.Update(balancerInstances)
+		for i := 0; i < 3; i++ {
+			instance, _ := b.Pick(info)
+			if instance.Port != 2 {
+				t.Errorf("Port expected be %d, but %d got (%T)", 2, instance.Port, b)
+			}
+		}
+		// Every instance is excluded, the exclusion is ignored.
+		if _, err := b.Pick(client.PickInfo{Exclude: discovery.Addresses(balancerInstances)}); err != nil {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+type BindPage struct {
+	Page int `json:"page" default:"1"`
+	Size int `json:"size,omitempty"`
+}
+
+type BindParams struct {
+	BindPage
+	Name    string   `json:"name"`
+	Tags    []string `json:"tags,omitempty"`
+	Sort    string   `default:"id"`
+	Verbose *bool
+	Ignored string `json:"-"`
+}
+
+func TestBindStruct(t *testing.T) {
+	verbose := true
+	cases := []struct {
+		data     string
+		expected BindParams
+	}{
+		{`{"name":"a"}`, BindParams{BindPage: BindPage{Page: 1}, Name: "a", Sort: "id"}},
+		{`{"NAME":"a","page":2,"size":10,"tags":["x"],"sort":"name","verbose":true}`, BindParams{BindPage{2, 10}, "a", []string{"x"}, "name", &verbose, ""}},
+		{`[3,20,"a"]`, BindParams{BindPage: BindPage{3, 20}, Name: "a", Sort: "id"}},
+	}
+	for _, c := range cases {
+		p := BindParams{}
+		if err := common.Bind(json.RawMessage(c.data), &p, common.BindOptions{}); err != nil || !reflect.DeepEqual(p, c.expected) {
+			t.Errorf("Params expected be %+v, but %+v got (%v)", c.expected, p, err)
+		}
+	}
+	failures := map[string]string{
+		`{}`:                        `missing param "name"`,
+		`{"name":"a","other":1}`:    `unknown param "other"`,
+		`{"name":1}`:                `invalid param "name": json: cannot unmarshal number into Go value of type string`,
+		`[1,2,"a",[],"b",true,"c"]`: `params must hold at most 6 values, 7 got`,
+		`"a"`:                       `params must be an object or an array`,
+	}
+	for data, expected := range failures {
+		err := common.Bind(json.RawMessage(data), &BindParams{}, common.BindOptions{})
+		if fmt.Sprint(err) != expected {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, expected, err)
+		}
+	}
+	p := BindParams{}
+	if err := common.Bind(json.RawMessage(`{"name":"a","other":1}`), &p, common.BindOptions{AllowUnknownFields: true}); err != nil || p.Name != "a" {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+}
+
+func TestBindTypes(t *testing.T) {
+	i := 0
+	if err := common.Bind(json.RawMessage(`[5]`), &i, common.BindOptions{}); err != nil || i != 5 {
+		t.Errorf("Param expected be %d, but %d got (%v)", 5, i, err)
+	}
+	if err := common.Bind(json.RawMessage(`[1,2]`), &i, common.BindOptions{}); err == nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "params must hold 1 value, 2 got", err)
+	}
+	list := []int{}
+	if err := common.Bind(json.RawMessage(`[1,2,3]`), &list, common.BindOptions{}); err != nil || fmt.Sprint(list) != "[1 2 3]" {
+		t.Errorf("Params expected be %v, but %v got (%v)", []int{1, 2, 3}, list, err)
+	}
+	m := map[string]int{}
+	if err := common.Bind(json.RawMessage(`{"a":1}`), &m, common.BindOptions{}); err != nil || m["a"] != 1 {
+		t.Errorf("Params expected be %v, but %v got (%v)", map[string]int{"a": 1}, m, err)
+	}
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go/client"
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+const BREAKER_ADDRESS = "127.0.0.1:1"
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	var transitions []string
+	b := client.NewCircuitBreaker(client.BreakerOptions{
+		ConsecutiveFailures: 3,
+		Cooldown:            50 * time.Millisecond,
+		OnStateChange: func(address string, from client.BreakerState, to client.BreakerState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+	})
+	failure := errors.New("connection refused")
+	for i := 0; i < 3; i++ {
+		if !b.Allow(BREAKER_ADDRESS) {
+			t.Fatalf("Allow expected be true, but false got")
+		}
+		b.Report(BREAKER_ADDRESS, failure)
+	}
+	if state := b.State(BREAKER_ADDRESS); state != client.BREAKER_OPEN {
+		t.Errorf("State expected be %v, but %v got", client.BREAKER_OPEN, state)
+	}
+	if b.Allow(BREAKER_ADDRESS) || len(b.Blocked()) != 1 {
+		t.Errorf("Allow expected be false while open, but true got")
+	}
+	time.Sleep(50 * time.Millisecond)
+	// A single probe is let through when half-open.
+	if !b.Allow(BREAKER_ADDRESS) || b.Allow(BREAKER_ADDRESS) {
+		t.Errorf("Allow expected be true once when half-open")
+	}
+	b.Report(BREAKER_ADDRESS, failure)
+	time.Sleep(50 * time.Millisecond)
+	b.Allow(BREAKER_ADDRESS)
+	b.Report(BREAKER_ADDRESS, nil)
+	expected := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Transitions expected be %v, but %v got", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("Transitions expected be %v, but %v got", expected, transitions)
+			break
+		}
+	}
+}
+
+func TestBreakerFailureRatio(t *testing.T) {
+	b := client.NewCircuitBreaker(client.BreakerOptions{ConsecutiveFailures: -1, FailureRatio: 0.5, MinRequests: 4})
+	for i := 0; i < 3; i++ {
+		b.Allow(BREAKER_ADDRESS)
+		b.Report(BREAKER_ADDRESS, nil)
+		b.Allow(BREAKER_ADDRESS)
+		b.Report(BREAKER_ADDRESS, errors.New("timeout"))
+		if i == 0 && b.State(BREAKER_ADDRESS) != client.BREAKER_CLOSED {
+			t.Errorf("State expected be %v, but %v got", client.BREAKER_CLOSED, b.State(BREAKER_ADDRESS))
+		}
+	}
+	if state := b.State(BREAKER_ADDRESS); state != client.BREAKER_OPEN {
+		t.Errorf("State expected be %v, but %v got", client.BREAKER_OPEN, state)
+	}
+}
+
+func TestBreakerErrorResponse(t *testing.T) {
+	b := client.NewCircuitBreaker(client.BreakerOptions{ConsecutiveFailures: 1})
+	// The upstream answered, an error response is not a failure.
+	b.Allow(BREAKER_ADDRESS)
+	b.Report(BREAKER_ADDRESS, &common.Error{Code: common.InternalError, Message: "Internal error"})
+	b.Allow(BREAKER_ADDRESS)
+	b.Report(BREAKER_ADDRESS, client.ErrCanceled)
+	if state := b.State(BREAKER_ADDRESS); state != client.BREAKER_CLOSED {
+		t.Errorf("State expected be %v, but %v got", client.BREAKER_CLOSED, state)
+	}
+}
+
+func TestBreakerPoolSaturation(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	address := l.Addr().String()
+	b := client.NewCircuitBreaker(client.BreakerOptions{ConsecutiveFailures: 1})
+	p := client.NewPool("PortRpc", address, nil, client.PoolOptions{MinIdle: 1, MaxActive: 1})
+	p.SetBreaker(b)
+	defer p.Close()
+	conn, err := p.BorrowContext(context.Background(), client.PickInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Waiting for a connection of the saturated pool times out locally, it is not a failure of the upstream.
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		if _, err := p.BorrowContext(ctx, client.PickInfo{}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, context.DeadlineExceeded, err)
+		}
+		cancel()
+	}
+	if state := b.State(address); state != client.BREAKER_CLOSED {
+		t.Errorf("State expected be %v, but %v got", client.BREAKER_CLOSED, state)
+	}
+	p.Release(conn, nil)
+}
@@ -5,8 +5,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/sunquakes/jsonrpc4go/discovery"
 	"github.com/sunquakes/jsonrpc4go/discovery/consul"
 )
 
@@ -39,6 +42,45 @@ func TestConsulGet(t *testing.T) {
 	}
 }
 
+func TestConsulGetInstances(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The instances are read from the endpoint watched by Watch.
+		if r.URL.Path != "/v1/health/service/java_tcp" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, `[{"Service":{"ID":"java_tcp-1:3232","Service":"java_tcp","Tags":["primary"],"Meta":{"protocol":"tcp","zone":"zone-a","version":"1.0.0"},"Port":3232,"Address":"10.222.1.164","Weights":{"Passing":3,"Warning":1}},"Checks":[{"CheckID":"serfHealth","Status":"passing"},{"CheckID":"service:java_tcp-1:3232","Status":"passing"}]},{"Service":{"ID":"java_tcp-2:3232","Service":"java_tcp","Tags":[],"Meta":{},"Port":3232,"Address":"10.222.1.165","Weights":{"Passing":1,"Warning":1}},"Checks":[{"CheckID":"serfHealth","Status":"passing"},{"CheckID":"service:java_tcp-2:3232","Status":"critical"}]}]`)
+	}))
+	defer ts.Close()
+	r, err := consul.NewConsul(ts.URL)
+	if err != nil {
+		t.Error(err)
+	}
+	instances, err := r.GetInstances("java_tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := discovery.Instance{
+		Id:       "java_tcp-1:3232",
+		Name:     "java_tcp",
+		Protocol: "tcp",
+		Host:     "10.222.1.164",
+		Port:     3232,
+		Weight:   3,
+		Healthy:  true,
+		Zone:     "zone-a",
+		Version:  "1.0.0",
+		Tags:     []string{"primary"},
+		Meta:     map[string]string{"protocol": "tcp", "zone": "zone-a", "version": "1.0.0"},
+	}
+	if len(instances) != 2 || fmt.Sprint(instances[0]) != fmt.Sprint(expected) {
+		t.Errorf("Instance expected be %+v, but %+v got", expected, instances)
+	}
+	if instances[1].Healthy {
+		t.Errorf("Instance %s expected be unhealthy", instances[1].Id)
+	}
+}
+
 func TestConsulRegister(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, ``)
@@ -54,6 +96,30 @@ func TestConsulRegister(t *testing.T) {
 	}
 }
 
+func TestConsulDeregister(t *testing.T) {
+	var method, path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		fmt.Fprintln(w, ``)
+	}))
+	defer ts.Close()
+	r, err := consul.NewConsul(ts.URL)
+	if err != nil {
+		t.Error(err)
+	}
+	err = r.Deregister("java_tcp", "tcp", "192.168.1.15", 3232)
+	if err != nil {
+		t.Error(err)
+	}
+	if method != http.MethodPut {
+		t.Errorf("Method expected be %v, but %v got", http.MethodPut, method)
+	}
+	expected := "/v1/agent/service/deregister/java_tcp:3232"
+	if path != expected {
+		t.Errorf("Path expected be %v, but %v got", expected, path)
+	}
+}
+
 func TestConsulCheck(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, ``)
@@ -82,3 +148,85 @@ func TestConsulCheck(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestConsulWatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/java_tcp" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.URL.Query().Get("index") {
+		case "":
+			w.Header().Set("X-Consul-Index", "1")
+			fmt.Fprintln(w, `[{"Node":{"Address":"10.0.0.1"},"Service":{"ID":"java_tcp:3232","Service":"java_tcp","Address":"","Port":3232}}]`)
+		case "1":
+			w.Header().Set("X-Consul-Index", "2")
+			fmt.Fprintln(w, `[{"Node":{"Address":"10.0.0.1"},"Service":{"ID":"java_tcp:3232","Service":"java_tcp","Address":"","Port":3232}},{"Node":{"Address":"10.0.0.2"},"Service":{"ID":"java_tcp:3233","Service":"java_tcp","Address":"10.0.0.3","Port":3233}}]`)
+		default:
+			// Block like Consul does until the watch is canceled.
+			<-r.Context().Done()
+		}
+	}))
+	defer ts.Close()
+	r, err := consul.NewConsul(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, cancel := r.(discovery.Watcher).Watch("java_tcp")
+	expected := [][]string{{"10.0.0.1:3232"}, {"10.0.0.1:3232", "10.0.0.3:3233"}}
+	for _, e := range expected {
+		addresses := discovery.Addresses(<-ch)
+		if fmt.Sprint(addresses) != fmt.Sprint(e) {
+			t.Errorf("Addresses expected be %v, but %v got", e, addresses)
+		}
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Error("Channel expected be closed after cancel")
+	}
+}
+
+func TestConsulWatchIndexReset(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		indexes []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		indexes = append(indexes, r.URL.Query().Get("index"))
+		n := len(indexes)
+		lock.Unlock()
+		switch n {
+		case 1:
+			w.Header().Set("X-Consul-Index", "5")
+			fmt.Fprintln(w, `[{"Node":{"Address":"10.0.0.1"},"Service":{"ID":"java_tcp:3232","Service":"java_tcp","Port":3232}}]`)
+		case 2:
+			// The index goes backwards.
+			w.Header().Set("X-Consul-Index", "3")
+			fmt.Fprintln(w, `[{"Node":{"Address":"10.0.0.2"},"Service":{"ID":"java_tcp:3232","Service":"java_tcp","Port":3232}}]`)
+		case 3:
+			// The index is missing.
+			fmt.Fprintln(w, `[{"Node":{"Address":"10.0.0.1"},"Service":{"ID":"java_tcp:3232","Service":"java_tcp","Port":3232}}]`)
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer ts.Close()
+	r, err := consul.NewConsul(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, cancel := r.(discovery.Watcher).Watch("java_tcp")
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		<-ch
+	}
+	// The next queries block instead of spinning.
+	time.Sleep(200 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	expected := []string{"", "5", "1", "1"}
+	if fmt.Sprint(indexes) != fmt.Sprint(expected) {
+		t.Errorf("Indexes expected be %v, but %v got", expected, indexes)
+	}
+}
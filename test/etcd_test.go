@@ -2,11 +2,17 @@ package test
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
+	"github.com/sunquakes/jsonrpc4go/discovery"
 	"github.com/sunquakes/jsonrpc4go/discovery/etcd"
 	"github.com/sunquakes/jsonrpc4go/discovery/etcd/etcdserverpb"
 	"google.golang.org/grpc"
@@ -67,14 +73,24 @@ func _MockMockKVServiceService_Range_Handler(srv interface{}, ctx context.Contex
 
 type LeaseInterface interface {
 	LeaseGrant(context.Context, *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error)
+	LeaseRevoke(context.Context, *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error)
 }
 
-type MockLeaseService struct{}
+type MockLeaseService struct {
+	Revoked chan int64
+}
 
 func (s *MockLeaseService) LeaseGrant(ctx context.Context, data *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error) {
 	return &etcdserverpb.LeaseGrantResponse{}, nil
 }
 
+func (s *MockLeaseService) LeaseRevoke(ctx context.Context, data *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error) {
+	if s.Revoked != nil {
+		s.Revoked <- data.ID
+	}
+	return &etcdserverpb.LeaseRevokeResponse{}, nil
+}
+
 var MockLeaseServiceDesc = grpc.ServiceDesc{
 	ServiceName: "etcdserverpb.Lease", // Full service name
 	HandlerType: (*LeaseInterface)(nil),
@@ -83,6 +99,10 @@ var MockLeaseServiceDesc = grpc.ServiceDesc{
 			MethodName: "LeaseGrant",
 			Handler:    _MockMockLeaseServiceService_LeaseGrant_Handler,
 		},
+		{
+			MethodName: "LeaseRevoke",
+			Handler:    _MockMockLeaseServiceService_LeaseRevoke_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "lease.proto",
@@ -92,6 +112,14 @@ func _MockMockLeaseServiceService_LeaseGrant_Handler(srv interface{}, ctx contex
 	return &etcdserverpb.LeaseGrantRequest{}, nil
 }
 
+func _MockMockLeaseServiceService_LeaseRevoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(etcdserverpb.LeaseRevokeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	return srv.(LeaseInterface).LeaseRevoke(ctx, in)
+}
+
 func TestEtcdRegister(t *testing.T) {
 	bufListener := bufconn.Listen(1024 * 1024)
 
@@ -188,3 +216,337 @@ func TestEtcdGet(t *testing.T) {
 		t.Errorf("URL expected be %s, but %s got", expected, servers)
 	}
 }
+
+func TestEtcdGetInstances(t *testing.T) {
+	bufListener := bufconn.Listen(1024 * 1024)
+
+	grpcServer := grpc.NewServer()
+	grpcServer.RegisterService(&MockKVServiceDesc, &MockKVService{})
+	go func() {
+		if err := grpcServer.Serve(bufListener); err != nil {
+			log.Fatalf("gRPC server error: %v", err)
+		}
+	}()
+	defer grpcServer.Stop()
+
+	conn, err := bufListener.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial bufconn: %v", err)
+	}
+	defer conn.Close()
+
+	clientConn, err := NewEtcdClient("192.168.1.15:3232", conn)
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client connection: %v", err)
+	}
+	defer clientConn.Close()
+
+	URL, err := url.Parse("bufconn://" + bufListener.Addr().String())
+	if err != nil {
+		t.Error(err)
+	}
+
+	r := &etcd.Etcd{URL: URL, Conn: clientConn, Heartbeat: make(chan bool)}
+	instances, err := r.GetInstances("java_tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := discovery.Instance{Id: "1692416183", Name: "java_tcp", Host: "192.168.1.15", Port: 3232, Weight: 1, Healthy: true}
+	if len(instances) != 1 || fmt.Sprint(instances[0]) != fmt.Sprint(expected) {
+		t.Errorf("Instances expected be %+v, but %+v got", []discovery.Instance{expected}, instances)
+	}
+}
+
+func TestEtcdDeregister(t *testing.T) {
+	bufListener := bufconn.Listen(1024 * 1024)
+
+	revoked := make(chan int64, 1)
+	grpcServer := grpc.NewServer()
+	grpcServer.RegisterService(&MockKVServiceDesc, &MockKVService{})
+	grpcServer.RegisterService(&MockLeaseServiceDesc, &MockLeaseService{Revoked: revoked})
+	go func() {
+		if err := grpcServer.Serve(bufListener); err != nil {
+			log.Fatalf("gRPC server error: %v", err)
+		}
+	}()
+	defer grpcServer.Stop()
+
+	conn, err := bufListener.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial bufconn: %v", err)
+	}
+	defer conn.Close()
+
+	clientConn, err := NewEtcdClient("192.168.1.15:3232", conn)
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client connection: %v", err)
+	}
+	defer clientConn.Close()
+
+	URL, err := url.Parse("bufconn://" + bufListener.Addr().String())
+	if err != nil {
+		t.Error(err)
+	}
+
+	r := &etcd.Etcd{URL: URL, Conn: clientConn, Heartbeat: make(chan bool)}
+	// Deregistering an unknown service is a no-op.
+	err = r.Deregister("java_tcp", "tcp", "192.168.1.15", 3232)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(revoked) != 0 {
+		t.Errorf("Revoked expected be %v, but %v got", 0, len(revoked))
+	}
+	err = r.Register("java_tcp", "tcp", "192.168.1.15", 3232)
+	if err != nil {
+		t.Error(err)
+	}
+	err = r.Deregister("java_tcp", "tcp", "192.168.1.15", 3232)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(revoked) != 1 {
+		t.Errorf("Revoked expected be %v, but %v got", 1, len(revoked))
+	}
+}
+
+// MockStore keeps the keys like etcd, a key is deleted with its lease.
+type MockStore struct {
+	mu     sync.Mutex
+	kvs    map[string]*etcdserverpb.KeyValue
+	leases int64
+}
+
+func (s *MockStore) Put(ctx context.Context, data *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kvs[data.Key] = &etcdserverpb.KeyValue{Key: data.Key, Value: data.Value, Lease: data.Lease}
+	return &etcdserverpb.PutResponse{}, nil
+}
+
+func (s *MockStore) Range(ctx context.Context, data *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &etcdserverpb.RangeResponse{}
+	for key, kv := range s.kvs {
+		if key == data.Key || data.RangeEnd != "" && key >= data.Key && key < data.RangeEnd {
+			resp.Kvs = append(resp.Kvs, kv)
+		}
+	}
+	sort.Slice(resp.Kvs, func(i, j int) bool {
+		return resp.Kvs[i].Key < resp.Kvs[j].Key
+	})
+	return resp, nil
+}
+
+func (s *MockStore) LeaseGrant(ctx context.Context, data *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leases++
+	return &etcdserverpb.LeaseGrantResponse{ID: s.leases}, nil
+}
+
+func (s *MockStore) LeaseRevoke(ctx context.Context, data *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, kv := range s.kvs {
+		if kv.Lease == data.ID {
+			delete(s.kvs, key)
+		}
+	}
+	return &etcdserverpb.LeaseRevokeResponse{}, nil
+}
+
+func MockStoreHandler[T any](f func(*MockStore, context.Context, *T) (any, error)) func(any, context.Context, func(any) error, grpc.UnaryServerInterceptor) (any, error) {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := new(T)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		return f(srv.(*MockStore), ctx, in)
+	}
+}
+
+func TestEtcdInstanceKeys(t *testing.T) {
+	bufListener := bufconn.Listen(1024 * 1024)
+
+	store := &MockStore{kvs: make(map[string]*etcdserverpb.KeyValue)}
+	kvServiceDesc := MockKVServiceDesc
+	kvServiceDesc.HandlerType = (*any)(nil)
+	kvServiceDesc.Methods = []grpc.MethodDesc{
+		{MethodName: "Put", Handler: MockStoreHandler(func(s *MockStore, ctx context.Context, in *etcdserverpb.PutRequest) (any, error) {
+			return s.Put(ctx, in)
+		})},
+		{MethodName: "Range", Handler: MockStoreHandler(func(s *MockStore, ctx context.Context, in *etcdserverpb.RangeRequest) (any, error) {
+			return s.Range(ctx, in)
+		})},
+	}
+	leaseServiceDesc := MockLeaseServiceDesc
+	leaseServiceDesc.HandlerType = (*any)(nil)
+	leaseServiceDesc.Methods = []grpc.MethodDesc{
+		{MethodName: "LeaseGrant", Handler: MockStoreHandler(func(s *MockStore, ctx context.Context, in *etcdserverpb.LeaseGrantRequest) (any, error) {
+			return s.LeaseGrant(ctx, in)
+		})},
+		{MethodName: "LeaseRevoke", Handler: MockStoreHandler(func(s *MockStore, ctx context.Context, in *etcdserverpb.LeaseRevokeRequest) (any, error) {
+			return s.LeaseRevoke(ctx, in)
+		})},
+	}
+	grpcServer := grpc.NewServer()
+	grpcServer.RegisterService(&kvServiceDesc, store)
+	grpcServer.RegisterService(&leaseServiceDesc, store)
+	go func() {
+		if err := grpcServer.Serve(bufListener); err != nil {
+			log.Fatalf("gRPC server error: %v", err)
+		}
+	}()
+	defer grpcServer.Stop()
+
+	conn, err := bufListener.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial bufconn: %v", err)
+	}
+	defer conn.Close()
+
+	clientConn, err := NewEtcdClient("192.168.1.15:3232", conn)
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client connection: %v", err)
+	}
+	defer clientConn.Close()
+
+	URL, err := url.Parse("bufconn://" + bufListener.Addr().String())
+	if err != nil {
+		t.Error(err)
+	}
+
+	r := &etcd.Etcd{URL: URL, Conn: clientConn, Heartbeat: make(chan bool)}
+	// Instances of the service keep their own keys, another service sharing the name prefix is not read.
+	for _, port := range []int{3232, 3233} {
+		if err := r.Register("java_tcp", "tcp", "192.168.1.15", port); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Register("java_tcp2", "tcp", "192.168.1.15", 3234); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Deregister("java_tcp", "tcp", "192.168.1.15", 3233)
+	defer r.Deregister("java_tcp2", "tcp", "192.168.1.15", 3234)
+	servers, err := r.Get("java_tcp")
+	expected := "192.168.1.15:3232,192.168.1.15:3233"
+	if err != nil || servers != expected {
+		t.Errorf("URL expected be %s, but %s got (%v)", expected, servers, err)
+	}
+	// Deregistering an instance removes its key only.
+	if err := r.Deregister("java_tcp", "tcp", "192.168.1.15", 3232); err != nil {
+		t.Error(err)
+	}
+	servers, err = r.Get("java_tcp")
+	expected = "192.168.1.15:3233"
+	if err != nil || servers != expected {
+		t.Errorf("URL expected be %s, but %s got (%v)", expected, servers, err)
+	}
+	if keys := strings.Join(etcdKeys(store), ","); keys != "java_tcp/192.168.1.15:3233,java_tcp2/192.168.1.15:3234" {
+		t.Errorf("Keys expected be %s, but %s got", "java_tcp/192.168.1.15:3233,java_tcp2/192.168.1.15:3234", keys)
+	}
+}
+
+func etcdKeys(store *MockStore) []string {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	keys := make([]string, 0, len(store.kvs))
+	for key := range store.kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type MockWatchService struct{}
+
+var MockWatchServiceDesc = grpc.ServiceDesc{
+	ServiceName: "etcdserverpb.Watch", // Full service name
+	HandlerType: (*interface{})(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _MockMockWatchServiceService_Watch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "watch.proto",
+}
+
+func _MockMockWatchServiceService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(etcdserverpb.WatchRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&etcdserverpb.WatchResponse{Created: true}); err != nil {
+		return err
+	}
+	event := &etcdserverpb.Event{Type: etcdserverpb.Event_PUT, Kv: &etcdserverpb.KeyValue{Key: req.GetCreateRequest().Key}}
+	if err := stream.SendMsg(&etcdserverpb.WatchResponse{Events: []*etcdserverpb.Event{event}}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func TestEtcdWatch(t *testing.T) {
+	bufListener := bufconn.Listen(1024 * 1024)
+
+	// The service has one instance before the watch event and two after.
+	var ranges atomic.Int32
+	kvServiceDesc := MockKVServiceDesc
+	kvServiceDesc.Methods = []grpc.MethodDesc{
+		{
+			MethodName: "Range",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				resp := &etcdserverpb.RangeResponse{}
+				resp.Kvs = append(resp.Kvs, &etcdserverpb.KeyValue{Key: "java_tcp", Value: []byte(`{"UniqueId":"1","Name":"java_tcp","Addr":"192.168.1.15:3232"}`)})
+				if ranges.Add(1) > 1 {
+					resp.Kvs = append(resp.Kvs, &etcdserverpb.KeyValue{Key: "java_tcp", Value: []byte(`{"UniqueId":"2","Name":"java_tcp","Addr":"http://192.168.1.16:3232"}`)})
+				}
+				return resp, nil
+			},
+		},
+	}
+	grpcServer := grpc.NewServer()
+	grpcServer.RegisterService(&kvServiceDesc, &MockKVService{})
+	grpcServer.RegisterService(&MockWatchServiceDesc, &MockWatchService{})
+	go func() {
+		if err := grpcServer.Serve(bufListener); err != nil {
+			log.Fatalf("gRPC server error: %v", err)
+		}
+	}()
+	defer grpcServer.Stop()
+
+	conn, err := bufListener.Dial()
+	if err != nil {
+		t.Fatalf("Failed to dial bufconn: %v", err)
+	}
+	defer conn.Close()
+
+	clientConn, err := NewEtcdClient("192.168.1.15:3232", conn)
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client connection: %v", err)
+	}
+	defer clientConn.Close()
+
+	URL, err := url.Parse("bufconn://" + bufListener.Addr().String())
+	if err != nil {
+		t.Error(err)
+	}
+
+	r := &etcd.Etcd{URL: URL, Conn: clientConn, Heartbeat: make(chan bool)}
+	ch, cancel := r.Watch("java_tcp")
+	defer cancel()
+	expected := [][]string{{"192.168.1.15:3232"}, {"192.168.1.15:3232", "192.168.1.16:3232"}}
+	for _, e := range expected {
+		addresses := discovery.Addresses(<-ch)
+		if len(addresses) != len(e) || addresses[0] != e[0] || addresses[len(e)-1] != e[len(e)-1] {
+			t.Errorf("Addresses expected be %v, but %v got", e, addresses)
+		}
+	}
+}
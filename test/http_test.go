@@ -1,16 +1,26 @@
 package test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/sunquakes/jsonrpc4go"
+	"github.com/sunquakes/jsonrpc4go/client"
 	"github.com/sunquakes/jsonrpc4go/common"
+	"github.com/sunquakes/jsonrpc4go/discovery"
 	"github.com/sunquakes/jsonrpc4go/discovery/consul"
 	"github.com/sunquakes/jsonrpc4go/discovery/nacos"
+	"github.com/sunquakes/jsonrpc4go/server"
 )
 
 const EQUAL_MESSAGE_TEMPLETE = "%d + %d expected be %d, but %d got"
@@ -33,6 +43,71 @@ func (i *IntRpc) Sub(params *Params, result *int) error {
 	return nil
 }
 
+type PanicRpc struct{}
+
+func (p *PanicRpc) Panic(params *Params, result *int) error {
+	var m map[int]int
+	m[params.A] = params.B
+	return nil
+}
+
+func (p *PanicRpc) Add(params *Params, result *int) error {
+	*result = params.A + params.B
+	return nil
+}
+
+type ErrorRpc struct{}
+
+type ErrorData struct {
+	Field string `json:"field"`
+}
+
+func (e *ErrorRpc) Div(params *Params, result *int) error {
+	if params.B == 0 {
+		return fmt.Errorf("divide %d: %w", params.A, jsonrpc4go.NewError(-32001, "Division by zero", ErrorData{"b"}))
+	}
+	if params.B < 0 {
+		return errors.New("negative divisor")
+	}
+	*result = params.A / params.B
+	return nil
+}
+
+type ContextRpc struct {
+	Canceled chan error
+}
+
+type ContextResult struct {
+	Transport   string `json:"transport"`
+	RemoteAddr  string `json:"remote_addr"`
+	ContentType string `json:"content_type"`
+	Method      string `json:"method"`
+	Id          any    `json:"id"`
+}
+
+func (c *ContextRpc) Info(ctx context.Context, params *Params, result *ContextResult) error {
+	if peer, ok := common.PeerFromContext(ctx); ok {
+		result.Transport = peer.Transport
+		result.RemoteAddr = peer.RemoteAddr
+		result.ContentType = peer.Header.Get("Content-Type")
+	}
+	if info, ok := common.RequestInfoFromContext(ctx); ok {
+		result.Method = info.Method
+		result.Id = info.Id
+	}
+	return nil
+}
+
+func (c *ContextRpc) Wait(ctx context.Context, params *Params, result *int) error {
+	select {
+	case <-ctx.Done():
+		c.Canceled <- ctx.Err()
+	case <-time.After(5 * time.Second):
+		c.Canceled <- nil
+	}
+	return nil
+}
+
 func TestHttpCall(t *testing.T) {
 	s, _ := jsonrpc4go.NewServer("http", 3201)
 	s.Register(new(IntRpc))
@@ -68,6 +143,11 @@ func TestHttpCallMethod(t *testing.T) {
 func TestHttpNotifyCall(t *testing.T) {
 	s, _ := jsonrpc4go.NewServer("http", 3203)
 	s.Register(new(IntRpc))
+	results := make(chan any, 4)
+	s.SetAfterFunc(func(id any, method string, result any) error {
+		results <- result
+		return nil
+	})
 	go func() {
 		s.Start()
 	}()
@@ -75,8 +155,33 @@ func TestHttpNotifyCall(t *testing.T) {
 	c, _ := jsonrpc4go.NewClient("IntRpc", "http", "127.0.0.1:3203")
 	params := Params{2, 3}
 	result := new(int)
-	_ = c.Call("Add", &params, result, true)
-	if *result != 5 {
+	if err := c.Call("Add", &params, result, true); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	// The notification is processed, but no response is sent back.
+	if r := <-results; r != 5 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 5, r)
+	}
+	if *result != 0 {
+		t.Errorf("Result expected be %d, but %d got", 0, *result)
+	}
+	// A batch of notifications gets no response either.
+	c.BatchAppend("Add", Params{1, 2}, result, true)
+	c.BatchAppend("Add", Params{3, 4}, result, true)
+	if err := c.BatchCall(); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	<-results
+	<-results
+	// Only the requests are answered in a mixed batch.
+	result1, result2 := new(int), new(int)
+	c.BatchAppend("Add", Params{1, 2}, result1, true)
+	err2 := c.BatchAppend("Add", Params{3, 4}, result2, false)
+	if err := c.BatchCall(); err != nil || *err2 != nil || *result1 != 0 || *result2 != 7 {
+		t.Errorf("Results expected be %v, but %v got (%v, %v)", []int{0, 7}, []int{*result1, *result2}, err, *err2)
+	}
+	// The connection is still in sync.
+	if err := c.Call("Add", &params, result, false); err != nil || *result != 5 {
 		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 5, *result)
 	}
 }
@@ -194,3 +299,585 @@ func TestDataType(t *testing.T) {
 		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 1, *result)
 	}
 }
+
+func TestHttpCallContextTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+	c, _ := jsonrpc4go.NewClient("IntRpc", "http", ts.Listener.Addr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	params := Params{1, 2}
+	result := new(int)
+	err := c.CallContext(ctx, "Add", &params, result, false)
+	if !errors.Is(err, client.ErrTimeout) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrTimeout, err)
+	}
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	err = c.CallContext(canceled, "Add", &params, result, false)
+	if !errors.Is(err, client.ErrCanceled) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrCanceled, err)
+	}
+}
+
+func TestHttpContextMethod(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3206)
+	s.Register(new(ContextRpc))
+	var hookMethod string
+	s.SetBeforeContextFunc(func(ctx context.Context, id any, method string, params any) error {
+		if info, ok := common.RequestInfoFromContext(ctx); ok {
+			hookMethod = info.Method
+		}
+		return nil
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("ContextRpc", "http", "127.0.0.1:3206")
+	result := new(ContextResult)
+	err := c.Call("Info", &Params{1, 2}, result, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Transport != "http" || result.ContentType != "application/json" || result.RemoteAddr == "" {
+		t.Errorf("Peer expected be http with application/json, but %+v got", result)
+	}
+	if result.Method != "ContextRpc/Info" || result.Id == nil {
+		t.Errorf("Request info expected be ContextRpc/Info with an id, but %+v got", result)
+	}
+	if hookMethod != "ContextRpc/Info" {
+		t.Errorf("Hook method expected be %s, but %s got", "ContextRpc/Info", hookMethod)
+	}
+}
+
+type hookKey struct{}
+
+func TestServerHooks(t *testing.T) {
+	svr := &common.Server{}
+	var calls []string
+	svr.Hooks.BeforeFunc = func(id any, method string, params any) error {
+		calls = append(calls, "before")
+		return nil
+	}
+	svr.Hooks.BeforeContextFunc = func(ctx context.Context, id any, method string, params any) error {
+		calls = append(calls, fmt.Sprint("before context ", ctx.Value(hookKey{})))
+		return nil
+	}
+	svr.Hooks.AfterFunc = func(id any, method string, result any) error {
+		calls = append(calls, "after")
+		return nil
+	}
+	// The hooks keep their signatures without a context.
+	svr.Before(1, "Add", nil)
+	svr.BeforeContext(context.WithValue(context.Background(), hookKey{}, "v"), 1, "Add", nil)
+	svr.After(1, "Add", 3)
+	expected := "[before before context <nil> before before context v after]"
+	if fmt.Sprint(calls) != expected {
+		t.Errorf("Calls expected be %s, but %v got", expected, calls)
+	}
+}
+
+func TestHttpShutdown(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3207)
+	dc := new(RecordDriver)
+	s.SetDiscovery(dc, "127.0.0.1")
+	s.Register(new(SlowRpc))
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("SlowRpc", "http", "127.0.0.1:3207")
+	params := Params{1, 2}
+	result := new(int)
+	called := make(chan error, 1)
+	go func() {
+		called <- c.Call("Add", &params, result, false)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err.Error())
+	}
+	if err := <-called; err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 3, *result)
+	}
+	if err := <-stopped; !errors.Is(err, server.ErrServerClosed) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, server.ErrServerClosed, err)
+	}
+	if len(dc.Deregistered) != 1 || dc.Deregistered[0] != "SlowRpc:http:3207" {
+		t.Errorf("Deregistered expected be %v, but %v got", []string{"SlowRpc:http:3207"}, dc.Deregistered)
+	}
+}
+
+func TestHttpWatch(t *testing.T) {
+	for _, port := range []int{3208, 3209} {
+		s, _ := jsonrpc4go.NewServer("http", port)
+		s.Register(&PortRpc{port})
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+	}
+	dc := &WatchDriver{
+		Current:   []discovery.Instance{{Host: "127.0.0.1", Port: 3208, Healthy: true}},
+		Instances: make(chan []discovery.Instance),
+	}
+	c, _ := jsonrpc4go.NewClient("PortRpc", "http", dc)
+	defer c.Close()
+	result := new(int)
+	if err := c.Call("Get", &Params{}, result, false); err != nil || *result != 3208 {
+		t.Fatalf("Port expected be %d, but %d got (%v)", 3208, *result, err)
+	}
+	dc.Instances <- []discovery.Instance{{Host: "127.0.0.1", Port: 3209, Healthy: true}}
+	deadline := time.Now().Add(time.Second)
+	for *result != 3209 && time.Now().Before(deadline) {
+		c.Call("Get", &Params{}, result, false)
+	}
+	if *result != 3209 {
+		t.Errorf("Port expected be %d, but %d got", 3209, *result)
+	}
+}
+
+func TestHttpRoundRobin(t *testing.T) {
+	for _, port := range []int{3210, 3211} {
+		s, _ := jsonrpc4go.NewServer("http", port)
+		s.Register(&PortRpc{port})
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+	}
+	c, _ := jsonrpc4go.NewClient("PortRpc", "http", "127.0.0.1:3210,127.0.0.1:3211")
+	defer c.Close()
+	for i := 0; i < 4; i++ {
+		result := new(int)
+		c.Call("Get", &Params{}, result, false)
+		if expected := 3210 + i%2; *result != expected {
+			t.Errorf("Port expected be %d, but %d got", expected, *result)
+		}
+	}
+}
+
+func TestHttpRetry(t *testing.T) {
+	for _, port := range []int{3212, 3213} {
+		s, _ := jsonrpc4go.NewServer("http", port)
+		s.Register(&FlakyRpc{port, port == 3212})
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+	}
+	c, _ := jsonrpc4go.NewClient("FlakyRpc", "http", "127.0.0.1:3212,127.0.0.1:3213")
+	defer c.Close()
+	retry := client.NewRetryPolicy(2, "Get")
+	retry.InitialBackoff = time.Millisecond
+	c.SetOptions(&client.HttpOptions{Retry: retry})
+	for i := 0; i < 4; i++ {
+		result := new(int)
+		if err := c.Call("Get", &Params{}, result, false); err != nil || *result != 3213 {
+			t.Errorf("Port expected be %d, but %d got (%v)", 3213, *result, err)
+		}
+	}
+}
+
+func TestHttpBreaker(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3215)
+	s.Register(&PortRpc{3215})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	breaker := client.NewCircuitBreaker(client.BreakerOptions{ConsecutiveFailures: 1, Cooldown: time.Minute})
+	// Nothing listens on 3214.
+	c, _ := jsonrpc4go.NewClient("PortRpc", "http", "127.0.0.1:3214,127.0.0.1:3215")
+	defer c.Close()
+	c.SetOptions(&client.HttpOptions{Breaker: breaker, Retry: client.NewRetryPolicy(2)})
+	for i := 0; i < 10; i++ {
+		result := new(int)
+		if err := c.Call("Get", &Params{}, result, false); err != nil || *result != 3215 {
+			t.Errorf("Port expected be %d, but %d got (%v)", 3215, *result, err)
+		}
+	}
+	if state := breaker.State("127.0.0.1:3214"); state != client.BREAKER_OPEN {
+		t.Errorf("State expected be %v, but %v got", client.BREAKER_OPEN, state)
+	}
+}
+
+func TestHttpMiddleware(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3216)
+	s.Register(new(IntRpc))
+	s.Use(func(next server.Handler) server.Handler {
+		return func(inv *server.Invocation) error {
+			peer, ok := common.PeerFromContext(inv.Context)
+			if !ok || peer.Header.Get("Content-Type") != "application/json" {
+				return errors.New("Missing peer")
+			}
+			if info, ok := common.RequestInfoFromContext(inv.Context); !ok || info.Method != inv.Method {
+				return errors.New("Missing request info")
+			}
+			return next(inv)
+		}
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "http", "127.0.0.1:3216")
+	params := Params{1, 2}
+	result := new(int)
+	if err := c.Call("Add", &params, result, false); err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 3, *result)
+	}
+}
+
+func TestHttpInterceptor(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3217)
+	s.Register(new(IntRpc))
+	s.Use(func(next server.Handler) server.Handler {
+		return func(inv *server.Invocation) error {
+			peer, ok := common.PeerFromContext(inv.Context)
+			if !ok || peer.Header.Get("Authorization") != "Bearer token" {
+				return &common.Error{Code: -32001, Message: "Unauthorized"}
+			}
+			return next(inv)
+		}
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "http", "127.0.0.1:3217")
+	params := Params{1, 2}
+	result := new(int)
+	err := c.Call("Add", &params, result, false)
+	var e *common.Error
+	if !errors.As(err, &e) || e.Code != -32001 {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "Unauthorized (-32001)", err)
+	}
+	c.Use(func(ctx context.Context, method string, params any, result any, invoker client.Invoker) error {
+		if r, ok := client.RequestFromContext(ctx); ok {
+			r.Header.Set("Authorization", "Bearer token")
+		}
+		return invoker(ctx, method, params, result)
+	})
+	if err := c.Call("Add", &params, result, false); err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 3, *result)
+	}
+}
+
+func TestHttpPanic(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3218)
+	s.Register(new(PanicRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("PanicRpc", "http", "127.0.0.1:3218")
+	result := new(int)
+	for i := 0; i < 2; i++ {
+		err := c.Call("Panic", &Params{1, 2}, result, false)
+		var e *common.Error
+		if !errors.As(err, &e) || e.Code != -32603 {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, common.CodeMap[common.InternalError], err)
+		}
+	}
+}
+
+func TestHttpError(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3219)
+	s.Register(new(ErrorRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("ErrorRpc", "http", "127.0.0.1:3219")
+	result := new(int)
+	err := c.Call("Div", &Params{1, 0}, result, false)
+	var e *jsonrpc4go.Error
+	if !errors.As(err, &e) || e.Code != -32001 || e.Message != "Division by zero" {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "Division by zero (-32001)", err)
+	}
+	data := ErrorData{}
+	if err := common.GetStruct(e.Data, &data); err != nil || data.Field != "b" {
+		t.Errorf("Data expected be %v, but %v got", ErrorData{"b"}, e.Data)
+	}
+	// Other errors are not exposed to the client.
+	err = c.Call("Div", &Params{1, -1}, result, false)
+	if !errors.As(err, &e) || e.Code != common.InternalError {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, common.CodeMap[common.InternalError], err)
+	}
+}
+
+func TestHttpErrorWithoutData(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`)
+	}))
+	defer ts.Close()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "http", ts.Listener.Addr().String())
+	defer c.Close()
+	result := new(int)
+	err := c.Call("Add", &Params{1, 2}, result, false)
+	var e *jsonrpc4go.Error
+	if !errors.As(err, &e) || e.Code != common.MethodNotFound || e.Message != "Method not found" || e.Data != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "Method not found (-32601)", err)
+	}
+}
+
+func TestHttpNotifyNoContent(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3220)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	bodies := map[string]int{
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2}}`:                                                                  http.StatusNoContent,
+		`[{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2}},{"jsonrpc":"2.0","method":"IntRpc/Mul","params":{"a":1,"b":2}}]`: http.StatusNoContent,
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2},"id":"1"}`:                                                         http.StatusOK,
+		`[]`: http.StatusOK,
+	}
+	for body, status := range bodies {
+		resp, err := http.Post("http://127.0.0.1:3220", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Status expected be %d, but %d got (%s)", status, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestHttpRequestId(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3221)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	bodies := map[string]string{
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2},"id":12345678901234567890}`:                                                        `{"id":12345678901234567890,"jsonrpc":"2.0","result":3}`,
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2},"id":"abc"}`:                                                                       `{"id":"abc","jsonrpc":"2.0","result":3}`,
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2},"id":null}`:                                                                        `{"id":null,"jsonrpc":"2.0","result":3}`,
+		`[{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2},"id":1.5},{"jsonrpc":"2.0","method":"IntRpc/Mul","params":{"a":1,"b":2},"id":2}]`: `[{"id":1.5,"jsonrpc":"2.0","result":3},{"id":2,"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found","data":null}}]`,
+		`{"jsonrpc":"2.0","method"`: `{"id":null,"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error","data":null}}`,
+	}
+	for body, expected := range bodies {
+		resp, err := http.Post("http://127.0.0.1:3221", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != expected {
+			t.Errorf("Response expected be %s, but %s got", expected, b)
+		}
+	}
+}
+
+func TestHttpBatchMatchId(t *testing.T) {
+	// The server answers the batch in reverse order.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []common.Request
+		json.NewDecoder(r.Body).Decode(&requests)
+		var responses []any
+		for i := len(requests) - 1; i >= 0; i-- {
+			params := requests[i].Params.(map[string]any)
+			responses = append(responses, common.S(requests[i].Id, common.JsonRpc, params["a"].(float64)+params["b"].(float64)))
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer ts.Close()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "http", ts.Listener.Addr().String())
+	c.SetOptions(&client.HttpOptions{IdGenerator: client.UUIDIdGenerator})
+	results := []*int{new(int), new(int), new(int)}
+	for i, r := range results {
+		c.BatchAppend("Add", Params{i, 10}, r, false)
+	}
+	if err := c.BatchCall(); err != nil {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	for i, r := range results {
+		if *r != i+10 {
+			t.Errorf(EQUAL_MESSAGE_TEMPLETE, i, 10, i+10, *r)
+		}
+	}
+}
+
+func TestHttpBatchUnmatchedId(t *testing.T) {
+	// The server answers only the first request, and adds a response with a null id.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []common.Request
+		json.NewDecoder(r.Body).Decode(&requests)
+		responses := []any{
+			common.E(nil, common.JsonRpc, common.InvalidRequest),
+			common.S(requests[0].Id, common.JsonRpc, 1),
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer ts.Close()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "http", ts.Listener.Addr().String())
+	results := []*int{new(int), new(int)}
+	errs := make([]*error, len(results))
+	for i, r := range results {
+		errs[i] = c.BatchAppend("Add", Params{i, 1}, r, false)
+	}
+	var e *jsonrpc4go.Error
+	if err := c.BatchCall(); !errors.As(err, &e) || e.Code != common.InvalidRequest {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "Invalid request (-32600)", err)
+	}
+	if *errs[0] != nil || *results[0] != 1 {
+		t.Errorf("Result expected be %v, but %v got (%v)", 1, *results[0], *errs[0])
+	}
+	if *results[1] != 0 || !errors.As(*errs[1], &e) || e.Code != common.InvalidRequest {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "Invalid request (-32600)", *errs[1])
+	}
+}
+
+func TestHttpStatusRetry(t *testing.T) {
+	// A proxy in front of the service answers the first request with a bare 502.
+	var (
+		requests atomic.Int32
+		conns    atomic.Int32
+	)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req common.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if requests.Add(1) == 1 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(common.S(req.Id, common.JsonRpc, 3))
+	}))
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "http", ts.Listener.Addr().String())
+	defer c.Close()
+	retry := client.NewRetryPolicy(2, "Add")
+	retry.RetryOn = client.RETRY_ON_DEFAULT | client.RETRY_ON_NETWORK
+	c.SetOptions(&client.HttpOptions{Retry: retry})
+	for i := 0; i < 3; i++ {
+		result := new(int)
+		if err := c.Call("Add", &Params{1, 2}, result, false); err != nil || *result != 3 {
+			t.Errorf("Result expected be %d, but %d got (%v)", 3, *result, err)
+		}
+	}
+	// The requests share the connections of one transport.
+	if n := conns.Load(); n != 1 {
+		t.Errorf("Connections expected be %d, but %d got", 1, n)
+	}
+	// Without retries the status is returned.
+	requests.Store(0)
+	c.SetOptions(&client.HttpOptions{})
+	var se *client.StatusError
+	if err := c.Call("Add", &Params{1, 2}, new(int), false); !errors.As(err, &se) || se.StatusCode != http.StatusBadGateway {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "502 Bad Gateway", err)
+	}
+}
+
+func TestHttpValidation(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3222)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	invalid := `{"id":null,"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request","data":null}}`
+	bodies := map[string]string{
+		`{"method":"IntRpc/Add","params":{"a":1,"b":2},"id":1}`:                   `{"id":1,"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request","data":null}}`,
+		`{"jsonrpc":"1.0","method":"IntRpc/Add","params":{"a":1,"b":2},"id":1}`:   `{"id":1,"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request","data":null}}`,
+		`{"jsonrpc":"2.0","method":"","params":{"a":1,"b":2},"id":1}`:             `{"id":1,"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request","data":null}}`,
+		`{"jsonrpc":"2.0","method":1,"params":{"a":1,"b":2},"id":1}`:              `{"id":1,"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request","data":null}}`,
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","params":"a","id":1}`:             `{"id":1,"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request","data":null}}`,
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2},"id":[1]}`: invalid,
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","id":1}`:                          `{"id":1,"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":"missing param \"a\""}}`,
+		`{"jsonrpc":"2.0","method":"Add","params":{"a":1,"b":2},"id":1}`:          `{"id":1,"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found","data":null}}`,
+		`[]`:    invalid,
+		`1`:     invalid,
+		`[1,2]`: `[` + invalid + `,` + invalid + `]`,
+		`[{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2}},{"jsonrpc":"2.0"}]`: `[` + invalid + `]`,
+	}
+	for body, expected := range bodies {
+		resp, err := http.Post("http://127.0.0.1:3222", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != expected {
+			t.Errorf("Response expected be %s, but %s got (%s)", expected, b, body)
+		}
+	}
+}
+
+func TestHttpLenient(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3223)
+	s.Register(new(IntRpc))
+	s.SetLenient(true)
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	bodies := map[string]string{
+		`{"method":"IntRpc/Add","params":{"a":1,"b":2},"id":1}`:                    `{"id":1,"jsonrpc":"2.0","result":3}`,
+		`{"jsonrpc":"1.0","method":"IntRpc/Add","params":[1,2],"id":"a"}`:          `{"id":"a","jsonrpc":"2.0","result":3}`,
+		`{"method":"IntRpc/Add","params":[1,2],"id":null}`:                         ``,
+		`{"jsonrpc":"2.0","method":"IntRpc/Add","params":{"a":1,"b":2},"id":null}`: `{"id":null,"jsonrpc":"2.0","result":3}`,
+		`{"method":"","params":[1,2],"id":1}`:                                      `{"id":1,"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request","data":null}}`,
+	}
+	for body, expected := range bodies {
+		resp, err := http.Post("http://127.0.0.1:3223", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != expected {
+			t.Errorf("Response expected be %s, but %s got (%s)", expected, b, body)
+		}
+	}
+}
+
+func TestHttpBatchParallelism(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3228)
+	s.Register(new(SlowRpc))
+	s.SetBatchParallelism(4)
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("SlowRpc", "http", "127.0.0.1:3228")
+	results := make([]*int, 4)
+	for i := range results {
+		results[i] = new(int)
+		c.BatchAppend("Add", Params{i, 1}, results[i], false)
+	}
+	start := time.Now()
+	if err := c.BatchCall(); err != nil {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	// Each element takes 500ms, processed one after the other they would take 2s.
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("Batch duration expected be less than %v, but %v got", 1500*time.Millisecond, elapsed)
+	}
+	for i, result := range results {
+		if *result != i+1 {
+			t.Errorf(EQUAL_MESSAGE_TEMPLETE, i, 1, i+1, *result)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package test
 
 import (
 	"fmt"
+	"github.com/sunquakes/jsonrpc4go/discovery"
 	"github.com/sunquakes/jsonrpc4go/discovery/nacos"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNacosRequestURL(t *testing.T) {
@@ -58,6 +61,38 @@ func TestNacosGet(t *testing.T) {
 	}
 }
 
+func TestNacosGetInstances(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"hosts":[{"instanceId":"192.168.1.15#3232#DEFAULT#DEFAULT_GROUP@@java_tcp","ip":"192.168.1.15","port":3232,"weight":2.0,"healthy":true,"clusterName":"DEFAULT","metadata":{"protocol":"tcp","version":"1.0.0"}},{"instanceId":"192.168.1.16#3232#DEFAULT#DEFAULT_GROUP@@java_tcp","ip":"192.168.1.16","port":3232,"weight":1.0,"healthy":false,"clusterName":"DEFAULT","metadata":{}}]}`)
+	}))
+	defer ts.Close()
+	r, err := nacos.NewNacos(ts.URL)
+	if err != nil {
+		t.Error(err)
+	}
+	instances, err := r.GetInstances("java_tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Fatalf("Instances expected be %d, but %d got", 2, len(instances))
+	}
+	instance := instances[0]
+	if instance.Name != "java_tcp" || instance.Protocol != "tcp" || instance.Address() != "192.168.1.15:3232" || instance.Weight != 2 || !instance.Healthy || instance.Zone != "DEFAULT" || instance.Version != "1.0.0" {
+		t.Errorf("Instance expected be java_tcp tcp 192.168.1.15:3232 weighted 2, but %+v got", instance)
+	}
+	if instances[1].Healthy {
+		t.Errorf("Instance %s expected be unhealthy", instances[1].Id)
+	}
+	URL, err := r.Get("java_tcp")
+	if err != nil {
+		t.Error(err)
+	}
+	if URL != "192.168.1.15:3232" {
+		t.Errorf("URL expected be %s, but %s got", "192.168.1.15:3232", URL)
+	}
+}
+
 func TestNacosBeat(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "PUT" {
@@ -78,3 +113,67 @@ func TestNacosBeat(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestNacosDeregister(t *testing.T) {
+	var method, ip, port string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			fmt.Fprintln(w, `{"clientBeatInterval":5000,"code":10200,"lightBeatEnabled":true}`)
+			return
+		}
+		method, ip, port = r.Method, r.URL.Query().Get("ip"), r.URL.Query().Get("port")
+		fmt.Fprintln(w, `ok`)
+	}))
+	defer ts.Close()
+	r, err := nacos.NewNacos(ts.URL)
+	if err != nil {
+		t.Error(err)
+	}
+	err = r.Register("java_tcp", "tcp", "192.168.1.15", 3234)
+	if err != nil {
+		t.Error(err)
+	}
+	err = r.Deregister("java_tcp", "tcp", "192.168.1.15", 3234)
+	if err != nil {
+		t.Error(err)
+	}
+	if method != http.MethodDelete {
+		t.Errorf("Method expected be %v, but %v got", http.MethodDelete, method)
+	}
+	if ip != "192.168.1.15" || port != "3234" {
+		t.Errorf("Instance expected be %v, but %v got", "192.168.1.15:3234", ip+":"+port)
+	}
+	if n := len(r.(*nacos.Nacos).HeartbeatList); n != 0 {
+		t.Errorf("Heartbeats expected be %v, but %v got", 0, n)
+	}
+}
+
+func TestNacosWatch(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			fmt.Fprintln(w, `{"hosts":[{"instanceId":"1","ip":"192.168.1.15","port":3232,"healthy":true}]}`)
+			return
+		}
+		fmt.Fprintln(w, `{"hosts":[{"instanceId":"1","ip":"192.168.1.15","port":3232,"healthy":false},{"instanceId":"2","ip":"192.168.1.16","port":3232,"healthy":true}]}`)
+	}))
+	defer ts.Close()
+	interval := nacos.WATCH_INTERVAL
+	nacos.WATCH_INTERVAL = 10 * time.Millisecond
+	defer func() {
+		nacos.WATCH_INTERVAL = interval
+	}()
+	r, err := nacos.NewNacos(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, cancel := r.(discovery.Watcher).Watch("java_tcp")
+	defer cancel()
+	expected := []string{"192.168.1.15:3232", "192.168.1.16:3232"}
+	for _, e := range expected {
+		addresses := discovery.Addresses(discovery.Healthy(<-ch))
+		if len(addresses) != 1 || addresses[0] != e {
+			t.Errorf("Addresses expected be %v, but %v got", []string{e}, addresses)
+		}
+	}
+}
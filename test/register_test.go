@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sunquakes/jsonrpc4go"
+	"github.com/sunquakes/jsonrpc4go/common"
+	"github.com/sunquakes/jsonrpc4go/server"
+)
+
+type UserRpc struct{}
+
+func (u *UserRpc) Get(params *int, result *string) error {
+	*result = fmt.Sprintf("user%d", *params)
+	return nil
+}
+
+func (u *UserRpc) Delete(params *int, result *bool) error {
+	*result = true
+	return nil
+}
+
+func blockNumber(ctx context.Context, params *[]any, result *int) error {
+	*result = 100
+	return nil
+}
+
+func TestRegisterName(t *testing.T) {
+	svr := &common.Server{}
+	if err := svr.RegisterName("v1.users", new(UserRpc), common.WithAlias("Get", "get"), common.WithExclude("Delete")); err != nil {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	if err := svr.RegisterFunc("eth_blockNumber", blockNumber); err != nil {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	cases := map[string]string{
+		`{"jsonrpc":"2.0","method":"v1.users.get","params":[1],"id":1}`:    `{"id":1,"jsonrpc":"2.0","result":"user1"}`,
+		`{"jsonrpc":"2.0","method":"v1.users/get","params":[2],"id":1}`:    `{"id":1,"jsonrpc":"2.0","result":"user2"}`,
+		`{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`:  `{"id":1,"jsonrpc":"2.0","result":100}`,
+		`{"jsonrpc":"2.0","method":"v1.users.Get","params":[1],"id":1}`:    `{"id":1,"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found","data":null}}`,
+		`{"jsonrpc":"2.0","method":"v1.users.Delete","params":[1],"id":1}`: `{"id":1,"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found","data":null}}`,
+	}
+	for req, expected := range cases {
+		if res := string(svr.Handler([]byte(req))); res != expected {
+			t.Errorf("Response expected be %s, but %s got", expected, res)
+		}
+	}
+	failures := map[string]error{
+		"service already defined: v1.users":       svr.RegisterName("v1.users", new(UserRpc)),
+		"method already defined: v1.users.get":    svr.RegisterName("v1", new(UserRpc), common.WithAlias("Get", "users.get"), common.WithAlias("Delete", "users.delete")),
+		"method already defined: eth_blockNumber": svr.RegisterFunc("eth_blockNumber", blockNumber),
+		"alias of unknown method: Put":            svr.RegisterName("v2.users", new(UserRpc), common.WithAlias("Put", "put")),
+		"exclusion of unknown method: Put":        svr.RegisterName("v2.users", new(UserRpc), common.WithExclude("Put")),
+		"function bad needs the signature func([ctx context.Context, ]params *P, result *R) error": svr.RegisterFunc("bad", func(a int) error { return nil }),
+	}
+	for expected, err := range failures {
+		if err == nil || err.Error() != "rpc: "+expected {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, "rpc: "+expected, err)
+		}
+	}
+	// A failed registration adds none of its methods.
+	if res := string(svr.Handler([]byte(`{"jsonrpc":"2.0","method":"v1.users.delete","params":[1],"id":1}`))); res != `{"id":1,"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found","data":null}}` {
+		t.Errorf("Response expected be %s, but %s got", "method not found", res)
+	}
+}
+
+func TestTcpRegisterName(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3642)
+	s.Register(new(IntRpc))
+	s.RegisterName("v1.users", new(UserRpc), server.WithAlias("Get", "get"))
+	s.RegisterFunc("eth_blockNumber", blockNumber)
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	users, _ := jsonrpc4go.NewClient("v1.users", "tcp", "127.0.0.1:3642")
+	defer users.Close()
+	name := new(string)
+	if err := users.Call("get", []int{7}, name, false); err != nil || *name != "user7" {
+		t.Errorf("Result expected be %s, but %s got (%v)", "user7", *name, err)
+	}
+	flat, _ := jsonrpc4go.NewClient("", "tcp", "127.0.0.1:3642")
+	defer flat.Close()
+	number := new(int)
+	if err := flat.Call("eth_blockNumber", []any{}, number, false); err != nil || *number != 100 {
+		t.Errorf("Result expected be %d, but %d got (%v)", 100, *number, err)
+	}
+	var e *jsonrpc4go.Error
+	if err := flat.Call("eth_gasPrice", []any{}, number, false); !errors.As(err, &e) || e.Code != common.MethodNotFound {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, common.CodeMap[common.MethodNotFound], err)
+	}
+	ints, _ := jsonrpc4go.NewClient("int_rpc", "tcp", "127.0.0.1:3642")
+	defer ints.Close()
+	result := new(int)
+	if err := ints.Call("Add", Params{1, 2}, result, false); err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 2, 3, *result)
+	}
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go/client"
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	r := client.NewRetryPolicy(5)
+	r.Jitter = 0
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 1600 * time.Millisecond, 2 * time.Second}
+	for i, backoff := range expected {
+		if got := r.Backoff(i + 1); got != backoff {
+			t.Errorf("Backoff expected be %v, but %v got", backoff, got)
+		}
+	}
+	r.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		if got := r.Backoff(1); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Errorf("Backoff expected be in [50ms, 100ms], but %v got", got)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	r := client.NewRetryPolicy(3, "Get")
+	ctx := context.Background()
+	dial := &client.DialError{Address: "127.0.0.1:1", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}
+	internal := &common.Error{Code: common.InternalError, Message: "Internal error"}
+	custom := &common.Error{Code: common.CustomError, Message: CUSTOM_ERROR}
+	cases := []struct {
+		err      error
+		methods  []string
+		expected bool
+	}{
+		{dial, []string{"Add"}, true},
+		{internal, []string{"Get"}, true},
+		{internal, []string{"Add"}, false},
+		{internal, []string{"Get", "Add"}, false},
+		{custom, []string{"Get"}, false},
+		{client.ErrTimeout, []string{"Get"}, true},
+		{net.ErrClosed, []string{"Get"}, false},
+	}
+	for _, c := range cases {
+		if got := r.Retryable(ctx, c.err, c.methods); got != c.expected {
+			t.Errorf("Retryable(%v, %v) expected be %v, but %v got", c.err, c.methods, c.expected, got)
+		}
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if r.Retryable(canceled, dial, nil) {
+		t.Error("Retryable expected be false after the context is canceled, but true got")
+	}
+}
@@ -0,0 +1,161 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go"
+	"github.com/sunquakes/jsonrpc4go/client"
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+type CounterRpc struct {
+	subs chan *common.Subscription
+}
+
+func (c *CounterRpc) Count(ctx context.Context, params *[]int, result *string) error {
+	n, ok := jsonrpc4go.NotifierFromContext(ctx)
+	if !ok {
+		return errors.New("notifications unsupported")
+	}
+	sub := n.CreateSubscription()
+	// Notifications sent before the response are delivered after it.
+	for i := 0; i < (*params)[0]; i++ {
+		sub.Notify(i)
+	}
+	c.subs <- sub
+	*result = sub.ID
+	return nil
+}
+
+func (c *CounterRpc) Fail(ctx context.Context, params *[]int, result *string) error {
+	n, _ := jsonrpc4go.NotifierFromContext(ctx)
+	sub := n.CreateSubscription()
+	c.subs <- sub
+	if (*params)[0] != 0 {
+		return errors.New("failed")
+	}
+	*result = sub.ID
+	return nil
+}
+
+func TestSubscriptionUnanswered(t *testing.T) {
+	counter := &CounterRpc{make(chan *common.Subscription, 4)}
+	s, _ := jsonrpc4go.NewServer("tcp", 3656)
+	s.Register(counter)
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	c, _ := jsonrpc4go.NewClient("CounterRpc", "tcp", "127.0.0.1:3656")
+	defer c.Close()
+	// The client never learns the id of a subscription created by a failing request or a notification.
+	id := ""
+	if err := c.Call("Fail", []int{1}, &id, false); err == nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "failed", err)
+	}
+	c.Call("Fail", []int{0}, &id, true)
+	// In a batch only the subscription of the failing request is ended.
+	c.BatchAppend("Fail", []int{0}, &id, false)
+	c.BatchAppend("Fail", []int{1}, new(string), false)
+	c.BatchCall()
+	for i := 0; i < 4; i++ {
+		sub := <-counter.subs
+		ended := sub.ID != id
+		select {
+		case <-sub.Done():
+			if !ended {
+				t.Errorf("Subscription %s expected be active", sub.ID)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if ended {
+				t.Errorf("Subscription %s expected be ended", sub.ID)
+			}
+		}
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	for _, tc := range []struct {
+		protocol string
+		port     int
+	}{{"tcp", 3644}, {"ws", 3227}} {
+		counter := &CounterRpc{make(chan *common.Subscription, 2)}
+		s, _ := jsonrpc4go.NewServer(tc.protocol, tc.port)
+		s.Register(counter)
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+		c, _ := jsonrpc4go.NewClient("CounterRpc", tc.protocol, "127.0.0.1:"+strconv.Itoa(tc.port))
+		ctx := context.Background()
+		sub, err := jsonrpc4go.Subscribe[[]int, int](ctx, c, "Count", []int{5})
+		if err != nil {
+			t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+		}
+		serverSub := <-counter.subs
+		if sub.ID != serverSub.ID {
+			t.Errorf("ID expected be %v, but %v got", serverSub.ID, sub.ID)
+		}
+		for i := 0; i < 5; i++ {
+			if v := <-sub.C; v != i {
+				t.Errorf("Notification expected be %v, but %v got", i, v)
+			}
+		}
+		go serverSub.Notify(5)
+		if v := <-sub.C; v != 5 {
+			t.Errorf("Notification expected be %v, but %v got", 5, v)
+		}
+
+		// Unsubscribe ends the subscription on both sides.
+		if err := sub.Unsubscribe(ctx); err != nil {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+		}
+		select {
+		case <-serverSub.Done():
+		case <-time.After(time.Second):
+			t.Errorf("%s subscription expected be ended by unsubscribe", tc.protocol)
+		}
+		if _, ok := <-sub.C; ok || sub.Err() != nil {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", sub.Err())
+		}
+		if err := serverSub.Notify(6); !errors.Is(err, common.ErrSubscriptionClosed) {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, common.ErrSubscriptionClosed, err)
+		}
+
+		// Plain calls work alongside the subscriptions.
+		id := ""
+		if err := c.Call("Count", []int{0}, &id, false); err != nil || id == "" {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+		}
+		<-counter.subs
+
+		// Closing the connection ends the subscriptions on both sides.
+		sub, err = jsonrpc4go.Subscribe[[]int, int](ctx, c, "Count", []int{0})
+		if err != nil {
+			t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+		}
+		serverSub = <-counter.subs
+		c.Close()
+		select {
+		case <-serverSub.Done():
+		case <-time.After(time.Second):
+			t.Errorf("%s subscription expected be ended by the disconnect", tc.protocol)
+		}
+		<-sub.Done()
+		if !errors.Is(sub.Err(), client.ErrConnectionLost) {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrConnectionLost, sub.Err())
+		}
+		s.Shutdown(ctx)
+	}
+
+	c, _ := jsonrpc4go.NewClient("CounterRpc", "http", "127.0.0.1:3227")
+	defer c.Close()
+	if _, err := jsonrpc4go.Subscribe[[]int, int](context.Background(), c, "Count", []int{1}); !errors.Is(err, client.ErrSubscriptionsUnsupported) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrSubscriptionsUnsupported, err)
+	}
+}
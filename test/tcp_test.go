@@ -1,11 +1,17 @@
 package test
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -13,6 +19,7 @@ import (
 	"github.com/sunquakes/jsonrpc4go"
 	"github.com/sunquakes/jsonrpc4go/client"
 	"github.com/sunquakes/jsonrpc4go/common"
+	"github.com/sunquakes/jsonrpc4go/discovery"
 	"github.com/sunquakes/jsonrpc4go/discovery/consul"
 	"github.com/sunquakes/jsonrpc4go/discovery/nacos"
 	"github.com/sunquakes/jsonrpc4go/server"
@@ -55,6 +62,11 @@ func TestTcpCallMethod(t *testing.T) {
 func TestTcpNotifyCall(t *testing.T) {
 	s, _ := jsonrpc4go.NewServer("tcp", 3603)
 	s.Register(new(IntRpc))
+	results := make(chan any, 4)
+	s.SetAfterFunc(func(id any, method string, result any) error {
+		results <- result
+		return nil
+	})
 	go func() {
 		s.Start()
 	}()
@@ -62,8 +74,33 @@ func TestTcpNotifyCall(t *testing.T) {
 	c, _ := jsonrpc4go.NewClient("IntRpc", "tcp", "127.0.0.1:3603")
 	params := Params{2, 3}
 	result := new(int)
-	c.Call("Add", &params, result, true)
-	if *result != 5 {
+	if err := c.Call("Add", &params, result, true); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	// The notification is processed, but no response is sent back.
+	if r := <-results; r != 5 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 5, r)
+	}
+	if *result != 0 {
+		t.Errorf("Result expected be %d, but %d got", 0, *result)
+	}
+	// A batch of notifications gets no response either.
+	c.BatchAppend("Add", Params{1, 2}, result, true)
+	c.BatchAppend("Add", Params{3, 4}, result, true)
+	if err := c.BatchCall(); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	<-results
+	<-results
+	// Only the requests are answered in a mixed batch.
+	result1, result2 := new(int), new(int)
+	c.BatchAppend("Add", Params{1, 2}, result1, true)
+	err2 := c.BatchAppend("Add", Params{3, 4}, result2, false)
+	if err := c.BatchCall(); err != nil || *err2 != nil || *result1 != 0 || *result2 != 7 {
+		t.Errorf("Results expected be %v, but %v got (%v, %v)", []int{0, 7}, []int{*result1, *result2}, err, *err2)
+	}
+	// The connection is still in sync.
+	if err := c.Call("Add", &params, result, false); err != nil || *result != 5 {
 		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 5, *result)
 	}
 }
@@ -376,3 +413,964 @@ func TestTcpNacos(t *testing.T) {
 		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 21, *result)
 	}
 }
+
+func TestTcpCallContextTimeout(t *testing.T) {
+	// A server which accepts connections but never replies.
+	listener, err := net.Listen("tcp", "127.0.0.1:3622")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				for {
+					if _, err := conn.Read(buf); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "tcp", "127.0.0.1:3622")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	params := Params{1, 2}
+	result := new(int)
+	start := time.Now()
+	err = c.CallContext(ctx, "Add", &params, result, false)
+	if !errors.Is(err, client.ErrTimeout) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrTimeout, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Call expected to return within %s, but it took %s", time.Second, elapsed)
+	}
+}
+
+func TestTcpCallContext(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3623)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "tcp", "127.0.0.1:3623")
+	params := Params{1, 2}
+	result := new(int)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := c.CallContext(ctx, "Add", &params, result, false)
+	if err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 3, *result)
+	}
+	// The connection is reused after a call with a deadline.
+	err = c.Call("Add", &params, result, false)
+	if err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 3, *result)
+	}
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	err = c.CallContext(canceled, "Add", &params, result, false)
+	if !errors.Is(err, client.ErrCanceled) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrCanceled, err)
+	}
+}
+
+func TestTcpContextCanceledOnDisconnect(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3624)
+	svc := &ContextRpc{Canceled: make(chan error, 1)}
+	s.Register(svc)
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("ContextRpc", "tcp", "127.0.0.1:3624")
+	result := new(ContextResult)
+	err := c.Call("Info", &Params{1, 2}, result, false)
+	if err != nil || result.Transport != "tcp" || result.Method != "ContextRpc/Info" {
+		t.Errorf("Context info expected be tcp and ContextRpc/Info, but %+v got", result)
+	}
+	// The client gives up and drops the connection, the server cancels the method context.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	c.CallContext(ctx, "Wait", &Params{1, 2}, new(int), false)
+	select {
+	case err = <-svc.Canceled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, context.Canceled, err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("Method context expected be canceled after the connection closed")
+	}
+}
+
+type SlowRpc struct{}
+
+func (i *SlowRpc) Add(params *Params, result *int) error {
+	time.Sleep(500 * time.Millisecond)
+	*result = params.A + params.B
+	return nil
+}
+
+type RecordDriver struct {
+	sync.Mutex
+	Registered    []string
+	Deregistered  []string
+	DeregisterErr error
+}
+
+func (d *RecordDriver) Register(name string, protocol string, hostname string, port int) error {
+	d.Lock()
+	defer d.Unlock()
+	d.Registered = append(d.Registered, fmt.Sprintf("%s:%s:%d", name, protocol, port))
+	return nil
+}
+
+func (d *RecordDriver) Deregister(name string, protocol string, hostname string, port int) error {
+	d.Lock()
+	defer d.Unlock()
+	d.Deregistered = append(d.Deregistered, fmt.Sprintf("%s:%s:%d", name, protocol, port))
+	return d.DeregisterErr
+}
+
+func (d *RecordDriver) Get(name string) (string, error) {
+	return "", errors.New("unable to get service url")
+}
+
+func (d *RecordDriver) GetInstances(name string) ([]discovery.Instance, error) {
+	return nil, errors.New("unable to get service url")
+}
+
+func TestTcpShutdown(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3625)
+	dc := new(RecordDriver)
+	s.SetDiscovery(dc, "127.0.0.1")
+	s.Register(new(SlowRpc))
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("SlowRpc", "tcp", "127.0.0.1:3625")
+	params := Params{1, 2}
+	result := new(int)
+	called := make(chan error, 1)
+	go func() {
+		called <- c.Call("Add", &params, result, false)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err.Error())
+	}
+	// The in-flight call is drained before the connection is closed.
+	if err := <-called; err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 3, *result)
+	}
+	if err := <-stopped; !errors.Is(err, server.ErrServerClosed) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, server.ErrServerClosed, err)
+	}
+	if len(dc.Deregistered) != 1 || dc.Deregistered[0] != "SlowRpc:tcp:3625" {
+		t.Errorf("Deregistered expected be %v, but %v got", []string{"SlowRpc:tcp:3625"}, dc.Deregistered)
+	}
+	if err := c.Call("Add", &params, result, false); err == nil {
+		t.Error("Error expected be not nil after shutdown, but nil got")
+	}
+}
+
+func TestTcpShutdownTimeout(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3626)
+	dc := &RecordDriver{DeregisterErr: errors.New("deregister failed")}
+	s.SetDiscovery(dc, "127.0.0.1")
+	s.Register(new(SlowRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("SlowRpc", "tcp", "127.0.0.1:3626")
+	called := make(chan error, 1)
+	go func() {
+		called <- c.Call("Add", &Params{1, 2}, new(int), false)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// Both the deregistration and the timeout are reported.
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, dc.DeregisterErr) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, errors.Join(dc.DeregisterErr, context.DeadlineExceeded), err)
+	}
+	if err := <-called; err == nil {
+		t.Error("Error expected be not nil after forced shutdown, but nil got")
+	}
+}
+
+func TestTcpShutdownPartialFrame(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3657)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	conn, err := net.Dial("tcp", "127.0.0.1:3657")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// The request is being received when the shutdown begins, it is answered before the connection is closed.
+	conn.Write([]byte(`{"jsonrpc":"2.0","method":"IntRpc/Add",`))
+	time.Sleep(100 * time.Millisecond)
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		stopped <- s.Shutdown(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	conn.Write([]byte(`"params":{"a":1,"b":2},"id":"1"}` + "\r\n"))
+	b, err := bufio.NewReader(conn).ReadString('\n')
+	expected := `{"id":"1","jsonrpc":"2.0","result":3}` + "\r\n"
+	if err != nil || b != expected {
+		t.Errorf("Response expected be %s, but %s got (%v)", expected, b, err)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+}
+
+type PortRpc struct {
+	Port int
+}
+
+func (p *PortRpc) Get(params *Params, result *int) error {
+	*result = p.Port
+	return nil
+}
+
+type WatchDriver struct {
+	RecordDriver
+	Current   []discovery.Instance
+	Instances chan []discovery.Instance
+}
+
+func (d *WatchDriver) GetInstances(name string) ([]discovery.Instance, error) {
+	return d.Current, nil
+}
+
+func (d *WatchDriver) Watch(name string) (<-chan []discovery.Instance, func()) {
+	return d.Instances, func() {}
+}
+
+func TestTcpWatch(t *testing.T) {
+	for _, port := range []int{3627, 3628} {
+		s, _ := jsonrpc4go.NewServer("tcp", port)
+		s.Register(&PortRpc{port})
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+	}
+	dc := &WatchDriver{
+		Current:   []discovery.Instance{{Host: "127.0.0.1", Port: 3627, Healthy: true}},
+		Instances: make(chan []discovery.Instance),
+	}
+	c, _ := jsonrpc4go.NewClient("PortRpc", "tcp", dc)
+	defer c.Close()
+	result := new(int)
+	if err := c.Call("Get", &Params{}, result, false); err != nil || *result != 3627 {
+		t.Fatalf("Port expected be %d, but %d got (%v)", 3627, *result, err)
+	}
+	// The unhealthy instance is skipped.
+	dc.Instances <- []discovery.Instance{{Host: "127.0.0.1", Port: 3627}, {Host: "127.0.0.1", Port: 3628, Healthy: true}}
+	deadline := time.Now().Add(time.Second)
+	for *result != 3628 && time.Now().Before(deadline) {
+		c.Call("Get", &Params{}, result, false)
+	}
+	if *result != 3628 {
+		t.Errorf("Port expected be %d, but %d got", 3628, *result)
+	}
+}
+
+func TestTcpConsistentHash(t *testing.T) {
+	for _, port := range []int{3629, 3630} {
+		s, _ := jsonrpc4go.NewServer("tcp", port)
+		s.Register(&PortRpc{port})
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+	}
+	c, _ := jsonrpc4go.NewClient("PortRpc", "tcp", "127.0.0.1:3629,127.0.0.1:3630")
+	defer c.Close()
+	c.SetOptions(client.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 2 * 1024 * 1024, Balancer: client.NewConsistentHashBalancer("a")})
+	ports := make(map[int]bool)
+	for key := 0; key < 20; key++ {
+		first := new(int)
+		if err := c.Call("Get", &Params{A: key}, first, false); err != nil {
+			t.Fatal(err)
+		}
+		ports[*first] = true
+		result := new(int)
+		c.Call("Get", &Params{A: key, B: 1}, result, false)
+		if *result != *first {
+			t.Errorf("Port expected be %d, but %d got", *first, *result)
+		}
+	}
+	if len(ports) != 2 {
+		t.Errorf("Ports expected be %d, but %d got", 2, len(ports))
+	}
+}
+
+type FlakyRpc struct {
+	Port int
+	Fail bool
+}
+
+func (f *FlakyRpc) Get(params *Params, result *int) error {
+	if f.Fail {
+		return errors.New("unavailable")
+	}
+	*result = f.Port
+	return nil
+}
+
+func TestTcpRetry(t *testing.T) {
+	for _, port := range []int{3631, 3632} {
+		s, _ := jsonrpc4go.NewServer("tcp", port)
+		s.Register(&FlakyRpc{port, port == 3631})
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+	}
+	c, _ := jsonrpc4go.NewClient("FlakyRpc", "tcp", "127.0.0.1:3631,127.0.0.1:3632")
+	defer c.Close()
+	retry := client.NewRetryPolicy(2, "Get")
+	retry.InitialBackoff = time.Millisecond
+	c.SetOptions(client.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 2 * 1024 * 1024, Retry: retry})
+	for i := 0; i < 4; i++ {
+		result := new(int)
+		if err := c.Call("Get", &Params{}, result, false); err != nil || *result != 3632 {
+			t.Errorf("Port expected be %d, but %d got (%v)", 3632, *result, err)
+		}
+	}
+	// Methods not marked idempotent are not retried.
+	retry.Idempotent = nil
+	failed := 0
+	for i := 0; i < 4; i++ {
+		if err := c.Call("Get", &Params{}, new(int), false); err != nil {
+			failed++
+		}
+	}
+	if failed != 2 {
+		t.Errorf("Failed calls expected be %d, but %d got", 2, failed)
+	}
+}
+
+func TestTcpBreaker(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3634)
+	s.Register(&PortRpc{3634})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	var (
+		lock   sync.Mutex
+		opened []string
+	)
+	breaker := client.NewCircuitBreaker(client.BreakerOptions{
+		ConsecutiveFailures: 1,
+		Cooldown:            time.Minute,
+		OnStateChange: func(address string, from client.BreakerState, to client.BreakerState) {
+			lock.Lock()
+			defer lock.Unlock()
+			if to == client.BREAKER_OPEN {
+				opened = append(opened, address)
+			}
+		},
+	})
+	// Nothing listens on 3633.
+	c, _ := jsonrpc4go.NewClient("PortRpc", "tcp", "127.0.0.1:3633,127.0.0.1:3634")
+	defer c.Close()
+	c.SetOptions(client.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 2 * 1024 * 1024, Breaker: breaker})
+	failed := 0
+	for i := 0; i < 10; i++ {
+		result := new(int)
+		if err := c.Call("Get", &Params{}, result, false); err != nil {
+			failed++
+		} else if *result != 3634 {
+			t.Errorf("Port expected be %d, but %d got", 3634, *result)
+		}
+	}
+	if failed > 1 {
+		t.Errorf("Failed calls expected be at most %d, but %d got", 1, failed)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(opened) != 1 || opened[0] != "127.0.0.1:3633" {
+		t.Errorf("Opened expected be %v, but %v got", []string{"127.0.0.1:3633"}, opened)
+	}
+}
+
+type CountingBalancer struct {
+	client.Balancer
+	lock      sync.Mutex
+	successes map[string]int
+}
+
+func (b *CountingBalancer) Done(instance discovery.Instance, err error) {
+	b.lock.Lock()
+	if err == nil {
+		b.successes[instance.Address()]++
+	}
+	b.lock.Unlock()
+	b.Balancer.Done(instance, err)
+}
+
+func TestMultiplexTcpFailover(t *testing.T) {
+	for _, port := range []int{3652, 3653} {
+		s, _ := jsonrpc4go.NewServer("tcp", port)
+		s.Register(&FlakyRpc{port, port == 3652})
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+		defer s.Shutdown(context.Background())
+	}
+	var (
+		lock   sync.Mutex
+		opened []string
+	)
+	breaker := client.NewCircuitBreaker(client.BreakerOptions{
+		ConsecutiveFailures: 1,
+		Cooldown:            time.Minute,
+		OnStateChange: func(address string, from client.BreakerState, to client.BreakerState) {
+			lock.Lock()
+			defer lock.Unlock()
+			if to == client.BREAKER_OPEN {
+				opened = append(opened, address)
+			}
+		},
+	})
+	// Nothing listens on 3654, 3652 answers with errors.
+	c, _ := jsonrpc4go.NewClient("FlakyRpc", "tcp", "127.0.0.1:3654,127.0.0.1:3652,127.0.0.1:3653")
+	defer c.Close()
+	retry := client.NewRetryPolicy(3, "Get")
+	retry.InitialBackoff = time.Millisecond
+	balancer := &CountingBalancer{Balancer: client.NewRoundRobinBalancer(), successes: make(map[string]int)}
+	c.SetOptions(client.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 2 * 1024 * 1024, Balancer: balancer, Retry: retry, Breaker: breaker, Multiplex: 2})
+	// The multiplexed calls go through the balancer, the circuit breaker and the retry failover.
+	for i := 0; i < 6; i++ {
+		result := new(int)
+		if err := c.Call("Get", &Params{}, result, false); err != nil || *result != 3653 {
+			t.Errorf("Port expected be %d, but %d got (%v)", 3653, *result, err)
+		}
+	}
+	if n := balancer.successes["127.0.0.1:3653"]; n != 6 {
+		t.Errorf("Successes expected be %d, but %d got", 6, n)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(opened) != 1 || opened[0] != "127.0.0.1:3654" {
+		t.Errorf("Opened expected be %v, but %v got", []string{"127.0.0.1:3654"}, opened)
+	}
+}
+
+func TestTcpMiddleware(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3635)
+	s.Register(new(IntRpc))
+	var (
+		lock  sync.Mutex
+		order []string
+	)
+	record := func(name string) {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, name)
+	}
+	s.Use(func(next server.Handler) server.Handler {
+		return func(inv *server.Invocation) error {
+			record("outer before")
+			err := next(inv)
+			record("outer after")
+			return err
+		}
+	}, func(next server.Handler) server.Handler {
+		return func(inv *server.Invocation) error {
+			record("inner " + inv.Service + "/" + inv.Name)
+			params := inv.Params.(*Params)
+			if params.A < 0 {
+				return &common.Error{Code: -32001, Message: "Unauthorized", Data: params.A}
+			}
+			// Serve a cached result without calling the method.
+			if params.A == 100 {
+				inv.Result = 0
+				return nil
+			}
+			params.B *= 10
+			return next(inv)
+		}
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "tcp", "127.0.0.1:3635")
+	defer c.Close()
+	result := new(int)
+	if err := c.Call("Add", &Params{1, 2}, result, false); err != nil || *result != 21 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 20, 21, *result)
+	}
+	expected := []string{"outer before", "inner IntRpc/Add", "outer after"}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Errorf("Order expected be %v, but %v got", expected, order)
+	}
+	if err := c.Call("Add", &Params{100, 2}, result, false); err != nil || *result != 0 {
+		t.Errorf("Result expected be %d, but %d got (%v)", 0, *result, err)
+	}
+	err := c.Call("Add", &Params{-1, 2}, result, false)
+	var e *common.Error
+	if !errors.As(err, &e) || e.Code != -32001 || e.Message != "Unauthorized" {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "Unauthorized (-32001)", err)
+	}
+}
+
+func TestTcpInterceptor(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3636)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "tcp", "127.0.0.1:3636")
+	defer c.Close()
+	var order []string
+	c.Use(func(ctx context.Context, method string, params any, result any, invoker client.Invoker) error {
+		order = append(order, "outer before")
+		r, ok := client.RequestFromContext(ctx)
+		if !ok || !strings.Contains(string(r.Body), `"method":"IntRpc/Add"`) {
+			t.Errorf("Body expected contain %s, but %v got", "IntRpc/Add", r)
+		}
+		err := invoker(ctx, method, params, result)
+		order = append(order, "outer after")
+		return err
+	}, func(ctx context.Context, method string, params any, result any, invoker client.Invoker) error {
+		order = append(order, "inner "+method)
+		p := *params.(*Params)
+		p.B *= 10
+		return invoker(ctx, method, &p, result)
+	})
+	result := new(int)
+	if err := c.Call("Add", &Params{1, 2}, result, false); err != nil || *result != 21 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 20, 21, *result)
+	}
+	expected := []string{"outer before", "inner Add", "outer after"}
+	if fmt.Sprint(order) != fmt.Sprint(expected) {
+		t.Errorf("Order expected be %v, but %v got", expected, order)
+	}
+
+	var methods []string
+	c.UseBatch(func(ctx context.Context, requests []*common.SingleRequest, invoker client.BatchInvoker) error {
+		for _, r := range requests {
+			methods = append(methods, r.Method)
+		}
+		return invoker(ctx, requests[1:])
+	})
+	result1, result2 := new(int), new(int)
+	c.BatchAppend("Add", Params{1, 2}, result1, false)
+	c.BatchAppend("Add", Params{3, 4}, result2, false)
+	if err := c.BatchCall(); err != nil || *result2 != 7 || *result1 != 0 {
+		t.Errorf("Results expected be %v, but %v got (%v)", []int{0, 7}, []int{*result1, *result2}, err)
+	}
+	if fmt.Sprint(methods) != "[Add Add]" {
+		t.Errorf("Methods expected be %v, but %v got", "[Add Add]", methods)
+	}
+}
+
+func TestTcpPanic(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3637)
+	s.Register(new(PanicRpc))
+	panics := make(chan []byte, 1)
+	s.SetPanicHandler(func(ctx context.Context, p any, stack []byte) {
+		panics <- stack
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("PanicRpc", "tcp", "127.0.0.1:3637")
+	defer c.Close()
+	result1 := new(int)
+	err1 := c.BatchAppend("Panic", Params{1, 2}, result1, false)
+	result2 := new(int)
+	err2 := c.BatchAppend("Add", Params{2, 3}, result2, false)
+	if err := c.BatchCall(); err != nil {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	var e *common.Error
+	if !errors.As(*err1, &e) || e.Code != -32603 {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, common.CodeMap[common.InternalError], *err1)
+	}
+	if *err2 != nil || *result2 != 5 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 2, 3, 5, *result2)
+	}
+	if stack := <-panics; !strings.Contains(string(stack), "PanicRpc") {
+		t.Errorf("Stack expected contain %s, but %s got", "PanicRpc", stack)
+	}
+	// The connection survived the panic.
+	err := c.Call("Panic", &Params{1, 2}, result1, false)
+	if !errors.As(err, &e) || e.Code != -32603 {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, common.CodeMap[common.InternalError], err)
+	}
+}
+
+func TestTcpError(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3638)
+	s.Register(new(ErrorRpc))
+	s.SetBeforeFunc(func(id any, method string, params any) error {
+		if params.(Params).A < 0 {
+			return jsonrpc4go.NewError(-32002, "Forbidden", params.(Params).A)
+		}
+		return nil
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("ErrorRpc", "tcp", "127.0.0.1:3638")
+	defer c.Close()
+	result1 := new(int)
+	err1 := c.BatchAppend("Div", Params{1, 0}, result1, false)
+	result2 := new(int)
+	err2 := c.BatchAppend("Div", Params{-1, 1}, result2, false)
+	c.BatchCall()
+	var e *jsonrpc4go.Error
+	if !errors.As(*err1, &e) || e.Code != -32001 || fmt.Sprint(e.Data) != "map[field:b]" {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "Division by zero (-32001)", *err1)
+	}
+	if !errors.As(*err2, &e) || e.Code != -32002 || e.Data != float64(-1) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "Forbidden (-32002)", *err2)
+	}
+}
+
+func TestIdGenerator(t *testing.T) {
+	g := client.NewCounterIdGenerator()
+	if first, second := g(), g(); first != "1" || second != "2" {
+		t.Errorf("Ids expected be %v, but %v got", []string{"1", "2"}, []any{first, second})
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if first, second := client.UUIDIdGenerator(), client.UUIDIdGenerator(); !uuid.MatchString(first.(string)) || first == second {
+		t.Errorf("Ids expected be different UUIDs, but %v and %v got", first, second)
+	}
+}
+
+func TestTcpIdGenerator(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3639)
+	s.Register(new(IntRpc))
+	ids := make(chan any, 4)
+	s.SetBeforeFunc(func(id any, method string, params any) error {
+		ids <- string(id.(json.RawMessage))
+		return nil
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "tcp", "127.0.0.1:3639")
+	defer c.Close()
+	var counter int64
+	c.SetOptions(client.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 1024 * 1024, IdGenerator: func() any {
+		counter++
+		return counter * 100
+	}})
+	params := Params{1, 2}
+	result := new(int)
+	if err := c.Call("Add", &params, result, false); err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, params.A, params.B, 3, *result)
+	}
+	if id := <-ids; id != "100" {
+		t.Errorf("Id expected be %s, but %v got", "100", id)
+	}
+	result1, result2 := new(int), new(int)
+	c.BatchAppend("Add", Params{1, 2}, result1, false)
+	c.BatchAppend("Add", Params{3, 4}, result2, false)
+	if err := c.BatchCall(); err != nil || *result1 != 3 || *result2 != 7 {
+		t.Errorf("Results expected be %v, but %v got (%v)", []int{3, 7}, []int{*result1, *result2}, err)
+	}
+	if first, second := <-ids, <-ids; first != "200" || second != "300" {
+		t.Errorf("Ids expected be %v, but %v got", []string{"200", "300"}, []any{first, second})
+	}
+}
+
+type ListRpc struct{}
+
+func (l *ListRpc) Sum(params *[]int, result *int) error {
+	for _, v := range *params {
+		*result += v
+	}
+	return nil
+}
+
+func (l *ListRpc) Double(params *int, result *int) error {
+	*result = *params * 2
+	return nil
+}
+
+func TestTcpBind(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3640)
+	s.Register(new(ListRpc))
+	s.Register(new(IntRpc))
+	s.SetBindOptions(server.BindOptions{AllowUnknownFields: true})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("ListRpc", "tcp", "127.0.0.1:3640")
+	defer c.Close()
+	result := new(int)
+	if err := c.Call("Sum", []int{1, 2, 3}, result, false); err != nil || *result != 6 {
+		t.Errorf("Result expected be %d, but %d got (%v)", 6, *result, err)
+	}
+	if err := c.Call("Double", []int{4}, result, false); err != nil || *result != 8 {
+		t.Errorf("Result expected be %d, but %d got (%v)", 8, *result, err)
+	}
+	ic, _ := jsonrpc4go.NewClient("IntRpc", "tcp", "127.0.0.1:3640")
+	defer ic.Close()
+	if err := ic.Call("Add", map[string]int{"a": 1, "b": 2, "c": 3}, result, false); err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 2, 3, *result)
+	}
+	err := ic.Call("Add", map[string]int{"a": 1}, result, false)
+	var e *common.Error
+	if !errors.As(err, &e) || e.Code != common.InvalidParams || e.Data != `missing param "b"` {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, `missing param "b"`, err)
+	}
+}
+
+func TestMultiplexTcpCall(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3645)
+	s.Register(new(SlowRpc))
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	c, _ := jsonrpc4go.NewClient("", "tcp", "127.0.0.1:3645")
+	c.SetOptions(client.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 2 * 1024 * 1024, Multiplex: 1})
+	defer c.Close()
+	// A slow call does not hold up the calls sharing its connection.
+	slow := make(chan int, 1)
+	go func() {
+		result := new(int)
+		c.Call("SlowRpc.Add", Params{1, 2}, result, false)
+		slow <- *result
+	}()
+	time.Sleep(100 * time.Millisecond)
+	result := new(int)
+	if err := c.Call("IntRpc.Add", Params{3, 4}, result, false); err != nil || *result != 7 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 3, 4, 7, *result)
+	}
+	select {
+	case <-slow:
+		t.Error("Slow call expected be answered after the fast one")
+	default:
+	}
+	if r := <-slow; r != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 2, 3, r)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result := new(int)
+			if err := c.Call("IntRpc.Add", Params{i, 1}, result, false); err != nil || *result != i+1 {
+				t.Errorf(EQUAL_MESSAGE_TEMPLETE, i, 1, i+1, *result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Packages written at once are read one by one and answered as they complete.
+	conn, err := net.Dial("tcp", "127.0.0.1:3645")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(`{"jsonrpc":"2.0","method":"SlowRpc.Add","params":{"a":1,"b":1},"id":1}` + "\r\n" +
+		`{"jsonrpc":"2.0","method":"IntRpc.Add","params":{"a":2,"b":2},"id":2}` + "\r\n"))
+	reader := bufio.NewReader(conn)
+	for _, want := range []string{`{"id":2,"jsonrpc":"2.0","result":4}`, `{"id":1,"jsonrpc":"2.0","result":2}`} {
+		line, err := reader.ReadString('\n')
+		if err != nil || strings.TrimSpace(line) != want {
+			t.Errorf("Response expected be %s, but %s got (%v)", want, line, err)
+		}
+	}
+}
+
+func TestTcpMaxConcurrency(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3646)
+	s.SetOptions(server.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 2 * 1024 * 1024, MaxConcurrency: 1})
+	s.Register(new(SlowRpc))
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	// With one request at a time the responses keep the order of the requests.
+	conn, err := net.Dial("tcp", "127.0.0.1:3646")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(`{"jsonrpc":"2.0","method":"SlowRpc.Add","params":{"a":1,"b":1},"id":1}` + "\r\n" +
+		`{"jsonrpc":"2.0","method":"IntRpc.Add","params":{"a":2,"b":2},"id":2}` + "\r\n"))
+	reader := bufio.NewReader(conn)
+	for _, want := range []string{`{"id":1,"jsonrpc":"2.0","result":2}`, `{"id":2,"jsonrpc":"2.0","result":4}`} {
+		line, err := reader.ReadString('\n')
+		if err != nil || strings.TrimSpace(line) != want {
+			t.Errorf("Response expected be %s, but %s got (%v)", want, line, err)
+		}
+	}
+}
+
+type GateRpc struct {
+	lock    sync.Mutex
+	active  int
+	peak    int
+	release chan struct{}
+}
+
+func (g *GateRpc) Wait(params *Params, result *int) error {
+	g.lock.Lock()
+	g.active++
+	g.peak = max(g.peak, g.active)
+	g.lock.Unlock()
+	<-g.release
+	g.lock.Lock()
+	g.active--
+	g.lock.Unlock()
+	*result = params.A
+	return nil
+}
+
+func (g *GateRpc) Peak() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.peak
+}
+
+func TestTcpDefaultMaxConcurrency(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3655)
+	gate := &GateRpc{release: make(chan struct{})}
+	s.Register(gate)
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	conn, err := net.Dial("tcp", "127.0.0.1:3655")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// A client pipelining requests gets at most DEFAULT_MAX_CONCURRENCY of them processed at once.
+	n := server.DEFAULT_MAX_CONCURRENCY + 16
+	go func() {
+		for i := 0; i < n; i++ {
+			conn.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"GateRpc.Wait","params":{"a":%d,"b":0},"id":%d}`, i, i) + "\r\n"))
+		}
+	}()
+	time.Sleep(500 * time.Millisecond)
+	if peak := gate.Peak(); peak != server.DEFAULT_MAX_CONCURRENCY {
+		t.Errorf("Peak expected be %d, but %d got", server.DEFAULT_MAX_CONCURRENCY, peak)
+	}
+	close(gate.release)
+	reader := bufio.NewReader(conn)
+	for i := 0; i < n; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+		}
+	}
+	if peak := gate.Peak(); peak != server.DEFAULT_MAX_CONCURRENCY {
+		t.Errorf("Peak expected be %d, but %d got", server.DEFAULT_MAX_CONCURRENCY, peak)
+	}
+}
+
+func TestTcpFramers(t *testing.T) {
+	framers := map[int]server.Framer{
+		3647: server.NewLengthPrefixFramer(),
+		3648: server.NewContentLengthFramer(),
+		3649: server.NewNDJSONFramer(),
+		3650: server.NewDelimiterFramer("aaaaaa"),
+	}
+	for port, framer := range framers {
+		s, _ := jsonrpc4go.NewServer("tcp", port)
+		s.SetOptions(server.TcpOptions{PackageMaxLength: 2 * 1024 * 1024, Framer: framer})
+		s.Register(new(LongRpc))
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+		for _, multiplex := range []int{0, 1} {
+			c, _ := jsonrpc4go.NewClient("LongRpc", "tcp", "127.0.0.1:"+strconv.Itoa(port))
+			c.SetOptions(client.TcpOptions{PackageMaxLength: 2 * 1024 * 1024, Framer: framer, Multiplex: multiplex})
+			// The payloads hold the delimiters and header names of the other framers.
+			params := LongParams{"a\r\nb\nContent-Length: 3\r\n\r\n", LongString1}
+			result := new(string)
+			for i := 0; i < 3; i++ {
+				if err := c.Call("Add", &params, result, false); err != nil || *result != params.A+params.B {
+					t.Errorf("Result expected be %q, but %q got (%d, %v)", params.A+params.B, *result, port, err)
+				}
+			}
+			c.Close()
+		}
+		s.Shutdown(context.Background())
+	}
+}
+
+func TestTcpMaxFrameSize(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3651)
+	s.SetOptions(server.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 256})
+	s.Register(new(LongRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	// A package larger than the maximum gets a parse error, then the connection is closed.
+	conn, err := net.Dial("tcp", "127.0.0.1:3651")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(`{"jsonrpc":"2.0","method":"LongRpc.Add","params":{"a":"` + strings.Repeat("a", 300) + `","b":""},"id":1}` + "\r\n"))
+	reader := bufio.NewReader(conn)
+	want := `{"id":null,"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error","data":"jsonrpc4go: frame exceeds the maximum size"}}`
+	if line, err := reader.ReadString('\n'); err != nil || strings.TrimSpace(line) != want {
+		t.Errorf("Response expected be %s, but %s got (%v)", want, line, err)
+	}
+	if _, err := reader.ReadByte(); err == nil {
+		t.Error("Connection expected be closed after a parse error")
+	}
+
+	// A response larger than the maximum of the client fails the call.
+	c, _ := jsonrpc4go.NewClient("LongRpc", "tcp", "127.0.0.1:3651")
+	c.SetOptions(client.TcpOptions{PackageEof: "\r\n", PackageMaxLength: 64})
+	defer c.Close()
+	result := new(string)
+	if err := c.Call("Add", LongParams{strings.Repeat("a", 50), strings.Repeat("b", 50)}, result, false); !errors.Is(err, common.ErrFrameTooLarge) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, common.ErrFrameTooLarge, err)
+	}
+}
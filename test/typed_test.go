@@ -0,0 +1,77 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/sunquakes/jsonrpc4go"
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+func TestTyped(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3643)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "tcp", "127.0.0.1:3643")
+	defer c.Close()
+	ctx := context.Background()
+	sum, err := jsonrpc4go.Invoke[Params, int](ctx, c, "Add", Params{1, 2})
+	if err != nil || sum != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 2, 3, sum)
+	}
+	sub := jsonrpc4go.NewTyped[Params, int](c, "Sub")
+	if diff, err := sub.Call(ctx, Params{5, 2}); err != nil || diff != 3 {
+		t.Errorf("Result expected be %d, but %d got (%v)", 3, diff, err)
+	}
+
+	b := jsonrpc4go.NewBatch(c)
+	f1 := jsonrpc4go.Add[Params, int](b, "Add", Params{2, 2})
+	f2 := sub.Add(b, Params{9, 4})
+	f3 := jsonrpc4go.Add[Params, int](b, "Mul", Params{2, 2})
+	b.Notify("Add", Params{1, 1})
+	if _, err := f1.Get(); !errors.Is(err, jsonrpc4go.ErrBatchPending) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, jsonrpc4go.ErrBatchPending, err)
+	}
+	if err := b.Call(ctx); err != nil {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	if r, err := f1.Get(); err != nil || r != 4 {
+		t.Errorf("Result expected be %d, but %d got (%v)", 4, r, err)
+	}
+	if r, err := f2.Get(); err != nil || r != 5 {
+		t.Errorf("Result expected be %d, but %d got (%v)", 5, r, err)
+	}
+	var e *jsonrpc4go.Error
+	if _, err := f3.Get(); !errors.As(err, &e) || e.Code != common.MethodNotFound {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, common.CodeMap[common.MethodNotFound], err)
+	}
+
+	// Batches of the same client may be called concurrently, and their futures read from other goroutines.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := jsonrpc4go.NewBatch(c)
+			f := jsonrpc4go.Add[Params, int](b, "Add", Params{i, 1})
+			got := make(chan struct{})
+			go func() {
+				defer close(got)
+				f.Get()
+			}()
+			if err := b.Call(ctx); err != nil {
+				t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+			}
+			<-got
+			if r, err := f.Get(); err != nil || r != i+1 {
+				t.Errorf(EQUAL_MESSAGE_TEMPLETE, i, 1, i+1, r)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go"
+	"github.com/sunquakes/jsonrpc4go/client"
+	"github.com/sunquakes/jsonrpc4go/common"
+	"github.com/sunquakes/jsonrpc4go/server"
+)
+
+func TestUnixCall(t *testing.T) {
+	paths := []string{filepath.Join(t.TempDir(), "rpc.sock")}
+	if runtime.GOOS == "linux" {
+		paths = append(paths, "@jsonrpc4go-test")
+	}
+	// A socket file left by a server that is gone does not keep the server from starting.
+	l, err := net.Listen("unix", paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	for _, path := range paths {
+		s, _ := jsonrpc4go.NewServer("unix", 0, path)
+		s.Register(new(IntRpc))
+		go func() {
+			s.Start()
+		}()
+		<-s.GetEvent()
+		c, _ := jsonrpc4go.NewClient("IntRpc", "unix", path)
+		result := new(int)
+		if err := c.Call("Add", Params{1, 6}, result, false); err != nil || *result != 7 {
+			t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 6, 7, *result)
+		}
+		c.Close()
+		s.Shutdown(context.Background())
+	}
+	if _, err := os.Stat(paths[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, os.ErrNotExist, err)
+	}
+	if _, err := jsonrpc4go.NewServer("unix", 0); err == nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "the unix socket path is required", "nil")
+	}
+}
+
+func TestStdioCall(t *testing.T) {
+	// The pipes of a child process, the server reads the requests and writes the responses.
+	requestReader, requestWriter := io.Pipe()
+	responseReader, responseWriter := io.Pipe()
+	counter := &CounterRpc{make(chan *common.Subscription, 1)}
+	s := server.NewServer(&server.Stdio{Reader: requestReader, Writer: responseWriter})
+	s.SetOptions(server.TcpOptions{PackageMaxLength: 1024, Framer: server.NewContentLengthFramer()})
+	s.Register(new(IntRpc))
+	s.Register(counter)
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.Start()
+	}()
+	<-s.GetEvent()
+
+	// Without a service name the methods are qualified by the caller.
+	c := client.NewStdioClient("", responseReader, requestWriter)
+	c.SetOptions(client.StdioOptions{PackageMaxLength: 1024, Framer: client.NewContentLengthFramer()})
+	result := new(int)
+	if err := c.Call("IntRpc/Add", Params{1, 6}, result, false); err != nil || *result != 7 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 6, 7, *result)
+	}
+	result1, result2 := new(int), new(int)
+	c.BatchAppend("IntRpc/Add", Params{1, 2}, result1, false)
+	c.BatchAppend("IntRpc/Sub", Params{5, 2}, result2, false)
+	if err := c.BatchCall(); err != nil || *result1 != 3 || *result2 != 3 {
+		t.Errorf("Result expected be %v, but %v got (%v)", []int{3, 3}, []int{*result1, *result2}, err)
+	}
+
+	// Notifications share the pipes with the responses.
+	sub, err := jsonrpc4go.Subscribe[[]int, int](context.Background(), c, "CounterRpc/Count", []int{2})
+	if err != nil {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	<-counter.subs
+	for i := 0; i < 2; i++ {
+		if v := <-sub.C; v != i {
+			t.Errorf("Notification expected be %v, but %v got", i, v)
+		}
+	}
+
+	// Closing the pipes stops the server.
+	c.Close()
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, io.EOF) {
+			t.Errorf(ERROR_MESSAGE_TEMPLETE, io.EOF, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Server expected be stopped by the end of the input")
+	}
+	if err := c.Call("IntRpc/Add", Params{1, 6}, result, false); !errors.Is(err, client.ErrClientClosed) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrClientClosed, err)
+	}
+}
+
+func TestStdioKeepStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+	// Closing a client on the stdio of the process unblocks its read, but the program may still print.
+	requestReader, _ := io.Pipe()
+	c := (&client.Stdio{Reader: requestReader}).NewClient()
+	c.Close()
+	if _, err := os.Stdout.Write([]byte("\n")); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sunquakes/jsonrpc4go"
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+type ValidateItem struct {
+	Sku   string `json:"sku" validate:"required,regex=^[A-Z]{2}-[0-9]{1,3}$"`
+	Count int    `json:"count" validate:"min=1,max=99"`
+}
+
+type ValidateOrder struct {
+	Customer string          `json:"customer" validate:"required,len=4"`
+	Channel  string          `json:"channel,omitempty" validate:"oneof=web app"`
+	Note     *string         `json:"note" validate:"max=5"`
+	Items    []ValidateItem  `json:"items" validate:"min=1"`
+	Gift     *ValidateItem   `json:"gift"`
+	Tags     map[string]bool `json:"tags,omitempty" validate:"max=2"`
+}
+
+func (o *ValidateOrder) Place(params *ValidateOrder, result *int) error {
+	*result = len(params.Items)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	note := "too long"
+	order := ValidateOrder{
+		Customer: "abc",
+		Channel:  "mail",
+		Note:     &note,
+		Items:    []ValidateItem{{Sku: "AB-1", Count: 1}, {Sku: "ab-1000", Count: 100}},
+		Gift:     &ValidateItem{},
+		Tags:     map[string]bool{"a": true, "b": true, "c": true},
+	}
+	expected := common.ValidationErrors{
+		{Field: "customer", Rule: "len", Param: "4"},
+		{Field: "channel", Rule: "oneof", Param: "web app"},
+		{Field: "note", Rule: "max", Param: "5"},
+		{Field: "items[1].sku", Rule: "regex", Param: "^[A-Z]{2}-[0-9]{1,3}$"},
+		{Field: "items[1].count", Rule: "max", Param: "99"},
+		{Field: "gift.sku", Rule: "required"},
+		{Field: "gift.count", Rule: "min", Param: "1"},
+		{Field: "tags", Rule: "max", Param: "2"},
+	}
+	err := common.Validate(&order)
+	var errs common.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != len(expected) {
+		t.Fatalf("Errors expected be %v, but %v got", expected, err)
+	}
+	for i := range expected {
+		if errs[i] != expected[i] {
+			t.Errorf("Error expected be %v, but %v got", expected[i], errs[i])
+		}
+	}
+	valid := ValidateOrder{Customer: "abcd", Items: []ValidateItem{{Sku: "AB-1", Count: 1}}}
+	if err := common.Validate(&valid); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+}
+
+func TestTcpValidate(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("tcp", 3641)
+	s.Register(new(ValidateOrder))
+	before := make(chan any, 2)
+	s.SetBeforeFunc(func(id any, method string, params any) error {
+		before <- params
+		return nil
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("ValidateOrder", "tcp", "127.0.0.1:3641")
+	defer c.Close()
+	result := new(int)
+	err := c.Call("Place", ValidateOrder{Customer: "abcd", Items: []ValidateItem{{Sku: "AB-1"}}}, result, false)
+	var e *jsonrpc4go.Error
+	if !errors.As(err, &e) || e.Code != common.InvalidParams {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, common.CodeMap[common.InvalidParams], err)
+	}
+	data, _ := json.Marshal(e.Data)
+	if string(data) != `[{"field":"items[0].count","param":"1","rule":"min"}]` {
+		t.Errorf("Data expected be %s, but %s got", `[{"field":"items[0].count","param":"1","rule":"min"}]`, data)
+	}
+	if len(before) != 0 {
+		t.Errorf("Before hook expected not be called, but %d calls got", len(before))
+	}
+	if err := c.Call("Place", ValidateOrder{Customer: "abcd", Items: []ValidateItem{{Sku: "AB-1", Count: 2}}}, result, false); err != nil || *result != 1 {
+		t.Errorf("Result expected be %d, but %d got (%v)", 1, *result, err)
+	}
+}
+
+type ValidateTypo struct {
+	Items []struct {
+		Count int `json:"count" validate:"mni=3"`
+	} `json:"items"`
+}
+
+func (o *ValidateTypo) Place(params *ValidateTypo, result *int) error {
+	return nil
+}
+
+type ValidateRegex struct {
+	Sku string `json:"sku" validate:"required,regex=("`
+}
+
+func (o *ValidateRegex) Place(params *ValidateRegex, result *int) error {
+	return nil
+}
+
+func TestValidateTags(t *testing.T) {
+	svr := &common.Server{}
+	err := svr.Register(new(ValidateTypo))
+	if err == nil || !strings.Contains(err.Error(), "items[].count: unknown validate rule: mni") {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "unknown validate rule: mni", err)
+	}
+	err = svr.Register(new(ValidateRegex))
+	if err == nil || !strings.Contains(err.Error(), "sku: invalid expression of validate rule regex") {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "invalid expression of validate rule regex", err)
+	}
+	if err := svr.Register(new(ValidateOrder)); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+}
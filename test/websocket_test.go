@@ -0,0 +1,241 @@
+package test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sunquakes/jsonrpc4go"
+	"github.com/sunquakes/jsonrpc4go/client"
+	"github.com/sunquakes/jsonrpc4go/server"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketCall(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("ws", 3224)
+	s.Register(new(IntRpc))
+	notified := make(chan string, 2)
+	s.SetAfterFunc(func(id any, method string, result any) error {
+		if id == nil {
+			notified <- method
+		}
+		return nil
+	})
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "ws", "127.0.0.1:3224")
+	defer c.Close()
+	// Concurrent calls share the connection and are matched by id.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result := new(int)
+			if err := c.Call("Add", Params{i, 1}, result, false); err != nil || *result != i+1 {
+				t.Errorf(EQUAL_MESSAGE_TEMPLETE, i, 1, i+1, *result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result1, result2 := new(int), new(int)
+	err1 := c.BatchAppend("Add", Params{1, 2}, result1, false)
+	c.BatchAppend("Sub", Params{1, 2}, nil, true)
+	err2 := c.BatchAppend("Sub", Params{5, 2}, result2, false)
+	if err := c.BatchCall(); err != nil || *err1 != nil || *err2 != nil {
+		t.Fatalf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	if *result1 != 3 || *result2 != 3 {
+		t.Errorf("Results expected be %d and %d, but %d and %d got", 3, 3, *result1, *result2)
+	}
+	if err := c.Call("Sub", Params{3, 1}, nil, true); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-notified:
+		case <-time.After(time.Second):
+			t.Fatalf("Notifications expected be %d, but %d got", 2, i)
+		}
+	}
+
+	// Plain HTTP requests are refused by a WebSocket server.
+	resp, err := http.Post("http://127.0.0.1:3224", "application/json", strings.NewReader(`{"jsonrpc":"2.0","method":"IntRpc.Add","params":{"a":1,"b":2},"id":1}`))
+	if err != nil || resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("Status expected be %d, but %v got (%v)", http.StatusUpgradeRequired, resp, err)
+	}
+}
+
+func TestWebSocketReconnect(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("ws", 3225)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	c, _ := jsonrpc4go.NewClient("IntRpc", "ws", "127.0.0.1:3225")
+	defer c.Close()
+	result := new(int)
+	if err := c.Call("Add", Params{1, 2}, result, false); err != nil || *result != 3 {
+		t.Fatalf(EQUAL_MESSAGE_TEMPLETE, 1, 2, 3, *result)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Shutdown(ctx)
+
+	s, _ = jsonrpc4go.NewServer("ws", 3225)
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	*result = 0
+	if err := c.Call("Add", Params{2, 2}, result, false); err != nil || *result != 4 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 2, 2, 4, *result)
+	}
+	c.Close()
+	if err := c.Call("Add", Params{2, 2}, result, false); !errors.Is(err, client.ErrClientClosed) {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, client.ErrClientClosed, err)
+	}
+}
+
+func TestHttpWebSocket(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("http", 3226)
+	s.SetOptions(server.HttpOptions{WebSocket: true})
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	for _, protocol := range []string{"http", "ws"} {
+		c, _ := jsonrpc4go.NewClient("IntRpc", protocol, "127.0.0.1:3226")
+		result := new(int)
+		if err := c.Call("Add", Params{1, 2}, result, false); err != nil || *result != 3 {
+			t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 2, 3, *result)
+		}
+		c.Close()
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("ws", 3229)
+	s.SetOptions(server.HttpOptions{AllowedOrigins: []string{"https://app.example.com"}})
+	s.Register(new(IntRpc))
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	dial := func(origin string) error {
+		config, err := websocket.NewConfig("ws://127.0.0.1:3229/", origin)
+		if err != nil {
+			return err
+		}
+		conn, err := config.DialContext(context.Background())
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	// Pages of other origins cannot connect, the server's own origin and the allowed ones can.
+	for origin, allowed := range map[string]bool{
+		"http://127.0.0.1:3229":   true,
+		"https://app.example.com": true,
+		"https://evil.example":    false,
+		"http://localhost":        false,
+	} {
+		if err := dial(origin); (err == nil) != allowed {
+			t.Errorf("Origin %s expected be allowed %v, but %v got", origin, allowed, err)
+		}
+	}
+	// The client sends the origin of the server by default.
+	c, _ := jsonrpc4go.NewClient("IntRpc", "ws", "127.0.0.1:3229")
+	defer c.Close()
+	result := new(int)
+	if err := c.Call("Add", Params{1, 2}, result, false); err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 2, 3, *result)
+	}
+
+	s.SetOptions(server.HttpOptions{CheckOrigin: func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://evil.example"
+	}})
+	if err := dial("https://evil.example"); err != nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "nil", err)
+	}
+	if err := dial("http://127.0.0.1:3229"); err == nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "bad status", "nil")
+	}
+}
+
+func TestWebSocketMaxConcurrency(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("ws", 3230)
+	s.SetOptions(server.HttpOptions{MaxConcurrency: 2})
+	gate := &GateRpc{release: make(chan struct{})}
+	s.Register(gate)
+	go func() {
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	c, _ := jsonrpc4go.NewClient("GateRpc", "ws", "127.0.0.1:3230")
+	defer c.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result := new(int)
+			if err := c.Call("Wait", Params{i, 0}, result, false); err != nil || *result != i {
+				t.Errorf(EQUAL_MESSAGE_TEMPLETE, i, 0, i, *result)
+			}
+		}(i)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if peak := gate.Peak(); peak != 2 {
+		t.Errorf("Peak expected be %d, but %d got", 2, peak)
+	}
+	close(gate.release)
+	wg.Wait()
+}
+
+func TestWebSocketSecure(t *testing.T) {
+	s, _ := jsonrpc4go.NewServer("wss", 3231)
+	s.Register(new(IntRpc))
+	go func() {
+		s.SetOptions(server.HttpOptions{KeyPath: "./secure/localhost+2-key.pem", CertPath: "./secure/localhost+2.pem"})
+		s.Start()
+	}()
+	<-s.GetEvent()
+	defer s.Shutdown(context.Background())
+	// The certificate is signed by a private CA, trusted through the TLS configuration.
+	caCert, err := os.ReadFile("./secure/rootCA.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caCert)
+	c, _ := jsonrpc4go.NewClient("IntRpc", "wss", "127.0.0.1:3231")
+	defer c.Close()
+	c.SetOptions(client.WebSocketOptions{TLSConfig: &tls.Config{RootCAs: roots}})
+	result := new(int)
+	if err := c.Call("Add", Params{1, 2}, result, false); err != nil || *result != 3 {
+		t.Errorf(EQUAL_MESSAGE_TEMPLETE, 1, 2, 3, *result)
+	}
+	// Without it the certificate is not trusted.
+	untrusted, _ := jsonrpc4go.NewClient("IntRpc", "wss", "127.0.0.1:3231")
+	defer untrusted.Close()
+	if err := untrusted.Call("Add", Params{1, 2}, new(int), false); err == nil {
+		t.Errorf(ERROR_MESSAGE_TEMPLETE, "certificate error", err)
+	}
+}
@@ -0,0 +1,237 @@
+package jsonrpc4go
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sunquakes/jsonrpc4go/client"
+	"github.com/sunquakes/jsonrpc4go/common"
+)
+
+/**
+ * @Description: Error returned by a future whose batch has not been called yet
+ */
+var ErrBatchPending = errors.New("jsonrpc4go: batch not called yet")
+
+/**
+ * @Description: Call a method with typed params and result
+ * @Param ctx: Context controlling the call
+ * @Param c: Client
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Return R: Result
+ * @Return error: Error message, an *Error for error responses
+ */
+func Invoke[P, R any](ctx context.Context, c client.Client, method string, params P) (R, error) {
+	var result R
+	err := c.CallContext(ctx, method, params, &result, false)
+	return result, err
+}
+
+/**
+ * @Description: Send a notification with typed params, no response is read
+ * @Param ctx: Context controlling the call
+ * @Param c: Client
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Return error: Error message
+ */
+func Notify[P any](ctx context.Context, c client.Client, method string, params P) error {
+	return c.CallContext(ctx, method, params, nil, true)
+}
+
+/**
+ * @Description: Method handle with typed params and result
+ * @Field Client: Client
+ * @Field Method: Method name
+ */
+type Typed[P, R any] struct {
+	Client client.Client
+	Method string
+}
+
+/**
+ * @Description: Create a method handle with typed params and result
+ * @Param c: Client
+ * @Param method: Method name
+ * @Return Typed[P, R]: Method handle
+ */
+func NewTyped[P, R any](c client.Client, method string) Typed[P, R] {
+	return Typed[P, R]{Client: c, Method: method}
+}
+
+/**
+ * @Description: Call the method
+ * @Receiver t: Method handle
+ * @Param ctx: Context controlling the call
+ * @Param params: Parameters
+ * @Return R: Result
+ * @Return error: Error message, an *Error for error responses
+ */
+func (t Typed[P, R]) Call(ctx context.Context, params P) (R, error) {
+	return Invoke[P, R](ctx, t.Client, t.Method, params)
+}
+
+/**
+ * @Description: Send the method as a notification
+ * @Receiver t: Method handle
+ * @Param ctx: Context controlling the call
+ * @Param params: Parameters
+ * @Return error: Error message
+ */
+func (t Typed[P, R]) Notify(ctx context.Context, params P) error {
+	return Notify(ctx, t.Client, t.Method, params)
+}
+
+/**
+ * @Description: Add the method to a batch
+ * @Receiver t: Method handle
+ * @Param b: Batch
+ * @Param params: Parameters
+ * @Return *Future[R]: Future resolved when the batch is called
+ */
+func (t Typed[P, R]) Add(b *Batch, params P) *Future[R] {
+	return Add[P, R](b, t.Method, params)
+}
+
+/**
+ * @Description: Batch builder, the requests are sent together by Call
+ * @Field client: Client
+ * @Field mu: Lock protecting the requests
+ * @Field requests: Requests added so far
+ */
+type Batch struct {
+	client   client.Client
+	mu       sync.Mutex
+	requests []batchRequest
+}
+
+/**
+ * @Description: Request of a batch
+ * @Field method: Method name
+ * @Field params: Parameters
+ * @Field result: Pointer to the result, nil for notifications
+ * @Field resolve: Function resolving the future with the error of the request
+ */
+type batchRequest struct {
+	method  string
+	params  any
+	result  any
+	resolve func(err error)
+}
+
+/**
+ * @Description: Result of a batch request, available once the batch is called
+ * @Field mu: Lock protecting the fields below, the batch call and Get may run in different goroutines
+ * @Field result: Result
+ * @Field err: Error of the request
+ * @Field done: Whether the batch has been called
+ */
+type Future[R any] struct {
+	mu     sync.Mutex
+	result R
+	err    error
+	done   bool
+}
+
+/**
+ * @Description: Create a batch builder
+ * @Param c: Client sending the batch
+ * @Return *Batch: Batch builder
+ */
+func NewBatch(c client.Client) *Batch {
+	return &Batch{client: c}
+}
+
+/**
+ * @Description: Add a call with typed params and result to a batch
+ * @Param b: Batch
+ * @Param method: Method name
+ * @Param params: Parameters
+ * @Return *Future[R]: Future resolved when the batch is called
+ */
+func Add[P, R any](b *Batch, method string, params P) *Future[R] {
+	f := new(Future[R])
+	// The batch call decodes the result before resolving the future.
+	b.add(batchRequest{method, params, &f.result, func(err error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.err = err
+		f.done = true
+	}})
+	return f
+}
+
+/**
+ * @Description: Add a notification to a batch
+ * @Receiver b: Batch
+ * @Param method: Method name
+ * @Param params: Parameters
+ */
+func (b *Batch) Notify(method string, params any) {
+	b.add(batchRequest{method, params, nil, func(err error) {}})
+}
+
+/**
+ * @Description: Append a request to the batch
+ * @Receiver b: Batch
+ * @Param r: Request
+ */
+func (b *Batch) add(r batchRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, r)
+}
+
+/**
+ * @Description: Send the requests added so far and resolve their futures, the batch may be reused afterwards
+ * @Receiver b: Batch
+ * @Param ctx: Context controlling the call
+ * @Return error: Error of the batch call itself, the errors of the requests are returned by their futures
+ */
+func (b *Batch) Call(ctx context.Context) error {
+	b.mu.Lock()
+	requests := b.requests
+	b.requests = nil
+	b.mu.Unlock()
+	if len(requests) == 0 {
+		return nil
+	}
+	// The requests are sent without the batch list of the client, which concurrent batches would share.
+	list := make([]*common.SingleRequest, len(requests))
+	for i, r := range requests {
+		list[i] = &common.SingleRequest{
+			Method:   r.method,
+			Params:   r.params,
+			Result:   r.result,
+			Error:    new(error),
+			IsNotify: r.result == nil,
+		}
+	}
+	err := b.client.BatchCallRequests(ctx, list)
+	for i, r := range requests {
+		if *list[i].Error == nil && err != nil {
+			r.resolve(err)
+			continue
+		}
+		r.resolve(*list[i].Error)
+	}
+	return err
+}
+
+/**
+ * @Description: Get the result of the request
+ * @Receiver f: Future
+ * @Return R: Result
+ * @Return error: Error of the request, of the batch call, or ErrBatchPending before the batch is called
+ */
+func (f *Future[R]) Get() (R, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.done {
+		var zero R
+		return zero, ErrBatchPending
+	}
+	return f.result, f.err
+}